@@ -3,35 +3,261 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	localFormat string // set by -format
+	zoneFormat  string // set by -utc-format
+	separator   string // set by -separator
 )
 
 func main() {
+	log.SetFlags(0)
 	secs := flag.Bool("secs", false, "Use second resolution")
+	var zones zoneList
+	flag.Var(&zones, "zones", "Show the time in these `zones` after the local time (such as NYC=America/New_York,TOK=Asia/Tokyo; default UTC)")
+	flag.StringVar(&localFormat, "format", "", "Format the local time with this Go time layout or, if it contains a %, strftime `format`")
+	flag.StringVar(&zoneFormat, "utc-format", "", "Format the UTC (or -zones) times with this Go time layout or strftime `format`")
+	noUTC := flag.Bool("no-utc", false, "Show only the local time (no UTC or -zones times)")
+	localeName := flag.String("locale", "", "Use the month and weekday names of `locale` (such as de_DE; default $LC_ALL, $LC_TIME, or $LANG)")
+	offsets := flag.Bool("offsets", false, "Show UTC offsets (such as +09:00) instead of zone abbreviations, except for labeled zones")
+	i3bar := flag.Bool("i3bar", false, "Speak the swaybar/i3bar JSON protocol (see the README for click actions)")
+	waybar := flag.Bool("waybar", false, "Print JSON for a waybar custom module (with the dates and zones in the tooltip)")
+	flag.StringVar(&localColor, "color", "", "With -i3bar, the `color` (#rrggbb) of the local time")
+	flag.StringVar(&zoneColor, "utc-color", "", "With -i3bar, the `color` (#rrggbb) of the other times")
+	flag.BoolVar(&pangoMarkup, "markup", false, "With -i3bar or -waybar, interpret Pango markup in the formats")
+	flag.StringVar(&calendarCmd, "calendar", "", "With -i3bar, run `command` (with /bin/sh -c) when the clock is left-clicked, instead of showing the built-in calendar")
+	var blocks blockList
+	flag.Var(&blocks, "blocks", "Show these status `blocks` (clock, cpu, battery, volume, network, title, event, sun) in order")
+	flag.StringVar(&separator, "separator", " • ", "Separate the blocks (other than with -i3bar) with `text`")
+	var calendarStep *int
+	flag.Func("show-calendar", "Show the built-in calendar in a notification, moved by `months` from the month it last showed (0 for the current month), and exit", func(s string) error {
+		n, err := strconv.Atoi(s)
+		calendarStep = &n
+		return err
+	})
+	flag.Func("location", "The `latitude,longitude` (in degrees north and east) for the sun block and barclock sun", parseLocation)
+	flag.StringVar(&onTimer, "on-timer", "", "Run `command` (with /bin/sh -c and the label in $BARCLOCK_TIMER) when a timer runs out, instead of sending a notification")
 	flag.Parse()
 
-	resolution := time.Minute
+	var err error
+	if timeLocale, err = findLocale(*localeName); err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sunCfg.Latitude == nil {
+		sunCfg = cfg.Sun
+	}
+
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "timer":
+			cmdTimer(flag.Args()[1:])
+		case "stopwatch":
+			cmdStopwatch(flag.Args()[1:])
+		case "sun":
+			cmdSun(flag.Args()[1:])
+		default:
+			flag.Usage()
+			os.Exit(2)
+		}
+		return
+	}
+	if calendarStep != nil {
+		if err := showCalendar(*calendarStep); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *noUTC {
+		zones = zoneList{}
+	}
+	if zones == nil {
+		zones = cfg.Zones
+	}
+	if blocks == nil {
+		blocks = cfg.Blocks
+	}
+	eventCfg = cfg.Event
+	showOffsets = *offsets || cfg.Offsets
+	if zones == nil {
+		zones = zoneList{{Name: "UTC", loc: time.UTC}}
+	}
+	if blocks == nil {
+		blocks = blockList{{Name: "clock"}}
+	}
+
+	c := &clock{
+		res:   time.Minute,
+		zones: zones,
+		shown: -1,
+	}
 	if *secs {
-		resolution = time.Second
+		c.res = time.Second
+	}
+	out := printPlain
+	var clicks chan click
+	switch {
+	case *i3bar && *waybar:
+		log.Fatal("-i3bar and -waybar are mutually exclusive")
+	case *waybar:
+		out = func(segments []segment) { c.printWaybar(segments) }
+	case *i3bar:
+		out = printBlocks
+		clicks = make(chan click)
+		startI3bar(clicks)
 	}
+	texts := make([]string, len(blocks))
+	updates := make(chan blockUpdate)
+	for i, b := range blocks {
+		if b.Name != "clock" {
+			go b.run(i, updates)
+		}
+	}
+	controls := make(chan controlRequest)
+	listenControl(controls)
+	tick, err := newWallTimer()
+	if err != nil {
+		log.Fatalln("Error creating timer:", err)
+	}
+	// SIGUSR1 toggles seconds and SIGUSR2 cycles through the zones (as
+	// with the middle and right buttons with -i3bar).
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
+	var last []segment
 	for {
-		t := time.Now().Truncate(resolution)
-		printTime(t, resolution)
-		t = t.Add(resolution)
-		time.Sleep(time.Until(t))
+		now := time.Now()
+		// The timers and stopwatch go after the clock (or at the end,
+		// if there isn't a clock).
+		timers := append(c.timerSegments(now), c.stopwatch.segments(now)...)
+		var segments []segment
+		for i, b := range blocks {
+			switch {
+			case b.Name == "clock":
+				segments = append(segments, c.segments(now)...)
+				segments = append(segments, timers...)
+				timers = nil
+			case texts[i] != "":
+				segments = append(segments, segment{b.Name, b.Name, texts[i]})
+			}
+		}
+		segments = append(segments, timers...)
+		if !slices.Equal(segments, last) {
+			out(segments)
+			last = segments
+		}
+		res := c.res
+		if len(c.timers) > 0 || c.stopwatch.running {
+			res = time.Second
+		}
+		tick.reset(now.Truncate(res).Add(res))
+		select {
+		case <-tick.C:
+		case cl := <-clicks:
+			c.handleClick(cl)
+		case u := <-updates:
+			texts[u.i] = u.text
+		case req := <-controls:
+			c.handleControl(req)
+		case sig := <-sigs:
+			if sig == syscall.SIGUSR1 {
+				c.toggleSecs()
+			} else {
+				c.cycleZones()
+			}
+		}
 	}
 }
 
-func printTime(t time.Time, res time.Duration) {
+// A clock is what barclock shows and how often it updates.
+type clock struct {
+	res       time.Duration
+	zones     zoneList
+	shown     int // index of the only zone shown, or -1 to show them all
+	timers    []timer
+	stopwatch stopwatch
+}
+
+// A segment is the text of a block or, for the clock, of the local time or
+// one of the zones.
+type segment struct {
+	name     string // barclock for the clock; otherwise, the block name
+	instance string // for the clock, local or the zone's label or name
+	text     string
+}
+
+func (c *clock) segments(t time.Time) []segment {
+	t = t.Truncate(c.res)
 	tailFormat := "15:04 MST"
-	if res == time.Second {
+	if c.res == time.Second {
 		tailFormat = "15:04:05 MST"
 	}
-	localFormat := "Jan 2 " + tailFormat
-	utc := t.UTC()
-	utcFormat := localFormat
-	if t.Day() == utc.Day() {
-		utcFormat = tailFormat
+	format := localFormat
+	if format == "" {
+		format = "Jan 2 " + tailFormat
+	}
+	local := t
+	if showOffsets {
+		local = withOffsetName(t)
+	}
+	segments := []segment{{"barclock", "local", formatTime(local, format)}}
+	for i, z := range c.zones {
+		if c.shown >= 0 && i != c.shown {
+			continue
+		}
+		zt := z.in(t)
+		instance := z.Name
+		if z.Label != "" {
+			instance = z.Label
+		}
+		format := zoneFormat
+		if format == "" {
+			// Elide the date if it's the same as the local date.
+			format = tailFormat
+			if zt.Day() != t.Day() {
+				format = "Jan 2 " + tailFormat
+			}
+		}
+		segments = append(segments, segment{"barclock", instance, formatTime(zt, format)})
+	}
+	return segments
+}
+
+// toggleSecs switches between minute and second resolution.
+func (c *clock) toggleSecs() {
+	if c.res == time.Second {
+		c.res = time.Minute
+	} else {
+		c.res = time.Second
+	}
+}
+
+// cycleZones goes from showing all the zones to showing each of them in
+// turn and then back to all of them.
+func (c *clock) cycleZones() {
+	c.shown++
+	if c.shown == len(c.zones) || len(c.zones) == 1 {
+		c.shown = -1
+	}
+}
+
+func printPlain(segments []segment) {
+	texts := make([]string, len(segments))
+	for i, s := range segments {
+		texts[i] = s.text
 	}
-	fmt.Printf("%s • %s\n", t.Format(localFormat), utc.Format(utcFormat))
+	fmt.Println(strings.Join(texts, separator))
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Besides the clock, barclock can show other status blocks (see -blocks),
+// so that a single process can drive the whole bar. Each block is updated
+// by its own goroutine, every interval and whenever it gets its signal.
+
+// A blockConfig configures a status block (in the config file or, with
+// the default interval and no signal, with -blocks).
+type blockConfig struct {
+	Name     string        `toml:"name"`     // clock, cpu, battery, volume, network, title, event, or sun
+	Interval time.Duration `toml:"interval"` // how often to update the block
+	Signal   int           `toml:"signal"`   // update the block on SIGRTMIN+Signal
+}
+
+// A source gives the text of a kind of block.
+type source struct {
+	interval time.Duration // the default interval
+	read     func() (string, error)
+	// watch, if set, is used instead of read and interval for blocks that
+	// change in response to events: it calls update with each new text.
+	watch func(update func(string))
+}
+
+var sources = map[string]source{
+	"cpu":     {interval: 5 * time.Second, read: readCPUTemp},
+	"battery": {interval: 30 * time.Second, read: readBattery},
+	"volume":  {interval: 10 * time.Second, read: readVolume},
+	"network": {interval: 10 * time.Second, read: readNetwork},
+	"title":   {watch: watchTitle},
+	"event":   {watch: watchEvents},
+	"sun":     {interval: time.Minute, read: readSun},
+}
+
+// sigrtmin is the first real-time signal that's available to programs
+// (glibc reserves the first two).
+const sigrtmin = 34
+
+// blockList is a flag.Value for a comma-separated list of block names.
+type blockList []blockConfig
+
+func (bl *blockList) String() string {
+	var names []string
+	for _, b := range *bl {
+		names = append(names, b.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (bl *blockList) Set(s string) error {
+	var blocks blockList
+	for _, name := range strings.Split(s, ",") {
+		b := blockConfig{Name: name}
+		if err := b.check(); err != nil {
+			return err
+		}
+		blocks = append(blocks, b)
+	}
+	*bl = blocks
+	return nil
+}
+
+func (b *blockConfig) check() error {
+	if _, ok := sources[b.Name]; !ok && b.Name != "clock" {
+		return fmt.Errorf("unknown block %q", b.Name)
+	}
+	if b.Interval < 0 || b.Signal < 0 || sigrtmin+b.Signal > 64 {
+		return fmt.Errorf("block %s: bad interval or signal", b.Name)
+	}
+	return nil
+}
+
+// A blockUpdate is the new text of the block at index i.
+type blockUpdate struct {
+	i    int
+	text string
+}
+
+// run updates the block, which is at index i, sending its text to updates.
+func (b blockConfig) run(i int, updates chan<- blockUpdate) {
+	src := sources[b.Name]
+	if src.watch != nil {
+		src.watch(func(text string) { updates <- blockUpdate{i, text} })
+		return
+	}
+	interval := b.Interval
+	if interval == 0 {
+		interval = src.interval
+	}
+	ticker := time.NewTicker(interval)
+	sig := make(chan os.Signal, 1)
+	if b.Signal > 0 {
+		signal.Notify(sig, syscall.Signal(sigrtmin+b.Signal))
+	}
+	var lastErr string
+	for {
+		text, err := src.read()
+		if err != nil {
+			// Only log each error once, rather than every interval.
+			if err.Error() != lastErr {
+				log.Printf("Error updating %s block: %s", b.Name, err)
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+		updates <- blockUpdate{i, text}
+		select {
+		case <-ticker.C:
+		case <-sig:
+		}
+	}
+}
+
+// readCPUTemp gives the CPU temperature according to cputemp, so that the
+// bar shows the same sensor as cputemp does (as configured in cputemp's
+// config file). cputemp gets the reading from its daemon, if it's running.
+func readCPUTemp() (string, error) {
+	out, err := exec.Command("cputemp").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("cputemp failed: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("cputemp failed: %s", err)
+	}
+	return "CPU " + strings.TrimSpace(string(out)) + "°C", nil
+}
+
+// readBattery gives the charge of the batteries (ignoring those of
+// devices, such as mice), or "" if there aren't any.
+func readBattery() (string, error) {
+	dirs, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, dir := range dirs {
+		if readTrimmed(filepath.Join(dir, "type")) != "Battery" ||
+			readTrimmed(filepath.Join(dir, "scope")) == "Device" {
+			continue
+		}
+		part := "BAT " + readTrimmed(filepath.Join(dir, "capacity")) + "%"
+		if readTrimmed(filepath.Join(dir, "status")) == "Charging" {
+			part += "+"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// readVolume gives the volume of the default audio sink, according to
+// wpctl (from WirePlumber).
+func readVolume() (string, error) {
+	out, err := exec.Command("wpctl", "get-volume", "@DEFAULT_AUDIO_SINK@").Output()
+	if err != nil {
+		return "", fmt.Errorf("wpctl failed: %s", err)
+	}
+	// The output is like "Volume: 0.40" or "Volume: 0.40 [MUTED]".
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unexpected wpctl output %q", out)
+	}
+	if len(fields) > 2 && fields[2] == "[MUTED]" {
+		return "VOL muted", nil
+	}
+	vol, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected wpctl output %q", out)
+	}
+	return fmt.Sprintf("VOL %.0f%%", vol*100), nil
+}
+
+// readNetwork lists the network interfaces that are up, with the link
+// quality of the wireless ones.
+func readNetwork() (string, error) {
+	dirs, err := filepath.Glob("/sys/class/net/*")
+	if err != nil {
+		return "", err
+	}
+	quality, err := wirelessQuality()
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if name == "lo" || readTrimmed(filepath.Join(dir, "operstate")) != "up" {
+			continue
+		}
+		if q, ok := quality[name]; ok {
+			name += fmt.Sprintf(" %d%%", q)
+		}
+		parts = append(parts, name)
+	}
+	if len(parts) == 0 {
+		return "offline", nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// wirelessQuality gives the link quality (as a percentage) of each wireless
+// interface, from /proc/net/wireless.
+func wirelessQuality() (map[string]int, error) {
+	f, err := os.Open("/proc/net/wireless")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	quality := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// After two header lines, each line is like
+		//  wlan0: 0000   55.  -55.  -256        0      0      0      0      0        0
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 2 {
+			continue
+		}
+		link, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "."), 64)
+		if err != nil {
+			continue
+		}
+		// The link quality is out of 70.
+		quality[strings.TrimSpace(name)] = int(link * 100 / 70)
+	}
+	return quality, scanner.Err()
+}
+
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
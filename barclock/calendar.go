@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// calendar renders three months (like cal -3) around the month that is
+// offset months from now's month, with today in bold (as Pango markup).
+// Weeks start on Monday.
+func calendar(now time.Time, offset int) string {
+	y, m, _ := now.Date()
+	var months [][]string
+	for i := -1; i <= 1; i++ {
+		first := time.Date(y, m+time.Month(offset+i), 1, 0, 0, 0, 0, now.Location())
+		months = append(months, monthLines(first, now))
+	}
+	var b strings.Builder
+	for row := range months[0] {
+		for i, lines := range months {
+			if i > 0 {
+				b.WriteString("   ")
+			}
+			b.WriteString(lines[row])
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// monthLines renders the month that starts on first as eight lines, each
+// 20 characters wide (not counting markup): the month, the days of the
+// week, and six weeks.
+func monthLines(first, today time.Time) []string {
+	title := formatTime(first, "January 2006")
+	n := utf8.RuneCountInString(title)
+	pad := (20 - n) / 2
+	lines := []string{
+		fmt.Sprintf("%*s%s%*s", pad, "", title, 20-pad-n, ""),
+		"Mo Tu We Th Fr Sa Su",
+	}
+	// Start on the Monday on or before first.
+	day := first.AddDate(0, 0, -(int(first.Weekday())+6)%7)
+	for week := 0; week < 6; week++ {
+		cells := make([]string, 7)
+		for i := range cells {
+			switch {
+			case day.Month() != first.Month():
+				cells[i] = "  "
+			case day.YearDay() == today.YearDay() && day.Year() == today.Year():
+				cells[i] = fmt.Sprintf("<b>%2d</b>", day.Day())
+			default:
+				cells[i] = fmt.Sprintf("%2d", day.Day())
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		lines = append(lines, strings.Join(cells, " "))
+	}
+	return lines
+}
+
+// calendarTTL is how long after showing the calendar a step (as by
+// scrolling) moves on from the month it showed rather than from the current
+// month.
+const calendarTTL = time.Minute
+
+// showCalendar shows the built-in calendar as a desktop notification, using
+// notify-send, moving it by step months from the month it last showed (or,
+// if step is 0 or the last one was a while ago, showing the current month).
+// Each notification replaces the previous one. The month and notification
+// ID are recorded in $XDG_RUNTIME_DIR/barclock-calendar so that separate
+// runs of barclock -show-calendar (as from waybar) work the same way as
+// clicks with -i3bar.
+func showCalendar(step int) error {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	statePath := filepath.Join(dir, "barclock-calendar")
+	var offset, id int
+	if fi, err := os.Stat(statePath); err == nil && time.Since(fi.ModTime()) < calendarTTL {
+		b, err := os.ReadFile(statePath)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Sscan(string(b), &offset, &id); err != nil {
+			offset, id = 0, 0
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if step == 0 {
+		offset = 0
+	}
+	offset += step
+	now := time.Now()
+	args := []string{
+		"--app-name=barclock",
+		"--print-id",
+		"--hint", "string:x-canonical-private-synchronous:barclock-calendar",
+	}
+	if id > 0 {
+		args = append(args, "--replace-id", strconv.Itoa(id))
+	}
+	month := time.Date(now.Year(), now.Month()+time.Month(offset), 1, 0, 0, 0, 0, now.Location())
+	args = append(args, formatTime(month, "January 2006"), "<tt>"+calendar(now, offset)+"</tt>")
+	out, err := exec.Command("notify-send", args...).Output()
+	if err != nil {
+		return fmt.Errorf("notify-send failed: %s", err)
+	}
+	id, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+	return os.WriteFile(statePath, []byte(fmt.Sprintf("%d %d\n", offset, id)), 0o644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is the (optional) barclock configuration file, which lives at
+// $XDG_CONFIG_HOME/barclock/config.toml. For example:
+//
+//	offsets = true
+//
+//	[[zones]]
+//	label = "NYC"
+//	zone = "America/New_York"
+//
+//	[[zones]]
+//	zone = "Asia/Tokyo"
+//
+//	[[blocks]]
+//	name = "volume"
+//	interval = "1m"
+//	signal = 1
+//
+//	[[blocks]]
+//	name = "clock"
+//
+//	[event]
+//	ics = ["$HOME/.calendars/work.ics"]
+//	countdown = "10m"
+//
+//	[sun]
+//	latitude = 40.7
+//	longitude = -74.0
+type config struct {
+	// Offsets shows UTC offsets instead of zone abbreviations (as with
+	// -offsets). Zones can also set offset individually.
+	Offsets bool `toml:"offsets"`
+
+	// Zones are shown after the local time, in order (as with -zones).
+	Zones zoneList `toml:"zones"`
+
+	// Blocks are the status blocks to show, in order (as with -blocks).
+	Blocks blockList `toml:"blocks"`
+
+	// Event configures the event block.
+	Event eventConfig `toml:"event"`
+
+	// Sun is the location for the sun block and barclock sun.
+	Sun sunConfig `toml:"sun"`
+}
+
+func readConfig() (*config, error) {
+	cfg := new(config)
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("error establishing config dir: %s", err)
+	}
+	path := filepath.Join(dir, "barclock", "config.toml")
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %s", path, err)
+	}
+	for i := range cfg.Zones {
+		if err := cfg.Zones[i].load(); err != nil {
+			return nil, fmt.Errorf("config file %s: %s", path, err)
+		}
+	}
+	for _, b := range cfg.Blocks {
+		if err := b.check(); err != nil {
+			return nil, fmt.Errorf("config file %s: %s", path, err)
+		}
+	}
+	if err := cfg.Sun.check(); err != nil {
+		return nil, fmt.Errorf("config file %s: %s", path, err)
+	}
+	return cfg, nil
+}
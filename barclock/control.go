@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The running barclock listens on a unix socket for commands from other
+// runs of barclock (such as barclock timer). Each connection carries one
+// request (a line of JSON) and one reply.
+
+func socketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "barclock.sock")
+}
+
+type controlRequest struct {
+	Cmd      string        `json:"cmd"` // timer, cancel-timers, or stopwatch
+	Duration time.Duration `json:"duration,omitempty"`
+	Label    string        `json:"label,omitempty"`
+	Action   string        `json:"action,omitempty"` // for stopwatch
+
+	reply chan controlReply
+}
+
+type controlReply struct {
+	Output string `json:"output,omitempty"` // printed by the client
+	Error  string `json:"error,omitempty"`
+}
+
+// listenControl listens on the socket, sending the requests it gets to
+// reqs. If another barclock is already listening, it logs that and gives
+// up, since only one of them can be controlled.
+func listenControl(reqs chan<- controlRequest) {
+	path := socketPath()
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		log.Printf("Another barclock is listening on %s; not listening for commands", path)
+		return
+	}
+	if err := os.RemoveAll(path); err != nil {
+		log.Fatalln("Error creating socket file:", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatalln("Error listening with socket file:", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Fatalln("Accept error:", err)
+			}
+			go handleControl(conn, reqs)
+		}
+	}()
+}
+
+func handleControl(conn net.Conn, reqs chan<- controlRequest) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req controlRequest
+	var reply controlReply
+	if err := json.Unmarshal(line, &req); err != nil {
+		reply.Error = fmt.Sprintf("bad request: %s", err)
+	} else {
+		req.reply = make(chan controlReply, 1)
+		reqs <- req
+		reply = <-req.reply
+	}
+	json.NewEncoder(conn).Encode(reply)
+}
+
+// sendControl sends req to the running barclock, printing its output.
+func sendControl(req controlRequest) error {
+	conn, err := net.Dial("unix", socketPath())
+	if err != nil {
+		return fmt.Errorf("can't reach the running barclock: %s", err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var reply controlReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return fmt.Errorf("error reading reply from barclock: %s", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("%s", reply.Error)
+	}
+	if reply.Output != "" {
+		fmt.Println(reply.Output)
+	}
+	return nil
+}
+
+// handleControl carries out a request from the socket.
+func (c *clock) handleControl(req controlRequest) {
+	var reply controlReply
+	switch req.Cmd {
+	case "timer":
+		c.timers = append(c.timers, timer{label: req.Label, end: time.Now().Add(req.Duration)})
+	case "cancel-timers":
+		c.timers = nil
+	case "stopwatch":
+		var err error
+		reply.Output, err = c.stopwatch.do(req.Action, time.Now())
+		if err != nil {
+			reply.Error = err.Error()
+		}
+	default:
+		reply.Error = fmt.Sprintf("unknown command %q", req.Cmd)
+	}
+	req.reply <- reply
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The event block shows the next event from the calendars listed in the
+// config file: .ics files and/or the output of a command (such as gcalcli
+// agenda --tsv). The calendars are read every Refresh; the block is updated
+// every minute.
+
+// eventConfig is the [event] section of the config file.
+type eventConfig struct {
+	// ICS lists iCalendar files. $VAR and ${VAR} are expanded.
+	ICS []string `toml:"ics"`
+
+	// Command is run (with /bin/sh -c) to list events, one per line. Each
+	// line starts with the date and time (as 2006-01-02 15:04, separated by
+	// a space or tab); the title is the rest of the line or, if the line
+	// has tabs, its last tab-separated field. Other lines are ignored.
+	Command string `toml:"command"`
+
+	// Countdown is how long before an event the block counts down to it
+	// (default 15m).
+	Countdown time.Duration `toml:"countdown"`
+
+	// Refresh is how often to read the calendars (default 5m).
+	Refresh time.Duration `toml:"refresh"`
+}
+
+// eventCfg is the event configuration, from the config file.
+var eventCfg eventConfig
+
+// eventLookahead is how far ahead the event block looks for the next
+// event.
+const eventLookahead = 24 * time.Hour
+
+// maxEventTitleLength is the length (in runes) beyond which the event block
+// truncates the event title.
+const maxEventTitleLength = 30
+
+type event struct {
+	start time.Time
+	title string
+}
+
+// watchEvents calls update with the next event every minute.
+func watchEvents(update func(string)) {
+	countdown := eventCfg.Countdown
+	if countdown == 0 {
+		countdown = 15 * time.Minute
+	}
+	refresh := eventCfg.Refresh
+	if refresh == 0 {
+		refresh = 5 * time.Minute
+	}
+	var events []event
+	var lastRead time.Time
+	var lastErr string
+	for {
+		now := time.Now()
+		if now.Sub(lastRead) >= refresh {
+			var err error
+			events, err = readEvents(now, now.Add(eventLookahead+refresh))
+			// Only log each error once, rather than every refresh.
+			if err != nil && err.Error() != lastErr {
+				log.Println("Error reading events:", err)
+			}
+			lastErr = ""
+			if err != nil {
+				lastErr = err.Error()
+			}
+			lastRead = now
+		}
+		update(nextEvent(events, now, countdown))
+		time.Sleep(time.Until(now.Truncate(time.Minute).Add(time.Minute)))
+	}
+}
+
+// nextEvent gives the text of the event block: the next event to start
+// after now, and when (or, if it's within countdown, how soon) it starts.
+func nextEvent(events []event, now time.Time, countdown time.Duration) string {
+	for _, e := range events {
+		if !e.start.After(now) {
+			continue
+		}
+		if e.start.Sub(now) > eventLookahead {
+			break
+		}
+		title := e.title
+		if r := []rune(title); len(r) > maxEventTitleLength {
+			title = string(r[:maxEventTitleLength]) + "…"
+		}
+		if d := e.start.Sub(now); d <= countdown {
+			return fmt.Sprintf("%s in %dm", title, int((d+time.Minute-1)/time.Minute))
+		}
+		return title + " " + e.start.Local().Format("15:04")
+	}
+	return ""
+}
+
+// readEvents reads the (timed) events starting between from and to from
+// the calendars, sorted by start time. It returns the events it could read
+// along with any error.
+func readEvents(from, to time.Time) ([]event, error) {
+	var events []event
+	var errs []string
+	for _, path := range eventCfg.ICS {
+		path = os.ExpandEnv(path)
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		evs, err := parseICS(f, from, to)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+		}
+		events = append(events, evs...)
+	}
+	if eventCfg.Command != "" {
+		out, err := exec.Command("/bin/sh", "-c", eventCfg.Command).Output()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("event command failed: %s", err))
+		}
+		events = append(events, parseEventLines(string(out), from, to)...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].start.Before(events[j].start) })
+	if len(errs) > 0 {
+		return events, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return events, nil
+}
+
+func parseEventLines(out string, from, to time.Time) []event {
+	var events []event
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		start, err := time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+fields[1], time.Local)
+		if err != nil || start.Before(from) || start.After(to) {
+			continue
+		}
+		var title string
+		if i := strings.LastIndexByte(line, '\t'); i >= 0 {
+			title = line[i+1:]
+		} else {
+			title = strings.Join(fields[2:], " ")
+		}
+		events = append(events, event{start, strings.TrimSpace(title)})
+	}
+	return events
+}
+
+// parseICS reads the timed events from an iCalendar file (RFC 5545) that
+// start between from and to. It understands daily and weekly recurrences
+// (with INTERVAL, COUNT, UNTIL, BYDAY, and EXDATE), along with changed and
+// cancelled occurrences (events with a RECURRENCE-ID); other recurring
+// events only give their first occurrence. All-day events are skipped.
+func parseICS(r io.Reader, from, to time.Time) ([]event, error) {
+	var vevents []*vevent
+	var cur *vevent
+	for _, line := range unfoldICS(r) {
+		name, params, value := parseICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &vevent{}
+		case name == "END" && value == "VEVENT":
+			if cur != nil {
+				vevents = append(vevents, cur)
+			}
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.uid = value
+		case name == "DTSTART":
+			if params["VALUE"] == "DATE" {
+				cur.allDay = true
+				break
+			}
+			t, err := parseICSTime(value, params["TZID"])
+			if err != nil {
+				return expandICS(vevents, from, to), err
+			}
+			cur.start = t
+		case name == "RECURRENCE-ID":
+			if t, err := parseICSTime(value, params["TZID"]); err == nil {
+				cur.recurrenceID = t
+			}
+		case name == "SUMMARY":
+			cur.summary = unescapeICS(value)
+		case name == "STATUS":
+			if value == "CANCELLED" {
+				cur.cancelled = true
+			}
+		case name == "RRULE":
+			cur.rrule = value
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, err := parseICSTime(v, params["TZID"]); err == nil {
+					cur.exdates = append(cur.exdates, t)
+				}
+			}
+		}
+	}
+	return expandICS(vevents, from, to), nil
+}
+
+// expandICS lists the occurrences of the events between from and to. An
+// event with a RECURRENCE-ID replaces that occurrence of the recurring event
+// with the same UID (or, if it's cancelled, just removes it).
+func expandICS(vevents []*vevent, from, to time.Time) []event {
+	overridden := make(map[string][]time.Time)
+	for _, e := range vevents {
+		if e.uid != "" && !e.recurrenceID.IsZero() {
+			overridden[e.uid] = append(overridden[e.uid], e.recurrenceID)
+		}
+	}
+	var events []event
+	for _, e := range vevents {
+		if e.start.IsZero() || e.allDay || e.cancelled {
+			continue
+		}
+		if e.recurrenceID.IsZero() {
+			if e.uid != "" {
+				e.exdates = append(e.exdates, overridden[e.uid]...)
+			}
+		} else {
+			e.rrule = "" // an override is a single occurrence
+		}
+		for _, t := range e.occurrences(from, to) {
+			events = append(events, event{t, e.summary})
+		}
+	}
+	return events
+}
+
+type vevent struct {
+	uid          string
+	start        time.Time
+	recurrenceID time.Time // for an override of one occurrence
+	summary      string
+	rrule        string
+	exdates      []time.Time
+	allDay       bool
+	cancelled    bool
+}
+
+// occurrences lists the starts of the event between from and to.
+func (e *vevent) occurrences(from, to time.Time) []time.Time {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(e.rrule, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			rule[k] = v
+		}
+	}
+	in := func(t time.Time) bool { return !t.Before(from) && !t.After(to) }
+	freq := rule["FREQ"]
+	if freq != "DAILY" && freq != "WEEKLY" {
+		if in(e.start) {
+			return []time.Time{e.start}
+		}
+		return nil
+	}
+	interval, _ := strconv.Atoi(rule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(rule["COUNT"])
+	var until time.Time
+	if v, ok := rule["UNTIL"]; ok {
+		if len(v) == len("20060102") {
+			v += "T235959"
+		}
+		until, _ = parseICSTime(v, e.start.Location().String())
+	}
+	days := map[time.Weekday]bool{e.start.Weekday(): true}
+	if v, ok := rule["BYDAY"]; ok && freq == "WEEKLY" {
+		days = make(map[time.Weekday]bool)
+		for _, d := range strings.Split(v, ",") {
+			if wd, ok := icsWeekdays[d]; ok {
+				days[wd] = true
+			}
+		}
+	}
+	// Step a day at a time (using AddDate, so that the time of day stays
+	// put across DST changes), counting occurrences from the start.
+	var starts []time.Time
+	n := 0
+	weekStart := e.start.AddDate(0, 0, -(int(e.start.Weekday())+6)%7) // Monday
+	for day := 0; ; day++ {
+		t := e.start.AddDate(0, 0, day)
+		if t.After(to) || !until.IsZero() && t.After(until) || count > 0 && n >= count {
+			break
+		}
+		switch freq {
+		case "DAILY":
+			if day%interval != 0 {
+				continue
+			}
+		case "WEEKLY":
+			week := int(t.Sub(weekStart).Hours()/24+0.5) / 7
+			if week%interval != 0 || !days[t.Weekday()] {
+				continue
+			}
+		}
+		n++
+		if in(t) && !e.excluded(t) {
+			starts = append(starts, t)
+		}
+	}
+	return starts
+}
+
+func (e *vevent) excluded(t time.Time) bool {
+	for _, x := range e.exdates {
+		if x.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// unfoldICS reads the lines of an iCalendar file, joining the continuation
+// lines (which start with a space or tab) onto the lines they continue.
+func unfoldICS(r io.Reader) []string {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICSLine splits a content line like
+//
+//	DTSTART;TZID=America/New_York:20240213T140500
+//
+// into its name, parameters, and value.
+func parseICSLine(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseICSTime parses an iCalendar date-time: in UTC (with a trailing Z), in
+// the zone tzid, or (if tzid is empty or unknown) in local time.
+func parseICSTime(v, tzid string) (time.Time, error) {
+	if strings.HasSuffix(v, "Z") {
+		return time.Parse("20060102T150405Z", v)
+	}
+	loc := time.Local
+	if tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation("20060102T150405", v, loc)
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeICS(s string) string { return icsUnescaper.Replace(s) }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// formatTime formats t with format, which is a strftime format if it
+// contains a % and otherwise a Go time layout (as for time.Time.Format)
+// with the extra tokens listed in layoutTokens. Month and weekday names are
+// in the -locale language.
+func formatTime(t time.Time, format string) string {
+	if strings.Contains(format, "%") {
+		return strftime(t, format)
+	}
+	var b strings.Builder
+	start := 0 // the start of the layout that hasn't been formatted yet
+	for i := 0; i < len(format); {
+		text, n := layoutToken(t, format[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		// Format the layout on either side of the token separately, so
+		// that the token's text isn't taken for part of the layout.
+		b.WriteString(t.Format(format[start:i]))
+		b.WriteString(text)
+		i += n
+		start = i
+	}
+	b.WriteString(t.Format(format[start:]))
+	return b.String()
+}
+
+// layoutToken formats the token (one of layoutTokens or, with -locale, a
+// month or weekday name) at the start of the layout s, returning its text
+// and length. It returns a length of 0 if s doesn't start with such a
+// token.
+func layoutToken(t time.Time, s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		if j := strings.IndexByte(s, '}'); j > 0 {
+			if tok, ok := layoutTokens[s[1:j]]; ok {
+				return tok(t), j + 1
+			}
+		}
+		return "", 0
+	}
+	if timeLocale == nil {
+		return "", 0
+	}
+	// These are checked in the same order as Go does.
+	for _, name := range []struct {
+		tok string
+		f   func(time.Time) string
+	}{
+		{"January", timeLocale.month},
+		{"Jan", timeLocale.shortMonth},
+		{"Monday", timeLocale.day},
+		{"Mon", timeLocale.shortDay},
+	} {
+		if strings.HasPrefix(s, name.tok) {
+			return name.f(t), len(name.tok)
+		}
+	}
+	return "", 0
+}
+
+// layoutTokens are the tokens, written in braces (as in {week}), that Go
+// time layouts can use in addition to Go's own.
+var layoutTokens = map[string]func(time.Time) string{
+	// The ISO 8601 week number (01-53) and the year it belongs to.
+	"week": func(t time.Time) string {
+		_, week := t.ISOWeek()
+		return fmt.Sprintf("%02d", week)
+	},
+	"isoyear": func(t time.Time) string {
+		year, _ := t.ISOWeek()
+		return fmt.Sprintf("%04d", year)
+	},
+	// The day of the year (001-366).
+	"yday": func(t time.Time) string { return fmt.Sprintf("%03d", t.YearDay()) },
+	// The ISO 8601 ordinal date, as in 2024-044.
+	"ordinal": func(t time.Time) string { return fmt.Sprintf("%04d-%03d", t.Year(), t.YearDay()) },
+}
+
+// strftime formats t according to the strftime(3) format. It supports the
+// common conversions along with the glibc flags - (don't pad), _ (pad with
+// spaces), and 0 (pad with zeros), so that, for instance, %-d gives the day
+// of the month without padding.
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i == len(format)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		start := i
+		i++
+		var pad byte // 0 means the conversion's default
+		switch format[i] {
+		case '-', '_', '0':
+			if i < len(format)-1 {
+				pad = format[i]
+				i++
+			}
+		}
+		num := func(n, width int, defaultPad byte) {
+			if pad != 0 {
+				defaultPad = pad
+			}
+			switch defaultPad {
+			case '-':
+				fmt.Fprintf(&b, "%d", n)
+			case '_':
+				fmt.Fprintf(&b, "%*d", width, n)
+			default:
+				fmt.Fprintf(&b, "%0*d", width, n)
+			}
+		}
+		hour12 := t.Hour() % 12
+		if hour12 == 0 {
+			hour12 = 12
+		}
+		switch format[i] {
+		case 'a':
+			b.WriteString(formatTime(t, "Mon"))
+		case 'A':
+			b.WriteString(formatTime(t, "Monday"))
+		case 'b', 'h':
+			b.WriteString(formatTime(t, "Jan"))
+		case 'B':
+			b.WriteString(formatTime(t, "January"))
+		case 'd':
+			num(t.Day(), 2, '0')
+		case 'e':
+			num(t.Day(), 2, '_')
+		case 'H':
+			num(t.Hour(), 2, '0')
+		case 'I':
+			num(hour12, 2, '0')
+		case 'k':
+			num(t.Hour(), 2, '_')
+		case 'l':
+			num(hour12, 2, '_')
+		case 'm':
+			num(int(t.Month()), 2, '0')
+		case 'M':
+			num(t.Minute(), 2, '0')
+		case 'S':
+			num(t.Second(), 2, '0')
+		case 'j':
+			num(t.YearDay(), 3, '0')
+		case 'V':
+			_, week := t.ISOWeek()
+			num(week, 2, '0')
+		case 'G':
+			year, _ := t.ISOWeek()
+			num(year, 4, '-')
+		case 'g':
+			year, _ := t.ISOWeek()
+			num(year%100, 2, '0')
+		case 'u':
+			num((int(t.Weekday())+6)%7+1, 1, '-')
+		case 'w':
+			num(int(t.Weekday()), 1, '-')
+		case 'y':
+			num(t.Year()%100, 2, '0')
+		case 'Y':
+			num(t.Year(), 4, '-')
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'P':
+			b.WriteString(t.Format("pm"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 'D':
+			b.WriteString(t.Format("01/02/06"))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '%':
+			b.WriteByte('%')
+		default:
+			// Leave unknown conversions as they are.
+			b.WriteString(format[start : i+1])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// With -i3bar, barclock speaks the swaybar/i3bar protocol (see
+// swaybar-protocol(7)): it writes a header and then an endless JSON array
+// with an array of blocks for each update, and reads click events from
+// stdin.
+
+var (
+	localColor  string // set by -color
+	zoneColor   string // set by -utc-color
+	pangoMarkup bool   // set by -markup
+	calendarCmd string // set by -calendar
+)
+
+type block struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Markup   string `json:"markup,omitempty"`
+}
+
+// A click is a click event sent by the bar.
+type click struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	Button   int    `json:"button"`
+}
+
+var stdout = bufio.NewWriter(os.Stdout)
+
+// startI3bar writes the protocol header and starts sending the click events
+// from stdin to clicks.
+func startI3bar(clicks chan<- click) {
+	header := struct {
+		Version     int  `json:"version"`
+		ClickEvents bool `json:"click_events"`
+	}{1, true}
+	b, err := json.Marshal(header)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(stdout, "%s\n[\n", b)
+	go readClicks(clicks)
+}
+
+func printBlocks(segments []segment) {
+	blocks := make([]block, len(segments))
+	for i, s := range segments {
+		blocks[i] = block{
+			Name:     s.name,
+			Instance: s.instance,
+			FullText: s.text,
+		}
+		if s.name != "barclock" {
+			continue
+		}
+		blocks[i].Color = zoneColor
+		if s.instance == "local" {
+			blocks[i].Color = localColor
+		}
+		if pangoMarkup {
+			blocks[i].Markup = "pango"
+		}
+	}
+	b, err := json.Marshal(blocks)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(stdout, "%s,\n", b)
+	if err := stdout.Flush(); err != nil {
+		log.Fatalln("Error writing to the bar:", err)
+	}
+}
+
+// readClicks reads the endless array of click events from stdin. The bar
+// writes each event on its own line.
+func readClicks(clicks chan<- click) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), "[, \t")
+		if line == "" {
+			continue
+		}
+		var cl click
+		if err := json.Unmarshal([]byte(line), &cl); err != nil {
+			log.Println("Bad click event:", err)
+			continue
+		}
+		if cl.Name == "barclock" {
+			clicks <- cl
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Println("Error reading click events:", err)
+	}
+}
+
+// handleClick carries out the action for a click: a left click runs the
+// -calendar command (or shows the built-in calendar), a middle click
+// toggles seconds, a right click cycles through the zones, and scrolling
+// moves the built-in calendar between months.
+func (c *clock) handleClick(cl click) {
+	switch cl.Button {
+	case 1:
+		if calendarCmd == "" {
+			goShowCalendar(0)
+		} else {
+			cmd := exec.Command("/bin/sh", "-c", calendarCmd)
+			// Stdout belongs to the bar.
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+			if err := cmd.Start(); err != nil {
+				log.Println("Error running -calendar command:", err)
+				return
+			}
+			go cmd.Wait()
+		}
+	case 2:
+		c.toggleSecs()
+	case 3:
+		c.cycleZones()
+	case 4:
+		goShowCalendar(-1)
+	case 5:
+		goShowCalendar(1)
+	}
+}
+
+// goShowCalendar calls showCalendar in the background (since notify-send
+// may take a moment).
+func goShowCalendar(step int) {
+	go func() {
+		if err := showCalendar(step); err != nil {
+			log.Println("Error showing the calendar:", err)
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A locale gives the names of months and weekdays in a language other than
+// English (which Go's time formatting uses).
+type locale struct {
+	Months      []string `toml:"months"`
+	ShortMonths []string `toml:"short_months"`
+	Days        []string `toml:"days"` // starting on Sunday
+	ShortDays   []string `toml:"short_days"`
+}
+
+// localesTOML has the built-in locales.
+//
+//go:embed locales.toml
+var localesTOML string
+
+// timeLocale is the locale of the month and weekday names, or nil for
+// English.
+var timeLocale *locale
+
+// findLocale gives the locale named name (such as de_DE.UTF-8) or, if name
+// is empty, the locale given by $LC_ALL, $LC_TIME, or $LANG. It returns nil
+// for English (and the C and POSIX locales).
+func findLocale(name string) (*locale, error) {
+	explicit := name != ""
+	for _, v := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if name != "" {
+			break
+		}
+		name = os.Getenv(v)
+	}
+	// Drop the codeset and modifier, as in de_DE.UTF-8@euro.
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	if name == "" || name == "C" || name == "POSIX" {
+		return nil, nil
+	}
+	lang, _, _ := strings.Cut(strings.ReplaceAll(name, "-", "_"), "_")
+	if lang == "en" {
+		return nil, nil
+	}
+	var locales map[string]*locale
+	if _, err := toml.Decode(localesTOML, &locales); err != nil {
+		panic(fmt.Sprintf("bad built-in locales.toml: %s", err))
+	}
+	if l, ok := locales[strings.ToLower(lang)]; ok {
+		return l, nil
+	}
+	if !explicit {
+		// Don't complain about an environment that's otherwise fine.
+		return nil, nil
+	}
+	var names []string
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("no month and weekday names for locale %q (have en, %s)", name, strings.Join(names, ", "))
+}
+
+func (l *locale) month(t time.Time) string      { return l.Months[t.Month()-1] }
+func (l *locale) shortMonth(t time.Time) string { return l.ShortMonths[t.Month()-1] }
+func (l *locale) day(t time.Time) string        { return l.Days[t.Weekday()] }
+func (l *locale) shortDay(t time.Time) string   { return l.ShortDays[t.Weekday()] }
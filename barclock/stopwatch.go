@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// A stopwatch is controlled by barclock stopwatch. It's shown next to the
+// clock from when it's started until it's reset.
+type stopwatch struct {
+	running bool
+	started time.Time     // when it was last started, if running
+	elapsed time.Duration // the time before it was last started
+	laps    []time.Duration
+}
+
+// cmdStopwatch implements barclock stopwatch.
+func cmdStopwatch(args []string) {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  barclock stopwatch start|stop|lap|reset
+
+The stopwatch command controls the stopwatch of the running barclock, which
+is shown next to the clock from when it's started until it's reset. The stop
+subcommand pauses it (start resumes it), lap prints the time of the lap (and
+shows it in the bar), and reset clears it.
+`)
+		os.Exit(2)
+	}
+	if err := sendControl(controlRequest{Cmd: "stopwatch", Action: args[0]}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (sw *stopwatch) total(now time.Time) time.Duration {
+	if !sw.running {
+		return sw.elapsed
+	}
+	return sw.elapsed + now.Sub(sw.started)
+}
+
+// do carries out the action, returning the output for barclock stopwatch.
+func (sw *stopwatch) do(action string, now time.Time) (string, error) {
+	switch action {
+	case "start":
+		if !sw.running {
+			sw.running = true
+			sw.started = now
+		}
+	case "stop":
+		if sw.running {
+			sw.elapsed = sw.total(now)
+			sw.running = false
+		}
+		return formatElapsed(sw.elapsed), nil
+	case "lap":
+		if !sw.running {
+			return "", fmt.Errorf("the stopwatch isn't running")
+		}
+		total := sw.total(now)
+		lap := total
+		if n := len(sw.laps); n > 0 {
+			lap -= sw.laps[n-1]
+		}
+		sw.laps = append(sw.laps, total)
+		return fmt.Sprintf("lap %d: %s (total %s)", len(sw.laps), formatElapsed(lap), formatElapsed(total)), nil
+	case "reset":
+		*sw = stopwatch{}
+	default:
+		return "", fmt.Errorf("unknown stopwatch action %q", action)
+	}
+	return "", nil
+}
+
+// shown reports whether the stopwatch is shown (that is, whether it has
+// been started since it was reset).
+func (sw *stopwatch) shown() bool {
+	return sw.running || sw.elapsed > 0
+}
+
+// segments gives the stopwatch's segment, if it's shown.
+func (sw *stopwatch) segments(now time.Time) []segment {
+	if !sw.shown() {
+		return nil
+	}
+	text := "stopwatch " + formatElapsed(sw.total(now))
+	if n := len(sw.laps); n > 0 {
+		lap := sw.laps[n-1]
+		if n > 1 {
+			lap -= sw.laps[n-2]
+		}
+		text += fmt.Sprintf(" (lap %d %s)", n, formatElapsed(lap))
+	}
+	if !sw.running {
+		text += " (stopped)"
+	}
+	return []segment{{"stopwatch", "stopwatch", text}}
+}
+
+// formatElapsed formats d (to the second) as m:ss or h:mm:ss.
+func formatElapsed(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
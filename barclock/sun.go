@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sunConfig is the [sun] section of the config file: the location for the
+// sun block and barclock sun.
+type sunConfig struct {
+	Latitude  *float64 `toml:"latitude"`  // degrees north
+	Longitude *float64 `toml:"longitude"` // degrees east
+}
+
+// sunCfg is the location, from the config file or -location.
+var sunCfg sunConfig
+
+// parseLocation parses -location, given as latitude,longitude (in degrees
+// north and east).
+func parseLocation(s string) error {
+	lat, long, ok := strings.Cut(s, ",")
+	if !ok {
+		return errors.New("location must be given as latitude,longitude")
+	}
+	var coords [2]float64
+	for i, v := range []string{lat, long} {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("bad coordinate %q", v)
+		}
+		coords[i] = f
+	}
+	sunCfg = sunConfig{Latitude: &coords[0], Longitude: &coords[1]}
+	return sunCfg.check()
+}
+
+func (c sunConfig) check() error {
+	if c.Latitude == nil && c.Longitude == nil {
+		return nil
+	}
+	if c.Latitude == nil || c.Longitude == nil ||
+		math.Abs(*c.Latitude) > 90 || math.Abs(*c.Longitude) > 180 {
+		return errors.New("the location needs a latitude from -90 to 90 and a longitude from -180 to 180")
+	}
+	return nil
+}
+
+// sunNear is how close to sunrise or sunset the sun block says how soon
+// (or how long ago) it is.
+const sunNear = 30 * time.Minute
+
+// readSun gives the text of the sun block: the times of today's sunrise and
+// sunset, with how soon (or how long ago) either is when it's within
+// sunNear.
+func readSun() (string, error) {
+	if sunCfg.Latitude == nil {
+		return "", errors.New("no location (see -location)")
+	}
+	now := time.Now()
+	rise, set, ok := sunTimes(now, *sunCfg.Latitude, *sunCfg.Longitude)
+	if !ok {
+		return "no sunrise or sunset today", nil
+	}
+	text := fmt.Sprintf("↑%s ↓%s", rise.Format("15:04"), set.Format("15:04"))
+	for _, e := range []struct {
+		name string
+		t    time.Time
+	}{{"sunrise", rise}, {"sunset", set}} {
+		d := e.t.Sub(now)
+		switch {
+		case d >= 0 && d <= sunNear:
+			text += fmt.Sprintf(" (%s in %dm)", e.name, int((d+time.Minute-1)/time.Minute))
+		case d < 0 && -d <= sunNear:
+			text += fmt.Sprintf(" (%s %dm ago)", e.name, int(-d/time.Minute))
+		}
+	}
+	return text, nil
+}
+
+// sunTimes gives the times of sunrise and sunset on the (local) day of t at
+// the given latitude and longitude, using the sunrise equation (see
+// https://en.wikipedia.org/wiki/Sunrise_equation), which is good to a few
+// minutes. It returns false if the sun doesn't rise or set that day (near the
+// poles).
+func sunTimes(t time.Time, lat, long float64) (rise, set time.Time, ok bool) {
+	const j2000 = 2451545.0
+	rad := math.Pi / 180
+	y, m, d := t.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, t.Location())
+	// The solar noon closest to local noon.
+	n := math.Round(julianDate(noon) - j2000 + long/360)
+	jstar := n - long/360
+	// The solar mean anomaly, the equation of the center, and the
+	// ecliptic longitude.
+	mean := math.Mod(357.5291+0.98560028*jstar, 360)
+	center := 1.9148*math.Sin(mean*rad) + 0.02*math.Sin(2*mean*rad) + 0.0003*math.Sin(3*mean*rad)
+	lambda := math.Mod(mean+center+180+102.9372, 360)
+	transit := j2000 + jstar + 0.0053*math.Sin(mean*rad) - 0.0069*math.Sin(2*lambda*rad)
+	// The declination of the sun and the hour angle of sunrise and sunset
+	// (when the top of the sun is at the horizon, allowing for refraction).
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(lat*rad)*sinDecl) / (math.Cos(lat*rad) * cosDecl)
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hour := math.Acos(cosHour) / rad
+	rise = fromJulianDate(transit-hour/360, t.Location())
+	set = fromJulianDate(transit+hour/360, t.Location())
+	return rise, set, true
+}
+
+func julianDate(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+func fromJulianDate(jd float64, loc *time.Location) time.Time {
+	return time.Unix(int64(math.Round((jd-2440587.5)*86400)), 0).In(loc)
+}
+
+// cmdSun implements barclock sun.
+func cmdSun(args []string) {
+	if len(args) > 1 || len(args) == 1 && args[0] != "sunrise" && args[0] != "sunset" {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  barclock [-location latitude,longitude] sun [sunrise|sunset]
+
+The sun command prints the times of today's sunrise and sunset (or just
+one of them) at the location given by -location or the config file, as
+HH:MM, which suits intelbacklight night -sunset and -sunrise.
+`)
+		os.Exit(2)
+	}
+	if sunCfg.Latitude == nil {
+		log.Fatal("No location (see -location)")
+	}
+	rise, set, ok := sunTimes(time.Now(), *sunCfg.Latitude, *sunCfg.Longitude)
+	if !ok {
+		log.Fatal("The sun doesn't rise and set today")
+	}
+	switch {
+	case len(args) == 0:
+		fmt.Printf("sunrise %s\nsunset %s\n", rise.Format("15:04"), set.Format("15:04"))
+	case args[0] == "sunrise":
+		fmt.Println(rise.Format("15:04"))
+	default:
+		fmt.Println(set.Format("15:04"))
+	}
+}
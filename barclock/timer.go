@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// onTimer is set by -on-timer.
+var onTimer string
+
+// A timer counts down to end, as started by barclock timer.
+type timer struct {
+	label string
+	end   time.Time
+}
+
+// cmdTimer implements barclock timer: it asks the running barclock to
+// start (or cancel) a timer.
+func cmdTimer(args []string) {
+	usage := func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  barclock timer duration [label]
+  barclock timer cancel
+
+The timer command starts a timer (such as 25m or 1h30m) in the running
+barclock, which counts down next to the clock and, when it runs out, runs
+the -on-timer command or sends a notification. The cancel subcommand
+cancels all the timers.
+`)
+		os.Exit(2)
+	}
+	var req controlRequest
+	switch {
+	case len(args) == 1 && args[0] == "cancel":
+		req.Cmd = "cancel-timers"
+	case len(args) == 1 || len(args) == 2:
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d <= 0 {
+			usage()
+		}
+		req = controlRequest{Cmd: "timer", Duration: d, Label: "timer"}
+		if len(args) == 2 {
+			req.Label = args[1]
+		}
+	default:
+		usage()
+	}
+	if err := sendControl(req); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// timerSegments gives the segments of the running timers, removing (and
+// firing) the ones that have run out.
+func (c *clock) timerSegments(now time.Time) []segment {
+	var segments []segment
+	running := c.timers[:0]
+	for _, t := range c.timers {
+		if !now.Before(t.end) {
+			t.fire()
+			continue
+		}
+		// Round up, so that the display doesn't reach 0 before the
+		// timer fires.
+		left := t.end.Sub(now)
+		if r := left.Truncate(time.Second); r < left {
+			left = r + time.Second
+		}
+		running = append(running, t)
+		segments = append(segments, segment{"timer", t.label, t.label + " " + formatElapsed(left)})
+	}
+	c.timers = running
+	return segments
+}
+
+// fire runs the -on-timer command (with the timer's label in
+// $BARCLOCK_TIMER) or, if there isn't one, sends a notification.
+func (t timer) fire() {
+	var cmd *exec.Cmd
+	if onTimer != "" {
+		cmd = exec.Command("/bin/sh", "-c", onTimer)
+		cmd.Env = append(os.Environ(), "BARCLOCK_TIMER="+t.label)
+	} else {
+		cmd = exec.Command("notify-send", "--app-name=barclock", "--urgency=critical", "Time's up", t.label)
+	}
+	// Stdout belongs to the bar.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Printf("Error running the command for timer %s: %s", t.label, err)
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// maxTitleLength is the length (in runes) beyond which the title block
+// truncates the title.
+const maxTitleLength = 60
+
+// watchTitle calls update with the title of the focused window whenever it
+// changes.
+func watchTitle(update func(string)) {
+	ctx := context.Background()
+	client, err := sway.New(ctx)
+	if err != nil {
+		log.Println("Error connecting to sway for the title block:", err)
+		return
+	}
+	h := &titleHandler{
+		EventHandler: sway.NoOpEventHandler(),
+		client:       client,
+		update:       update,
+	}
+	// The initial title is sent upon receiving the first tick event, which
+	// sway sends as soon as the subscription is established.
+	events := []sway.EventType{sway.EventTypeWindow, sway.EventTypeWorkspace, sway.EventTypeTick}
+	if err := sway.Subscribe(ctx, h, events...); err != nil {
+		log.Println("Error with subscription for the title block:", err)
+	}
+}
+
+type titleHandler struct {
+	client sway.Client
+	update func(string)
+	sway.EventHandler
+}
+
+func (h *titleHandler) send(title string) {
+	if r := []rune(title); len(r) > maxTitleLength {
+		title = string(r[:maxTitleLength]) + "…"
+	}
+	h.update(title)
+}
+
+func (h *titleHandler) Tick(ctx context.Context, e sway.TickEvent) {
+	if !e.First {
+		return
+	}
+	root, err := h.client.GetTree(ctx)
+	if err != nil {
+		log.Println("GET_TREE failed:", err)
+		return
+	}
+	if n := root.FocusedNode(); n != nil && n.Type != sway.NodeWorkspace {
+		h.send(n.Name)
+	}
+}
+
+func (h *titleHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
+	// Switching to an empty workspace doesn't generate a window event.
+	if e.Change != sway.WorkspaceFocus || e.Current == nil {
+		return
+	}
+	if len(e.Current.Nodes) == 0 && len(e.Current.FloatingNodes) == 0 {
+		h.send("")
+	}
+}
+
+func (h *titleHandler) Window(ctx context.Context, e sway.WindowEvent) {
+	switch e.Change {
+	case sway.WindowFocus, sway.WindowTitle:
+		if e.Container.Focused {
+			h.send(e.Container.Name)
+		}
+	case sway.WindowClose:
+		if e.Container.Focused {
+			h.send("")
+		}
+	}
+}
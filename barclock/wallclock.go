@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Go's timers run on the monotonic clock, which stops while the machine is
+// suspended and doesn't follow changes to the wall clock (as when NTP steps
+// it), so a sleep until the next minute can end well after the minute (or
+// before it). A wallTimer instead fires at a wall-clock time, using a
+// CLOCK_REALTIME timerfd, which fires on time after a suspend and is
+// canceled (waking barclock to reprint the time at once) when the wall
+// clock jumps.
+type wallTimer struct {
+	fd int
+	C  chan struct{}
+}
+
+func newWallTimer() (*wallTimer, error) {
+	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, unix.TFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	t := &wallTimer{fd: fd, C: make(chan struct{}, 1)}
+	go t.wait()
+	return t, nil
+}
+
+// reset makes t fire at the time at (or as soon as the wall clock jumps).
+func (t *wallTimer) reset(at time.Time) {
+	spec := unix.ItimerSpec{Value: unix.NsecToTimespec(at.UnixNano())}
+	flags := unix.TFD_TIMER_ABSTIME | unix.TFD_TIMER_CANCEL_ON_SET
+	if err := unix.TimerfdSettime(t.fd, flags, &spec, nil); err != nil {
+		log.Fatalln("Error setting timer:", err)
+	}
+}
+
+func (t *wallTimer) wait() {
+	buf := make([]byte, 8) // the number of expirations
+	for {
+		_, err := unix.Read(t.fd, buf)
+		switch err {
+		case nil, unix.ECANCELED: // fired or the clock jumped
+		case unix.EINTR:
+			continue
+		default:
+			log.Fatalln("Error reading timer:", err)
+		}
+		select {
+		case t.C <- struct{}{}:
+		default:
+		}
+	}
+}
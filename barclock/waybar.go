@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// printWaybar prints the segments as JSON for a waybar custom module (with
+// return-type json). The tooltip shows the full local date and week number
+// along with the time in each of the zones, even those that aren't shown
+// in the bar.
+func (c *clock) printWaybar(segments []segment) {
+	texts := make([]string, len(segments))
+	for i, s := range segments {
+		texts[i] = s.text
+	}
+	t := time.Now().Truncate(c.res)
+	_, week := t.ISOWeek()
+	lines := []string{
+		formatTime(t, "Monday, January 2, 2006"),
+		fmt.Sprintf("Week %d", week),
+	}
+	for _, z := range c.zones {
+		lines = append(lines, formatTime(z.in(t), "MST\tMon Jan 2 15:04"))
+	}
+	// Waybar interprets the text and tooltip as Pango markup.
+	text := strings.Join(texts, separator)
+	if !pangoMarkup {
+		text = html.EscapeString(text)
+	}
+	b, err := json.Marshal(struct {
+		Text    string `json:"text"`
+		Tooltip string `json:"tooltip"`
+	}{
+		Text:    text,
+		Tooltip: html.EscapeString(strings.Join(lines, "\n")),
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A zone is an additional time zone shown after the local time.
+type zone struct {
+	Label  string `toml:"label"`  // shown instead of the zone abbreviation
+	Name   string `toml:"zone"`   // IANA name, such as America/New_York
+	Offset bool   `toml:"offset"` // show the UTC offset instead of the abbreviation
+
+	loc *time.Location
+}
+
+func (z *zone) load() error {
+	loc, err := time.LoadLocation(z.Name)
+	if err != nil {
+		return fmt.Errorf("bad time zone %q: %s", z.Name, err)
+	}
+	z.loc = loc
+	return nil
+}
+
+// showOffsets is set by -offsets (or offsets in the config file).
+var showOffsets bool
+
+// in gives t in the zone. If the zone has a label, the label takes the place
+// of the zone abbreviation; otherwise, with -offsets (or if the zone's
+// Offset is set), the UTC offset does.
+func (z zone) in(t time.Time) time.Time {
+	t = t.In(z.loc)
+	switch {
+	case z.Label != "":
+		_, offset := t.Zone()
+		t = t.In(time.FixedZone(z.Label, offset))
+	case z.Offset || showOffsets:
+		t = withOffsetName(t)
+	}
+	return t
+}
+
+// withOffsetName gives t in a zone named for its UTC offset (such as +09:00),
+// since abbreviations such as CST are ambiguous.
+func withOffsetName(t time.Time) time.Time {
+	_, offset := t.Zone()
+	return t.In(time.FixedZone(t.Format("-07:00"), offset))
+}
+
+// zoneList is a flag.Value for a comma-separated list of zones, each an
+// IANA zone name optionally preceded by a label and =, as in
+// NYC=America/New_York,TOK=Asia/Tokyo.
+type zoneList []zone
+
+func (zl *zoneList) String() string {
+	var parts []string
+	for _, z := range *zl {
+		if z.Label == "" {
+			parts = append(parts, z.Name)
+		} else {
+			parts = append(parts, z.Label+"="+z.Name)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (zl *zoneList) Set(s string) error {
+	var zones zoneList
+	for _, part := range strings.Split(s, ",") {
+		var z zone
+		if label, name, ok := strings.Cut(part, "="); ok {
+			z = zone{Label: label, Name: name}
+		} else {
+			z = zone{Name: part}
+		}
+		if err := z.load(); err != nil {
+			return err
+		}
+		zones = append(zones, z)
+	}
+	*zl = zones
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// These are set by the threshold flags. Thresholds are in degrees Celsius;
+// 0 means unset.
+var (
+	warnThreshold float64
+	critThreshold float64
+	hysteresis    float64
+	onCrit        string
+)
+
+// Alert levels.
+const (
+	levelNormal = iota
+	levelWarn
+	levelCrit
+)
+
+var levelNames = []string{"normal", "warning", "critical"}
+
+// thresholdLevel gives the alert level of temperature t (in degrees Celsius)
+// ignoring hysteresis.
+func thresholdLevel(t float64) int {
+	switch {
+	case critThreshold > 0 && t >= critThreshold:
+		return levelCrit
+	case warnThreshold > 0 && t >= warnThreshold:
+		return levelWarn
+	}
+	return levelNormal
+}
+
+// exitForLevel exits with the alert level of temperature milli (in
+// millidegrees Celsius) as the status, if it isn't normal: 1 for warning
+// and 2 for critical. (Failures exit with exitError.)
+func exitForLevel(milli float64) {
+	if level := thresholdLevel(milli / 1000); level != levelNormal {
+		os.Exit(level)
+	}
+}
+
+// exitError is the status that cputemp exits with when it fails if a
+// threshold is set, so that a failure isn't mistaken for a warning or a
+// critical reading.
+const exitError = 3
+
+// fatal, fatalf, and fatalln are like log.Fatal, log.Fatalf, and
+// log.Fatalln, but exit with exitError if a threshold is set.
+func fatal(v ...any) {
+	log.Print(v...)
+	exitFailure(1)
+}
+
+func fatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	exitFailure(1)
+}
+
+func fatalln(v ...any) {
+	log.Println(v...)
+	exitFailure(1)
+}
+
+// exitFailure exits with status or, if a threshold is set, exitError.
+func exitFailure(status int) {
+	if warnThreshold > 0 || critThreshold > 0 {
+		os.Exit(exitError)
+	}
+	os.Exit(status)
+}
+
+// An alerter tracks the alert level of a series of readings. The level goes
+// up as soon as a threshold is reached but only goes down once the
+// temperature is more than hysteresis degrees below the threshold, so that
+// readings hovering around a threshold don't cause repeated alerts.
+type alerter struct {
+	level int
+}
+
+// update records a reading (in millidegrees Celsius), logging level changes
+// and running the -on-crit command when the level becomes critical.
+func (a *alerter) update(milli int64) {
+	t := float64(milli) / 1000
+	level := thresholdLevel(t)
+	if level < a.level {
+		level = thresholdLevel(t + hysteresis)
+		if level > a.level {
+			level = a.level
+		}
+	}
+	if level == a.level {
+		return
+	}
+	log.Printf("Temperature %s: %s", formatTemp(float64(milli), 0), levelNames[level])
+	if level == levelCrit && a.level < levelCrit && onCrit != "" {
+		runHook(onCrit, milli)
+	}
+	a.level = level
+}
+
+// runHook runs command (with /bin/sh -c) in the background, with the
+// temperature (in degrees Celsius) in $CPUTEMP_CELSIUS.
+func runHook(command string, milli int64) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("CPUTEMP_CELSIUS=%g", float64(milli)/1000))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Printf("Error running %q: %s", command, err)
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readBatteryTemp reads the temperature (in millidegrees Celsius) of the
+// first battery. The power_supply class reports it in tenths of a degree;
+// some batteries only report it through an hwmon chip named for the battery
+// instead.
+func readBatteryTemp() (int64, error) {
+	files, err := filepath.Glob("/sys/class/power_supply/BAT*/temp")
+	if err != nil {
+		return 0, err
+	}
+	if len(files) > 0 {
+		text, err := readFile(files[0])
+		if err != nil {
+			return 0, err
+		}
+		tenths, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return tenths * 100, nil
+	}
+	inputs, err := listTempInputs()
+	if err != nil {
+		return 0, err
+	}
+	for _, in := range inputs {
+		if strings.HasPrefix(in.Device, "BAT") {
+			return readMillidegrees(in.Path)
+		}
+	}
+	return 0, errors.New("no battery temperature sensor found")
+}
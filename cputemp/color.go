@@ -0,0 +1,59 @@
+package main
+
+// colorOutput is set by -color.
+var colorOutput bool
+
+// ANSI escape sequences for each alert level.
+var levelColors = []string{"\x1b[32m", "\x1b[33m", "\x1b[31m"}
+
+const colorReset = "\x1b[0m"
+
+// A colorizer tints printed temperatures according to thresholds (in
+// degrees Celsius; 0 means unset). A nil colorizer doesn't change anything.
+type colorizer struct {
+	warn, crit float64
+}
+
+// newColorizer returns a colorizer for readings of the sensor whose input
+// file is given by sensor ("" if it isn't an hwmon sensor), or nil if -color
+// isn't set or no thresholds are known. The -warn and -crit thresholds are
+// used if given; otherwise, they come from the sensor's sysfs max and crit
+// values.
+func newColorizer(sensor func() string) *colorizer {
+	if !colorOutput {
+		return nil
+	}
+	c := &colorizer{warn: warnThreshold, crit: critThreshold}
+	if c.warn == 0 || c.crit == 0 {
+		if path := sensor(); path != "" {
+			max, crit := sensorLimits(path)
+			if c.warn == 0 {
+				c.warn = float64(max) / 1000
+			}
+			if c.crit == 0 {
+				c.crit = float64(crit) / 1000
+			}
+		}
+	}
+	if c.warn == 0 && c.crit == 0 {
+		return nil
+	}
+	return c
+}
+
+// apply colors s, the formatted form of the temperature milli (in
+// millidegrees Celsius).
+func (c *colorizer) apply(s string, milli float64) string {
+	if c == nil {
+		return s
+	}
+	t := milli / 1000
+	level := levelNormal
+	switch {
+	case c.crit > 0 && t >= c.crit:
+		level = levelCrit
+	case c.warn > 0 && t >= c.warn:
+		level = levelWarn
+	}
+	return levelColors[level] + s + colorReset
+}
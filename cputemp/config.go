@@ -0,0 +1,146 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is the (optional) cputemp configuration file, which lives at
+// $XDG_CONFIG_HOME/cputemp/config.toml. For example:
+//
+//	[[sensors]]
+//	device = "k10temp"
+//	label = "Tdie"
+//	priority = 10
+//
+//	[[sensors]]
+//	device = "zenpower"
+//	label = "Tdie"
+//
+//	[[names]]
+//	device = "it8686"
+//	label = "temp2"
+//	name = "VRM"
+type config struct {
+	// Sensors are merged with the built-in list of known CPU sensors (see
+	// sensorPreferences).
+	Sensors []sensorConfig `toml:"sensors"`
+
+	// Names give names to motherboard sensors, which often aren't labeled
+	// (or are labeled unhelpfully) by their Super I/O chip driver.
+	Names []nameConfig `toml:"names"`
+}
+
+type nameConfig struct {
+	Device string `toml:"device"` // hwmon chip name
+	Label  string `toml:"label"`  // temp*_label contents (or tempN)
+	Name   string `toml:"name"`
+}
+
+// sensorName returns the configured name of the sensor in, or "" if it has
+// none.
+func (cfg *config) sensorName(in *tempInput) string {
+	for _, n := range cfg.Names {
+		if n.Device == in.Device && n.Label == in.Label {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+type sensorConfig struct {
+	Device   string `toml:"device"` // hwmon chip name
+	Label    string `toml:"label"`  // temp*_label contents (or tempN)
+	Priority int    `toml:"priority"`
+	Disable  bool   `toml:"disable"` // remove a built-in sensor
+}
+
+// defaultSensorsTOML is the built-in list of known CPU sensors, in the same
+// format as the config file.
+//
+//go:embed sensors.toml
+var defaultSensorsTOML string
+
+// sensorPreferences gives the CPU sensors to try, in order. These are the
+// built-in sensors merged with those in cfg: a configured sensor replaces
+// the built-in one with the same device and label (or removes it, if
+// Disable is set). Sensors are ordered by decreasing priority; among those
+// with equal priority, the configured sensors come first, followed by the
+// built-in ones in the order they're listed.
+func sensorPreferences(cfg *config) []sensorConfig {
+	var defaults config
+	if _, err := toml.Decode(defaultSensorsTOML, &defaults); err != nil {
+		panic(fmt.Sprintf("bad built-in sensors.toml: %s", err))
+	}
+	type key struct{ device, label string }
+	configured := make(map[key]bool)
+	var sensors []sensorConfig
+	for _, s := range cfg.Sensors {
+		configured[key{s.Device, s.Label}] = true
+		if !s.Disable {
+			sensors = append(sensors, s)
+		}
+	}
+	for _, s := range defaults.Sensors {
+		if !configured[key{s.Device, s.Label}] {
+			sensors = append(sensors, s)
+		}
+	}
+	sort.SliceStable(sensors, func(i, j int) bool {
+		return sensors[i].Priority > sensors[j].Priority
+	})
+	return sensors
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error establishing config dir: %s", err)
+	}
+	return filepath.Join(dir, "cputemp", "config.toml"), nil
+}
+
+// configIdentity identifies the current version of the config file by its
+// modification time and size, or gives "none" if there is no config file.
+func configIdentity() string {
+	path, err := configPath()
+	if err != nil {
+		return "none"
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%d", fi.ModTime().UnixNano(), fi.Size())
+}
+
+func readConfig() (*config, error) {
+	cfg := new(config)
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %s", path, err)
+	}
+	for _, s := range cfg.Sensors {
+		if s.Device == "" || s.Label == "" {
+			return nil, fmt.Errorf("config file %s: each sensor needs a device and a label", path)
+		}
+	}
+	for _, n := range cfg.Names {
+		if n.Device == "" || n.Label == "" || n.Name == "" {
+			return nil, fmt.Errorf("config file %s: each name needs a device, a label, and a name", path)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// printCores prints the minimum, average, and maximum of all the sensors on
+// the CPU's hwmon chip (such as each core for coretemp or each CCD for
+// k10temp). If each is set, it first prints every sensor's reading.
+func printCores(each bool) {
+	chip := filepath.Dir(cpuTempFile())
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var n int
+	var sum, min, max int64
+	for _, in := range inputs {
+		dir, err := filepath.EvalSymlinks(in.Chip)
+		if err != nil || dir != chip {
+			continue
+		}
+		temp, err := readMillidegrees(in.Path)
+		if err != nil {
+			fatalln("Error reading temperature file:", err)
+		}
+		t := temp
+		if each {
+			fmt.Fprintf(tw, "%s\t%s\n", in.Label, formatTemp(float64(t), 0))
+		}
+		if n == 0 || t < min {
+			min = t
+		}
+		if n == 0 || t > max {
+			max = t
+		}
+		sum += t
+		n++
+	}
+	tw.Flush()
+	if n == 0 {
+		fatalf("No sensors found on CPU chip %s", chip)
+	}
+	avg := float64(sum) / float64(n)
+	fmt.Printf("min %s  avg %s  max %s\n", formatTemp(float64(min), 0), formatTemp(avg, 0), formatTemp(float64(max), 0))
+}
@@ -3,57 +3,497 @@ package main
 import (
 	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 )
 
 func main() {
 	log.SetFlags(0)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "discover":
+			cmdDiscover(os.Args[2:])
+			return
+		case "query":
+			cmdQuery(os.Args[2:])
+			return
+		}
+	}
+	all := flag.Bool("all", false, "List every hwmon temperature sensor")
+	cores := flag.Bool("cores", false, "Print the min/avg/max of all the CPU chip's sensors")
+	perCore := flag.Bool("per-core", false, "With -cores, also print each sensor")
+	battery := flag.Bool("battery", false, "Print the battery temperature instead")
+	summary := flag.Bool("summary", false, "Print the CPU, GPU, drive, and chipset temperatures together")
+	flag.DurationVar(&readTimeout, "read-timeout", readTimeout, "Give up on reading a sensor (when listing sensors, and with -all or -summary) after `duration`")
+	jsonOutput := flag.Bool("json", false, "With -summary, print JSON")
+	drives := flag.Bool("drives", false, "Print the temperature of each NVMe and SATA drive")
+	var gpu gpuFlag
+	flag.Var(&gpu, "gpu", "Print the GPU temperature instead (`type` may be auto, amd, or nvidia)")
+	flag.StringVar(&cpuSensor.Device, "device", "", "Use the CPU sensor on the hwmon chip with this `name` (bypassing the cache)")
+	flag.StringVar(&cpuSensor.Label, "label", "", "With -device, use the sensor with this `label` (as listed by -all)")
+	format := flag.String("format", "", "Format the temperature with the Go `template` (see the README for the fields)")
+	freq := flag.Bool("freq", false, "Also print the average CPU frequency (and whether the CPU is at its thermal limit)")
+	flag.BoolVar(&fahrenheit, "f", false, "Print temperatures in degrees Fahrenheit")
+	flag.Func("precision", "Number of decimal `places` (default 0, or 1 for -all)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return errors.New("must be a non-negative integer")
+		}
+		precision = n
+		return nil
+	})
+	flag.BoolVar(&colorOutput, "color", false, "Color temperatures green, yellow, or red according to the thresholds (-warn and -crit, or else the sensor's own)")
+	pct := flag.Bool("pct", false, "Print the temperature as a percentage of the critical temperature (-crit, or else the sensor's own)")
+	flag.BoolVar(&rawOutput, "raw", false, "Print temperatures as integer millidegrees Celsius (as in sysfs)")
+	var watchInterval intervalFlag
+	flag.Var(&watchInterval, "watch", "Print the temperature every `interval` (default 1s)")
+	flag.IntVar(&smoothSamples, "smooth", 0, "With -watch or -daemon, smooth readings over about `n` samples (exponential moving average)")
+	inline := flag.Bool("inline", false, "With -watch, overwrite the previous reading instead of printing lines")
+	var daemonInterval intervalFlag
+	flag.Var(&daemonInterval, "daemon", "Run a daemon that samples the sensors every `interval` (default 1s)")
+	flag.Float64Var(&warnThreshold, "warn", 0, "Warning threshold (degrees Celsius)")
+	flag.Float64Var(&critThreshold, "crit", 0, "Critical threshold (degrees Celsius)")
+	flag.Float64Var(&hysteresis, "hysteresis", 3, "With -watch or -daemon, degrees below a threshold the temperature must drop to clear it")
+	flag.StringVar(&onCrit, "on-crit", "", "With -watch or -daemon, run `command` (with /bin/sh -c) when the critical threshold is reached")
+	lenient := flag.Bool("lenient", false, "If there is no sensor to read (as in a VM), print the -placeholder and exit successfully")
+	placeholder := flag.String("placeholder", "n/a", "With -lenient, the `text` to print when there is no sensor")
+	history := flag.Duration("history", 0, "Print a sparkline of the CPU temperature over the past `duration` (recorded by the daemon)")
+	logFile := flag.String("log", "", "Append a CSV row with the CPU and GPU temperatures to `file` periodically (see also cputemp query)")
+	logInterval := flag.Duration("log-interval", time.Minute, "With -log, the `interval` between samples")
+	retention := flag.Duration("retention", 30*24*time.Hour, "With -log, remove samples older than `duration` (0 to keep them all)")
+	exporterAddr := flag.String("exporter", "", "Serve Prometheus metrics for all sensors at `addr` (such as :9111)")
+	// Bad flags exit with status 2, or exitError with a threshold, so that
+	// they aren't mistaken for a critical reading.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	parseFlags := func(args []string) {
+		switch err := flag.CommandLine.Parse(args); {
+		case err == flag.ErrHelp:
+			os.Exit(0)
+		case err != nil:
+			exitFailure(2)
+		}
+	}
+	parseFlags(os.Args[1:])
+
+	// Allow "-gpu nvidia" and "-watch 5s" (and so on) as well as
+	// "-gpu=nvidia" and "-watch=5s". (Since flag parsing stops at the first
+	// non-flag argument, parse again after each such value.)
+	for flag.NArg() > 0 {
+		arg := flag.Arg(0)
+		switch {
+		case gpu != "" && gpu.Set(arg) == nil:
+		case watchInterval != 0 && watchInterval.Set(arg) == nil:
+		case daemonInterval != 0 && daemonInterval.Set(arg) == nil:
+		default:
+			fatalf("Unexpected argument %q", arg)
+		}
+		parseFlags(flag.Args()[1:])
+	}
+
+	if cpuSensor.Label != "" && cpuSensor.Device == "" {
+		fatal("-label requires -device")
+	}
+	if cpuSensor.Device != "" && gpu != "" {
+		fatal("-device cannot be used with -gpu")
+	}
+	if *battery && (gpu != "" || cpuSensor.Device != "") {
+		fatal("-battery cannot be used with -gpu or -device")
+	}
+	if *freq && (gpu != "" || *battery) {
+		fatal("-freq cannot be used with -gpu or -battery")
+	}
+
+	if *history > 0 {
+		printHistory(*history)
+		return
+	}
+	// -log, -exporter, and -daemon each run forever; any combination of
+	// them runs together.
+	var services []func()
+	if *logFile != "" {
+		if *logInterval <= 0 {
+			fatal("-log-interval must be positive")
+		}
+		services = append(services, func() { runLogger(*logFile, *logInterval, *retention) })
+	}
+	if *exporterAddr != "" {
+		services = append(services, func() { runExporter(*exporterAddr) })
+	}
+	if daemonInterval != 0 {
+		services = append(services, func() { runDaemon(time.Duration(daemonInterval)) })
+	}
+	if len(services) > 0 {
+		for _, run := range services[1:] {
+			go run()
+		}
+		services[0]()
+		return
+	}
+	if *all {
+		listAll()
+		return
+	}
+	if *summary {
+		printSummary(*jsonOutput)
+		return
+	}
+	if *drives {
+		listDrives()
+		return
+	}
+	if *cores {
+		printCores(*perCore)
+		return
+	}
+
+	if *lenient && !sensorAvailable(gpu, *battery) {
+		fmt.Println(*placeholder)
+		return
+	}
+
+	// sensor gives the hwmon input file of the sensor being read, if
+	// there is one, for its max and crit values.
+	sensor := func() string { return sensorFile(gpu) }
+	if *battery {
+		sensor = func() string { return "" }
+	}
+	col := newColorizer(sensor)
+	show := func(milli float64) string { return col.apply(formatTemp(milli, 0), milli) }
+	if *pct {
+		crit := critTemp(sensor)
+		if crit <= 0 {
+			fatal("The sensor has no critical temperature; use -crit to set one")
+		}
+		show = func(milli float64) string { return col.apply(formatPercent(milli, crit), milli) }
+	}
+	if *format != "" {
+		tmpl, err := template.New("format").Parse(*format)
+		if err != nil {
+			fatalln("Bad -format template:", err)
+		}
+		f := &templateFormatter{tmpl: tmpl, sensor: sensor, other: "nvidia"}
+		if *battery {
+			f.other = "battery"
+		}
+		show = func(milli float64) string { return col.apply(f.format(milli), milli) }
+	}
+	if *freq {
+		show = withFreq(show, critTemp(sensor))
+	}
+
+	// If the daemon is running, it has the reading already.
+	if watchInterval == 0 && cpuSensor.Device == "" && !*battery {
+		if temp, ok := daemonTemp(gpu); ok {
+			fmt.Println(show(temp))
+			exitForLevel(temp)
+			return
+		}
+	}
+
+	if gpu == "auto" {
+		gpu = "nvidia"
+		if hasAMDGPU() {
+			gpu = "amd"
+		}
+	}
+	if *battery {
+		if watchInterval != 0 {
+			watch(readBatteryTemp, show, time.Duration(watchInterval), *inline)
+		}
+		temp, err := readBatteryTemp()
+		if err != nil {
+			fatalln("Error reading battery temperature:", err)
+		}
+		fmt.Println(show(float64(temp)))
+		exitForLevel(float64(temp))
+		return
+	}
+	if watchInterval != 0 {
+		watch(tempReader(gpu), show, time.Duration(watchInterval), *inline)
+	}
+
+	var temp int64
+	switch gpu {
+	case "":
+		if cpuSensor.Device != "" {
+			var err error
+			temp, err = readMillidegrees(cpuTempFile())
+			if err != nil {
+				fatalln("Error reading temperature file:", err)
+			}
+			break
+		}
+		temp = readCachedTemp("cpu_temp", findTempFile)
+	case "amd":
+		temp = readCachedTemp("gpu_temp", findGPUTempFile)
+	case "nvidia":
+		var err error
+		temp, err = readNVIDIATemp()
+		if err != nil {
+			fatalln("Error reading NVIDIA GPU temperature:", err)
+		}
+	}
+	fmt.Println(show(float64(temp)))
+	exitForLevel(float64(temp))
+}
+
+// tempReader returns a function that reads the CPU temperature or, if gpu is
+// set, the GPU temperature. The sensor is located once, up front.
+func tempReader(gpu gpuFlag) func() (int64, error) {
+	var path string
+	switch gpu {
+	case "":
+		path = cpuTempFile()
+	case "amd":
+		path = cachedTempFile("gpu_temp", findGPUTempFile)
+	case "nvidia":
+		return readNVIDIATemp
+	}
+	return func() (int64, error) { return readMillidegrees(path) }
+}
+
+// sensorAvailable reports whether there is a sensor to read for the CPU or,
+// if gpu or battery is set, the GPU or battery temperature. In the common
+// case of a cached sensor, this only costs a read of the cached file.
+func sensorAvailable(gpu gpuFlag, battery bool) bool {
+	available := func(name string, find func() (string, error)) bool {
+		if dir, err := os.UserCacheDir(); err == nil {
+			if _, err := readFile(filepath.Join(dir, "cputemp", name)); err == nil {
+				return true
+			}
+		}
+		_, err := find()
+		return err == nil
+	}
+	switch {
+	case battery:
+		_, err := readBatteryTemp()
+		return err == nil
+	case gpu == "" && cpuSensor.Device != "":
+		_, err := resolveTempFile(cpuSensor.Device, cpuSensor.Label)
+		return err == nil
+	case gpu == "":
+		return available("cpu_temp", findTempFile)
+	case gpu == "amd" || gpu == "auto" && hasAMDGPU():
+		return available("gpu_temp", findGPUTempFile)
+	}
+	_, err := readNVIDIATemp()
+	return err == nil
+}
+
+// critTemp returns the critical temperature (in millidegrees Celsius) of the
+// sensor whose input file is given by sensor: the -crit threshold, if set,
+// or else the sensor's own. It returns 0 if neither is known.
+func critTemp(sensor func() string) float64 {
+	if critThreshold > 0 {
+		return critThreshold * 1000
+	}
+	if path := sensor(); path != "" {
+		_, crit := sensorLimits(path)
+		return float64(crit)
+	}
+	return 0
+}
+
+// sensorFile returns the input file of the CPU sensor or, if gpu is set,
+// the AMD GPU sensor. It returns "" for an NVIDIA GPU (which isn't read
+// through hwmon).
+func sensorFile(gpu gpuFlag) string {
+	switch {
+	case gpu == "":
+		return cpuTempFile()
+	case gpu == "amd" || gpu == "auto" && hasAMDGPU():
+		return cachedTempFile("gpu_temp", findGPUTempFile)
+	}
+	return ""
+}
+
+// cpuSensor is the CPU sensor selected with -device and -label, if any.
+var cpuSensor sensorConfig
+
+// cpuTempFile returns the input file of the CPU sensor: the one selected
+// by cpuSensor, if set, or else the cached one.
+func cpuTempFile() string {
+	if cpuSensor.Device == "" {
+		return cachedTempFile("cpu_temp", findTempFile)
+	}
+	path, err := resolveTempFile(cpuSensor.Device, cpuSensor.Label)
+	if err != nil {
+		fatalln("Error locating selected temperature file:", err)
+	}
+	return path
+}
+
+// cachedTempFile is like readCachedTemp but returns the (resolved) path of
+// the sensor file instead of reading it.
+func cachedTempFile(name string, find func() (string, error)) string {
+	symlink, _ := readCachedTempFile(name, find)
+	path, err := filepath.EvalSymlinks(symlink)
+	if err != nil {
+		fatalln("Error resolving temperature file:", err)
+	}
+	return path
+}
+
+// readCachedTemp reads a temperature (in millidegrees Celsius) using the
+// sensor file cached under the given name. If there is no cached file, or
+// the cached file is no longer the same sensor (hwmon numbering can change
+// across reboots), it uses find to locate one and caches that.
+func readCachedTemp(name string, find func() (string, error)) int64 {
+	symlink, tempText := readCachedTempFile(name, find)
+
+	temp, err := strconv.ParseInt(tempText, 10, 64)
+	if err != nil {
+		fatalf("Error parsing contents of %s as an integer: %s", symlink, tempText)
+	}
+	return temp
+}
+
+// readCachedTempFile is like readCachedTemp but returns the path of the
+// cache symlink and the unparsed contents of the sensor file.
+func readCachedTempFile(name string, find func() (string, error)) (symlink, tempText string) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		log.Fatalln("Error establishing cache dir:", err)
+		fatalln("Error establishing cache dir:", err)
 	}
-	symlink := filepath.Join(cacheDir, "cputemp", "cpu_temp")
+	symlink = filepath.Join(cacheDir, "cputemp", name)
+	// Alongside the symlink, we record the identity of the sensor it
+	// points to and of the config file it was chosen under.
+	idFile := symlink + ".sensor"
 	tempText, readErr := readFile(symlink)
+	if readErr == nil && !cachedSensorValid(symlink, idFile) {
+		readErr = os.ErrNotExist
+	}
 	if errors.Is(readErr, os.ErrNotExist) {
-		file, err := findTempFile()
+		file, err := find()
 		if err != nil {
-			log.Fatalln("Error locating correct temperature file:", err)
+			fatalln("Error locating correct temperature file:", err)
 		}
 		if err := os.MkdirAll(filepath.Dir(symlink), 0o755); err != nil {
-			log.Fatalln("Error creating cache dir:", err)
+			fatalln("Error creating cache dir:", err)
 		}
 		os.Remove(symlink) // best-effort
 		if err := os.Symlink(file, symlink); err != nil {
-			log.Fatalf("Error writing cache symlink %s->%s: %s", file, symlink, err)
+			fatalf("Error writing cache symlink %s->%s: %s", file, symlink, err)
+		}
+		id, err := sensorIdentity(file)
+		if err != nil {
+			fatalln("Error reading sensor identity:", err)
+		}
+		if err := os.WriteFile(idFile, []byte(cacheRecord(id)), 0o644); err != nil {
+			fatalln("Error writing cache file:", err)
 		}
 		tempText, readErr = readFile(symlink)
 	}
 	if readErr != nil {
-		log.Fatalln("Error reading temperature file:", readErr)
+		fatalln("Error reading temperature file:", readErr)
+	}
+	return symlink, tempText
+}
+
+// cachedSensorValid reports whether the sensor that symlink points to
+// and the config file match the identities recorded in idFile.
+func cachedSensorValid(symlink, idFile string) bool {
+	want, err := readFile(idFile)
+	if err != nil {
+		return false
+	}
+	file, err := filepath.EvalSymlinks(symlink)
+	if err != nil {
+		return false
 	}
+	id, err := sensorIdentity(file)
+	return err == nil && cacheRecord(id) == want+"\n"
+}
 
-	temp, err := strconv.ParseInt(tempText, 10, 64)
+// cacheRecord gives the contents of the file recorded alongside a cache
+// symlink to the sensor identified by sensorID: that identity and the
+// config file's, as in
+//
+//	k10temp/Tctl
+//	config 1700000000000000000/123
+//
+// The sensors to try come from the config file, so the cached choice is
+// stale once the config changes.
+func cacheRecord(sensorID string) string {
+	return sensorID + "\nconfig " + configIdentity() + "\n"
+}
+
+// sensorIdentity identifies the sensor whose input file is path by its chip
+// name and label, as in "k10temp/Tctl".
+func sensorIdentity(path string) (string, error) {
+	name, err := readFile(filepath.Join(filepath.Dir(path), "name"))
 	if err != nil {
-		log.Fatalf("Error parsing contents of %s as an integer: %s", symlink, tempText)
+		return "", err
 	}
-	fmt.Println(math.Round(float64(temp) / 1000))
+	base := strings.TrimSuffix(path, "_input")
+	label, err := readFile(base + "_label")
+	if errors.Is(err, os.ErrNotExist) {
+		label = filepath.Base(base)
+	} else if err != nil {
+		return "", err
+	}
+	return name + "/" + label, nil
+}
+
+func listAll() {
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		fatal(err)
+	}
+	reads := make([]func() (int64, error), len(inputs))
+	for i, in := range inputs {
+		path := in.Path
+		reads[i] = func() (int64, error) { return readMillidegrees(path) }
+	}
+	results := readParallel(reads)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, in := range inputs {
+		value := "?"
+		if results[i].err == nil {
+			value = formatTemp(float64(results[i].val), 1)
+		}
+		var limits []string
+		if in.Max != 0 {
+			limits = append(limits, "max "+formatTemp(float64(in.Max), 1))
+		}
+		if in.Crit != 0 {
+			limits = append(limits, "crit "+formatTemp(float64(in.Crit), 1))
+		}
+		label := in.Label
+		if name := cfg.sensorName(&in); name != "" {
+			label = fmt.Sprintf("%s (%s)", name, in.Label)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", filepath.Base(in.Chip), in.Device, label, value, strings.Join(limits, ", "))
+	}
+	tw.Flush()
 }
 
 func findTempFile() (string, error) {
-	for _, opt := range []struct {
-		deviceName string
-		label      string
-	}{
-		{deviceName: "k10temp", label: "Tctl"},          // AMD Ryzen 9 3900X
-		{deviceName: "coretemp", label: "Package id 0"}, // Intel Core i7-8565U
-	} {
-		path, err := resolveTempFile(opt.deviceName, opt.label)
+	cfg, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+	return findFirstTempFile(sensorPreferences(cfg))
+}
+
+// findFirstTempFile returns the input file of the first of sensors that
+// exists.
+func findFirstTempFile(sensors []sensorConfig) (string, error) {
+	for _, opt := range sensors {
+		path, err := resolveTempFile(opt.Device, opt.Label)
 		if errors.Is(err, errTempFileNotFound) {
 			continue
 		}
@@ -67,42 +507,35 @@ func findTempFile() (string, error) {
 
 var errTempFileNotFound = errors.New("temp file not found")
 
+// resolveTempFile finds the input file of the sensor with the given label
+// on an hwmon chip with the given name. (There may be several chips with
+// the same name, as with coretemp on a machine with multiple CPU packages.)
+// If label is empty, the chip must have exactly one temperature sensor.
 func resolveTempFile(deviceName, label string) (string, error) {
-	dirs, err := filepath.Glob("/sys/class/hwmon/hwmon*")
+	inputs, err := listTempInputs()
 	if err != nil {
 		return "", err
 	}
-	var dir string
-	for _, d := range dirs {
-		name, err := readFile(filepath.Join(d, "name"))
-		if errors.Is(err, os.ErrNotExist) {
+	var found bool
+	var matches []string
+	for _, in := range inputs {
+		if in.Device != deviceName {
 			continue
 		}
-		if err != nil {
-			return "", err
-		}
-		if name == deviceName {
-			dir = d
-			break
+		found = true
+		if label == "" || in.Label == label {
+			matches = append(matches, in.Path)
 		}
 	}
-	if dir == "" {
-		return "", errTempFileNotFound
-	}
-	labels, err := filepath.Glob(filepath.Join(dir, "temp*_label"))
-	if err != nil {
-		return "", err
-	}
-	for _, f := range labels {
-		l, err := readFile(f)
-		if err != nil {
-			return "", err
-		}
-		if l == label {
-			return filepath.EvalSymlinks(strings.TrimSuffix(f, "_label") + "_input")
-		}
+	switch {
+	case !found:
+		return "", fmt.Errorf("%w: no device %q", errTempFileNotFound, deviceName)
+	case label == "" && len(matches) != 1:
+		return "", fmt.Errorf("device %q has %d temp files; select one with a label", deviceName, len(matches))
+	case len(matches) == 0:
+		return "", fmt.Errorf("%w: no temp file labeled %q located for device %q", errTempFileNotFound, label, deviceName)
 	}
-	return "", fmt.Errorf("no temp file labeled %q located for device %q", label, deviceName)
+	return filepath.EvalSymlinks(matches[0])
 }
 
 func readFile(p string) (string, error) {
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// The -log file is a CSV file with a header row followed by one row per
+// sample: the time (RFC 3339) and the CPU and GPU temperatures in degrees
+// Celsius (the GPU column is empty if there is no GPU).
+var csvLogHeader = []string{"time", "cpu", "gpu"}
+
+// runLogger appends a sample to the CSV file at path every interval,
+// removing samples older than retention (if positive) at startup and once
+// a day after that.
+func runLogger(path string, interval, retention time.Duration) {
+	readCPU := tempReader("")
+	var readGPU func() (int64, error)
+	if gpuType := detectGPU(); gpuType != "" {
+		readGPU = tempReader(gpuType)
+	}
+
+	var f *os.File
+	var w *csv.Writer
+	open := func() {
+		if retention > 0 {
+			if err := pruneCSVLog(path, time.Now().Add(-retention)); err != nil {
+				fatalln("Error pruning log file:", err)
+			}
+		}
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			fatalln("Error opening log file:", err)
+		}
+		w = csv.NewWriter(f)
+		if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+			w.Write(csvLogHeader)
+		}
+	}
+	open()
+	lastPrune := time.Now()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		row := []string{now.Format(time.RFC3339), "", ""}
+		if temp, err := readCPU(); err == nil {
+			row[1] = formatCelsius(temp)
+		} else {
+			log.Println("Error reading CPU temperature:", err)
+		}
+		if readGPU != nil {
+			if temp, err := readGPU(); err == nil {
+				row[2] = formatCelsius(temp)
+			} else {
+				log.Println("Error reading GPU temperature:", err)
+			}
+		}
+		w.Write(row)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			fatalln("Error writing log file:", err)
+		}
+		if retention > 0 && now.Sub(lastPrune) > 24*time.Hour {
+			f.Close()
+			open()
+			lastPrune = now
+		}
+		<-ticker.C
+	}
+}
+
+func formatCelsius(milli int64) string {
+	return strconv.FormatFloat(float64(milli)/1000, 'f', 1, 64)
+}
+
+// A csvLogRow is a parsed row of the log file. Temperatures are in
+// millidegrees Celsius; NaN means there was no reading.
+type csvLogRow struct {
+	time     time.Time
+	cpu, gpu float64
+}
+
+// readCSVLog calls fn for each row of the log file at path.
+func readCSVLog(path string, fn func(row csvLogRow)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = len(csvLogHeader)
+	for i := 0; ; i++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if i == 0 && rec[0] == csvLogHeader[0] {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, rec[0])
+		if err != nil {
+			return fmt.Errorf("line %d: bad time %q", i+1, rec[0])
+		}
+		row := csvLogRow{time: t}
+		for j, v := range []*float64{&row.cpu, &row.gpu} {
+			*v = math.NaN()
+			if s := rec[j+1]; s != "" {
+				c, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return fmt.Errorf("line %d: bad temperature %q", i+1, s)
+				}
+				*v = c * 1000
+			}
+		}
+		fn(row)
+	}
+}
+
+// pruneCSVLog rewrites the log file at path without the rows before cutoff.
+func pruneCSVLog(path string, cutoff time.Time) error {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	// CreateTemp makes the file 0600; keep the log's own mode.
+	if err := out.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	r := csv.NewReader(bufio.NewReader(in))
+	w := csv.NewWriter(out)
+	w.Write(csvLogHeader)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339, rec[0])
+		if err != nil || t.Before(cutoff) {
+			continue // also skips the header
+		}
+		w.Write(rec)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), path)
+}
+
+// cmdQuery implements "cputemp query", which summarizes a -log file.
+func cmdQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	since := fs.Duration("since", 24*time.Hour, "Only include samples from the past `duration`")
+	bucket := fs.Duration("bucket", time.Hour, "Summarize samples in buckets of `duration`")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  cputemp query [-since duration] [-bucket duration] file.csv
+
+The query command prints the average and maximum CPU and GPU temperatures
+in each time bucket of a log file written by cputemp -log.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *bucket <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	type stats struct {
+		start          time.Time
+		cpuN, gpuN     int
+		cpuSum, gpuSum float64
+		cpuMax, gpuMax float64
+	}
+	var buckets []*stats
+	cutoff := time.Now().Add(-*since)
+	err := readCSVLog(fs.Arg(0), func(row csvLogRow) {
+		if row.time.Before(cutoff) {
+			return
+		}
+		start := row.time.Truncate(*bucket)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].start.Equal(start) {
+			buckets = append(buckets, &stats{start: start})
+		}
+		b := buckets[len(buckets)-1]
+		if !math.IsNaN(row.cpu) {
+			if b.cpuN == 0 || row.cpu > b.cpuMax {
+				b.cpuMax = row.cpu
+			}
+			b.cpuSum += row.cpu
+			b.cpuN++
+		}
+		if !math.IsNaN(row.gpu) {
+			if b.gpuN == 0 || row.gpu > b.gpuMax {
+				b.gpuMax = row.gpu
+			}
+			b.gpuSum += row.gpu
+			b.gpuN++
+		}
+	})
+	if err != nil {
+		fatalln("Error reading log file:", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "time\tcpu avg\tcpu max\tgpu avg\tgpu max")
+	format := func(sum, max float64, n int) (string, string) {
+		if n == 0 {
+			return "-", "-"
+		}
+		return formatTemp(sum/float64(n), 1), formatTemp(max, 1)
+	}
+	for _, b := range buckets {
+		cpuAvg, cpuMax := format(b.cpuSum, b.cpuMax, b.cpuN)
+		gpuAvg, gpuMax := format(b.gpuSum, b.gpuMax, b.gpuN)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.start.Local().Format("2006-01-02 15:04"), cpuAvg, cpuMax, gpuAvg, gpuMax)
+	}
+	tw.Flush()
+}
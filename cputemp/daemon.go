@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A snapshot is the set of readings that the daemon serves. Temperatures are
+// in millidegrees Celsius. The smoothed values are exponential moving
+// averages (see -smooth); without smoothing, they equal the raw readings.
+type snapshot struct {
+	Time        time.Time       `json:"time"`
+	CPU         *int64          `json:"cpu,omitempty"`
+	CPUSmoothed *float64        `json:"cpu_smoothed,omitempty"`
+	GPU         *int64          `json:"gpu,omitempty"`
+	GPUSmoothed *float64        `json:"gpu_smoothed,omitempty"`
+	GPUType     string          `json:"gpu_type,omitempty"` // amd or nvidia
+	Sensors     []sensorReading `json:"sensors"`
+}
+
+type sensorReading struct {
+	Device       string `json:"device"`
+	Label        string `json:"label"`
+	Millidegrees int64  `json:"millidegrees"`
+	Max          int64  `json:"max,omitempty"`  // millidegrees
+	Crit         int64  `json:"crit,omitempty"` // millidegrees
+}
+
+func daemonSockPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR must be defined (to place socket file)")
+	}
+	return filepath.Join(dir, "cputemp.sock"), nil
+}
+
+// runDaemon samples the sensors every interval and serves the latest
+// snapshot (as JSON) to each client that connects to the socket.
+func runDaemon(interval time.Duration) {
+	sockPath, err := daemonSockPath()
+	if err != nil {
+		fatal(err)
+	}
+	if _, err := queryDaemon(); err == nil {
+		fatal("The daemon is already running")
+	}
+	if err := os.RemoveAll(sockPath); err != nil {
+		fatalln("Error creating socket file:", err)
+	}
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		fatalln("Error listening with socket file:", err)
+	}
+
+	readCPU := tempReader("")
+	gpuType := detectGPU()
+	var readGPU func() (int64, error)
+	if gpuType != "" {
+		readGPU = tempReader(gpuType)
+	}
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+
+	hist, err := openHistory()
+	if err != nil {
+		fatalln("Error opening history file:", err)
+	}
+
+	var mu sync.Mutex
+	var snap snapshot
+	var a alerter
+	cpuAvg, gpuAvg := newEMA(smoothSamples), newEMA(smoothSamples)
+	sample := func() {
+		s := snapshot{Time: time.Now(), GPUType: string(gpuType), Sensors: []sensorReading{}}
+		if temp, err := readCPU(); err == nil {
+			s.CPU = &temp
+			smoothed := cpuAvg.add(float64(temp))
+			s.CPUSmoothed = &smoothed
+			a.update(temp)
+			if err := hist.add(s.Time, temp); err != nil {
+				log.Println("Error recording history:", err)
+			}
+		} else {
+			log.Println("Error reading CPU temperature:", err)
+		}
+		if readGPU != nil {
+			if temp, err := readGPU(); err == nil {
+				s.GPU = &temp
+				smoothed := gpuAvg.add(float64(temp))
+				s.GPUSmoothed = &smoothed
+			} else {
+				log.Println("Error reading GPU temperature:", err)
+			}
+		}
+		for _, in := range inputs {
+			temp, err := readMillidegrees(in.Path)
+			if err != nil {
+				continue
+			}
+			s.Sensors = append(s.Sensors, sensorReading{in.Device, in.Label, temp, in.Max, in.Crit})
+		}
+		mu.Lock()
+		snap = s
+		mu.Unlock()
+	}
+	sample()
+	go func() {
+		for range time.Tick(interval) {
+			sample()
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			fatalln("Accept error:", err)
+		}
+		mu.Lock()
+		s := snap
+		mu.Unlock()
+		go func() {
+			defer conn.Close()
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			json.NewEncoder(conn).Encode(s)
+		}()
+	}
+}
+
+// queryDaemon gets the latest snapshot from the daemon, if it is running.
+func queryDaemon() (*snapshot, error) {
+	sockPath, err := daemonSockPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", sockPath, 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var s snapshot
+	if err := json.NewDecoder(conn).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// daemonTemp returns the (smoothed) CPU temperature or, if gpu is set, the
+// GPU temperature from the daemon. It returns false if the daemon isn't
+// running or doesn't have a recent reading.
+func daemonTemp(gpu gpuFlag) (float64, bool) {
+	s, err := queryDaemon()
+	if err != nil || time.Since(s.Time) > time.Minute {
+		return 0, false
+	}
+	switch {
+	case gpu == "":
+		if s.CPUSmoothed != nil {
+			return *s.CPUSmoothed, true
+		}
+	case gpu == "auto" || string(gpu) == s.GPUType:
+		if s.GPUSmoothed != nil {
+			return *s.GPUSmoothed, true
+		}
+	}
+	return 0, false
+}
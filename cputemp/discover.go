@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// cmdDiscover implements "cputemp discover", which prints what cputemp can
+// see of the hwmon chips and explains which sensor it picks.
+func cmdDiscover(args []string) {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  cputemp discover
+
+The discover command lists every hwmon chip with its driver and each of its
+temperature and fan sensors, and then shows how cputemp picks the CPU and GPU
+sensors: each candidate is listed in order of preference along with whether
+it was found.
+`)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		fatal(err)
+	}
+	dirs, err := filepath.Glob("/sys/class/hwmon/hwmon*")
+	if err != nil {
+		fatal(err)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return hwmonIndex(dirs[i]) < hwmonIndex(dirs[j]) })
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, dir := range dirs {
+		name, err := readFile(filepath.Join(dir, "name"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			fatal(err)
+		}
+		driver := "-"
+		if d, err := filepath.EvalSymlinks(filepath.Join(dir, "device", "driver")); err == nil {
+			driver = filepath.Base(d)
+		}
+		var note string
+		if isSuperIO(name) {
+			note = "(Super I/O; name its sensors in the config)"
+		}
+		fmt.Fprintf(tw, "%s\tname %s\tdriver %s\t%s\n", filepath.Base(dir), name, driver, note)
+		for _, kind := range []string{"temp", "fan"} {
+			files, err := filepath.Glob(filepath.Join(dir, kind+"*_input"))
+			if err != nil {
+				fatal(err)
+			}
+			sort.Slice(files, func(i, j int) bool { return tempIndex(files[i]) < tempIndex(files[j]) })
+			for _, f := range files {
+				base := strings.TrimSuffix(f, "_input")
+				label, err := readFile(base + "_label")
+				if err != nil {
+					label = "-"
+				}
+				value := "?"
+				if text, err := readFile(f); err == nil {
+					value = text
+					if kind == "temp" {
+						if temp, err := readMillidegrees(f); err == nil {
+							value = formatTemp(float64(temp), 1)
+						}
+					} else {
+						value += " RPM"
+					}
+				}
+				if kind == "temp" {
+					key := label
+					if key == "-" {
+						key = filepath.Base(base)
+					}
+					if n := cfg.sensorName(&tempInput{Device: name, Label: key}); n != "" {
+						value += "  (named " + n + ")"
+					}
+				}
+				fmt.Fprintf(tw, "\t%s\t%s\t%s\n", filepath.Base(base), label, value)
+			}
+		}
+	}
+	tw.Flush()
+	if len(dirs) == 0 {
+		fmt.Println("No hwmon chips found.")
+	}
+
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+	configured := make(map[sensorConfig]bool)
+	for _, s := range cfg.Sensors {
+		configured[sensorConfig{Device: s.Device, Label: s.Label}] = true
+	}
+	explain := func(what string, sensors []sensorConfig) {
+		fmt.Printf("\n%s sensor candidates, in order of preference:\n", what)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		picked := false
+		for _, s := range sensors {
+			source := "built-in"
+			if configured[sensorConfig{Device: s.Device, Label: s.Label}] {
+				source = "config"
+			}
+			var status string
+			switch in := pickTempInput(inputs, []sensorConfig{s}); {
+			case in == nil:
+				status = "not found"
+			case picked:
+				status = "found (" + filepath.Base(in.Chip) + "), but a preferred sensor was picked"
+			default:
+				status = "PICKED (" + filepath.Base(in.Chip) + ")"
+				picked = true
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", s.Device, s.Label, source, status)
+		}
+		tw.Flush()
+		if !picked {
+			fmt.Println("  (no sensor found)")
+		}
+	}
+	explain("CPU", sensorPreferences(cfg))
+	explain("AMD GPU", amdGPUSensors)
+
+	// The cache may predate a config change, in which case it is
+	// re-resolved on the next read.
+	if dir, err := os.UserCacheDir(); err == nil {
+		fmt.Println()
+		for _, name := range []string{"cpu_temp", "gpu_temp"} {
+			record, err := readFile(filepath.Join(dir, "cputemp", name+".sensor"))
+			if err != nil {
+				fmt.Printf("Cached %s sensor: (none)\n", name)
+				continue
+			}
+			id, config, _ := strings.Cut(record, "\n")
+			if config != "config "+configIdentity() {
+				id += " (stale: the config file has changed)"
+			}
+			fmt.Printf("Cached %s sensor: %s\n", name, id)
+		}
+	}
+}
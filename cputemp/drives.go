@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// listDrives prints the temperature of each NVMe and SATA drive. SATA drives
+// need the drivetemp kernel module.
+func listDrives() {
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+	drives := driveTempInputs(inputs)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, in := range drives {
+		value := "?"
+		if temp, err := readMillidegrees(in.Path); err == nil {
+			value = formatTemp(float64(temp), 0)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", driveName(in.Chip), value)
+	}
+	tw.Flush()
+	if len(drives) == 0 {
+		fatal("No drive temperature sensors found (for SATA drives, load the drivetemp module)")
+	}
+}
+
+// driveTempInputs returns the sensor of each NVMe and SATA drive among
+// inputs.
+func driveTempInputs(inputs []tempInput) []*tempInput {
+	var drives []*tempInput
+	seen := make(map[string]bool)
+	for i := range inputs {
+		in := &inputs[i]
+		switch in.Device {
+		case "nvme", "drivetemp":
+		default:
+			continue
+		}
+		// NVMe drives may have several sensors; the first one
+		// (Composite) is the overall temperature.
+		if seen[in.Chip] {
+			continue
+		}
+		seen[in.Chip] = true
+		drives = append(drives, in)
+	}
+	return drives
+}
+
+// driveName gives the name of the drive (such as nvme0 or sda) that the hwmon
+// chip belongs to.
+func driveName(chip string) string {
+	dev, err := filepath.EvalSymlinks(filepath.Join(chip, "device"))
+	if err != nil {
+		return filepath.Base(chip)
+	}
+	// For drivetemp, the device is the SCSI device; find its block device.
+	if blocks, _ := filepath.Glob(filepath.Join(dev, "block", "*")); len(blocks) > 0 {
+		return filepath.Base(blocks[0])
+	}
+	return filepath.Base(dev)
+}
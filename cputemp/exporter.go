@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runExporter serves Prometheus metrics for every hwmon temperature sensor
+// at addr.
+func runExporter(addr string) {
+	http.HandleFunc("/metrics", serveMetrics)
+	http.Handle("/", http.RedirectHandler("/metrics", http.StatusFound))
+	log.Printf("Serving metrics at http://%s/metrics", addr)
+	fatal(http.ListenAndServe(addr, nil))
+}
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	inputs, err := listTempInputs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# HELP hwmon_temp_celsius Hardware monitor for temperature (input)\n")
+	buf.WriteString("# TYPE hwmon_temp_celsius gauge\n")
+	for _, in := range inputs {
+		temp, err := readMillidegrees(in.Path)
+		if err != nil {
+			continue
+		}
+		sensor := strings.TrimSuffix(filepath.Base(in.Path), "_input")
+		fmt.Fprintf(&buf, "hwmon_temp_celsius{chip=%s,chip_name=%s,sensor=%s,label=%s} %s\n",
+			promQuote(filepath.Base(in.Chip)), promQuote(in.Device), promQuote(sensor), promQuote(in.Label),
+			strconv.FormatFloat(float64(temp)/1000, 'f', -1, 64))
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// promQuote quotes a Prometheus label value.
+func promQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
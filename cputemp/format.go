@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// These are set by the output formatting flags.
+var (
+	fahrenheit bool
+	precision  = -1 // -1 means the default for the mode
+	rawOutput  bool
+)
+
+// formatPercent formats a temperature (in millidegrees Celsius) as a
+// percentage of crit.
+func formatPercent(milli, crit float64) string {
+	prec := precision
+	if prec < 0 {
+		prec = 0
+	}
+	p := math.Pow10(prec)
+	pct := math.Round(milli/crit*100*p) / p
+	return strconv.FormatFloat(pct, 'f', prec, 64) + "%"
+}
+
+// formatTemp formats a temperature given in millidegrees Celsius according to
+// the formatting flags. defPrecision is the number of decimal places used if
+// -precision isn't given.
+func formatTemp(milli float64, defPrecision int) string {
+	if rawOutput {
+		return strconv.FormatInt(int64(math.Round(milli)), 10)
+	}
+	t := milli / 1000
+	if fahrenheit {
+		t = t*9/5 + 32
+	}
+	return formatDegrees(t, defPrecision)
+}
+
+// formatDegrees formats a temperature t in degrees to -precision decimal
+// places (or defPrecision places, if -precision isn't given).
+func formatDegrees(t float64, defPrecision int) string {
+	prec := precision
+	if prec < 0 {
+		prec = defPrecision
+	}
+	// Round half away from zero (as math.Round does) rather than to even.
+	p := math.Pow10(prec)
+	t = math.Round(t*p) / p
+	return strconv.FormatFloat(t, 'f', prec, 64)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// cpuFreq returns the current frequency (in kHz) averaged over all CPUs.
+func cpuFreq() (float64, error) {
+	files, err := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, errors.New("no cpufreq information available")
+	}
+	var sum float64
+	for _, f := range files {
+		text, err := readFile(f)
+		if err != nil {
+			return 0, err
+		}
+		khz, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing contents of %s: %s", f, err)
+		}
+		sum += float64(khz)
+	}
+	return sum / float64(len(files)), nil
+}
+
+// formatFreq formats a frequency given in kHz.
+func formatFreq(khz float64) string {
+	return strconv.FormatFloat(khz/1e6, 'f', 2, 64) + "GHz"
+}
+
+// withFreq extends show, which formats a CPU temperature, to add the
+// average CPU frequency and, if the temperature has reached limit (in
+// millidegrees Celsius; 0 if unknown), a throttling indicator.
+func withFreq(show func(milli float64) string, limit float64) func(milli float64) string {
+	return func(milli float64) string {
+		s := show(milli)
+		khz, err := cpuFreq()
+		if err != nil {
+			s += " ?GHz"
+		} else {
+			s += " " + formatFreq(khz)
+		}
+		if limit > 0 && milli >= limit {
+			s += " throttling"
+		}
+		return s
+	}
+}
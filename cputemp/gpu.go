@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gpuFlag is the value of -gpu, which may be given alone (as a boolean
+// flag) to pick the GPU automatically or given a GPU vendor.
+type gpuFlag string
+
+func (f *gpuFlag) String() string   { return string(*f) }
+func (f *gpuFlag) IsBoolFlag() bool { return true }
+
+func (f *gpuFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*f = "auto"
+	case "false":
+		*f = ""
+	case "auto", "amd", "nvidia":
+		*f = gpuFlag(s)
+	default:
+		return fmt.Errorf("unknown GPU type %q (must be auto, amd, or nvidia)", s)
+	}
+	return nil
+}
+
+// amdGPUSensors are the amdgpu sensors, in order of preference: edge is the
+// temperature that most tools display and junction is the hotspot (not all
+// GPUs have both).
+var amdGPUSensors = []sensorConfig{
+	{Device: "amdgpu", Label: "edge"},
+	{Device: "amdgpu", Label: "junction"},
+}
+
+func findGPUTempFile() (string, error) {
+	return findFirstTempFile(amdGPUSensors)
+}
+
+// hasAMDGPU reports whether there is an amdgpu temperature sensor.
+func hasAMDGPU() bool {
+	dirs, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+	for _, dir := range dirs {
+		if name, err := readFile(filepath.Join(dir, "name")); err == nil && name == "amdgpu" {
+			return true
+		}
+	}
+	return false
+}
+
+// detectGPU returns the type of GPU present (preferring an AMD GPU), or ""
+// if there is none.
+func detectGPU() gpuFlag {
+	if hasAMDGPU() {
+		return "amd"
+	}
+	if _, err := readNVIDIATemp(); err == nil {
+		return "nvidia"
+	}
+	return ""
+}
+
+// readNVIDIATemp reads the temperature (in millidegrees Celsius) of the
+// first NVIDIA GPU using nvidia-smi.
+func readNVIDIATemp() (int64, error) {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return 0, errors.New("nvidia-smi not found (is the NVIDIA driver installed?)")
+	}
+	cmd := exec.Command("nvidia-smi", "--query-gpu=temperature.gpu", "--format=csv,noheader,nounits")
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return 0, fmt.Errorf("nvidia-smi failed: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return 0, fmt.Errorf("nvidia-smi failed: %s", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	temp, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse nvidia-smi output %q", out)
+	}
+	return temp * 1000, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The daemon records CPU temperature samples in a ring buffer file. The file
+// consists of a header (the index of the next record to write and the
+// number of records written, capped at historyCap) followed by historyCap
+// fixed-size records.
+const (
+	historyCap        = 86400 // a day at the default sampling interval
+	historyHeaderSize = 8
+	historyRecordSize = 12
+)
+
+type historyRecord struct {
+	Time  int64 // Unix seconds
+	Milli int32 // millidegrees Celsius
+}
+
+func historyPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "cputemp", "history"), nil
+}
+
+// A historyWriter appends samples to the history file.
+type historyWriter struct {
+	f     *os.File
+	next  uint32
+	count uint32
+}
+
+func openHistory() (*historyWriter, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := &historyWriter{f: f}
+	var hdr [historyHeaderSize]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil && !errors.Is(err, io.EOF) {
+		f.Close()
+		return nil, err
+	}
+	w.next = binary.LittleEndian.Uint32(hdr[:4]) % historyCap
+	w.count = binary.LittleEndian.Uint32(hdr[4:])
+	if w.count > historyCap {
+		w.count = historyCap
+	}
+	return w, nil
+}
+
+func (w *historyWriter) add(t time.Time, milli int64) error {
+	var rec [historyRecordSize]byte
+	binary.LittleEndian.PutUint64(rec[:8], uint64(t.Unix()))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(int32(milli)))
+	off := historyHeaderSize + int64(w.next)*historyRecordSize
+	if _, err := w.f.WriteAt(rec[:], off); err != nil {
+		return err
+	}
+	w.next = (w.next + 1) % historyCap
+	if w.count < historyCap {
+		w.count++
+	}
+	var hdr [historyHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:4], w.next)
+	binary.LittleEndian.PutUint32(hdr[4:], w.count)
+	_, err := w.f.WriteAt(hdr[:], 0)
+	return err
+}
+
+// readHistory reads the recorded samples, oldest first.
+func readHistory() ([]historyRecord, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < historyHeaderSize {
+		return nil, nil
+	}
+	next := binary.LittleEndian.Uint32(b[:4]) % historyCap
+	count := binary.LittleEndian.Uint32(b[4:8])
+	if count > historyCap {
+		count = historyCap
+	}
+	recs := make([]historyRecord, 0, count)
+	for i := uint32(0); i < count; i++ {
+		idx := (next + historyCap - count + i) % historyCap
+		off := historyHeaderSize + int(idx)*historyRecordSize
+		if off+historyRecordSize > len(b) {
+			continue
+		}
+		rec := b[off : off+historyRecordSize]
+		recs = append(recs, historyRecord{
+			Time:  int64(binary.LittleEndian.Uint64(rec[:8])),
+			Milli: int32(binary.LittleEndian.Uint32(rec[8:])),
+		})
+	}
+	return recs, nil
+}
+
+const sparkWidth = 60
+
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// printHistory prints a sparkline of the CPU temperature over the past d.
+func printHistory(d time.Duration) {
+	recs, err := readHistory()
+	if errors.Is(err, os.ErrNotExist) {
+		fatal("No history recorded (the daemon records it; see -daemon)")
+	}
+	if err != nil {
+		fatalln("Error reading history:", err)
+	}
+	now := time.Now()
+	start := now.Add(-d)
+	// Average the samples into sparkWidth buckets.
+	var sums [sparkWidth]float64
+	var counts [sparkWidth]int
+	var n int
+	for _, r := range recs {
+		t := time.Unix(r.Time, 0)
+		if t.Before(start) || t.After(now) {
+			continue
+		}
+		i := int(float64(t.Sub(start)) / float64(d) * sparkWidth)
+		if i >= sparkWidth {
+			i = sparkWidth - 1
+		}
+		sums[i] += float64(r.Milli)
+		counts[i]++
+		n++
+	}
+	if n == 0 {
+		fatalf("No samples recorded in the past %s", d)
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range sums {
+		if counts[i] == 0 {
+			continue
+		}
+		sums[i] /= float64(counts[i])
+		lo = math.Min(lo, sums[i])
+		hi = math.Max(hi, sums[i])
+	}
+	var b strings.Builder
+	for i := range sums {
+		if counts[i] == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		j := len(sparkChars) - 1
+		if hi > lo {
+			j = int((sums[i] - lo) / (hi - lo) * float64(len(sparkChars)-1))
+		}
+		b.WriteRune(sparkChars[j])
+	}
+	fmt.Printf("%s  %s–%s (past %s)\n", b.String(), formatTemp(lo, 0), formatTemp(hi, 0), d)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A tempInput is one temperature sensor of an hwmon chip.
+type tempInput struct {
+	Chip   string // hwmon directory, such as /sys/class/hwmon/hwmon2
+	Device string // chip name, such as k10temp
+	Label  string // sensor label (or tempN if the sensor has no label)
+	Path   string // path of the tempN_input file
+
+	// Max and Crit are the sensor's high and critical temperatures (in
+	// millidegrees Celsius), or 0 if it doesn't report them.
+	Max, Crit int64
+}
+
+// listTempInputs lists every temperature sensor of every hwmon chip. The
+// sensors' attributes are read in parallel (see readParallel); a sensor
+// that doesn't respond in time is left out, with a warning.
+func listTempInputs() ([]tempInput, error) {
+	dirs, err := filepath.Glob("/sys/class/hwmon/hwmon*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(dirs, func(i, j int) bool { return hwmonIndex(dirs[i]) < hwmonIndex(dirs[j]) })
+	var files []string
+	for _, dir := range dirs {
+		fs, err := filepath.Glob(filepath.Join(dir, "temp*_input"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(fs, func(i, j int) bool { return tempIndex(fs[i]) < tempIndex(fs[j]) })
+		files = append(files, fs...)
+	}
+	reads := make([]func() (*tempInput, error), len(files))
+	for i, f := range files {
+		f := f
+		reads[i] = func() (*tempInput, error) { return readTempInput(f) }
+	}
+	var inputs []tempInput
+	for i, r := range readParallel(reads) {
+		if errors.Is(r.err, errTimeout) {
+			log.Printf("Skipping %s: reading its attributes %s", files[i], r.err)
+			continue
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.val != nil {
+			inputs = append(inputs, *r.val)
+		}
+	}
+	return inputs, nil
+}
+
+// readTempInput reads the attributes of the sensor whose input file is
+// path. It returns nil if the sensor's chip has no name.
+func readTempInput(path string) (*tempInput, error) {
+	dir := filepath.Dir(path)
+	name, err := readFile(filepath.Join(dir, "name"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(path, "_input")
+	label, err := readFile(base + "_label")
+	if errors.Is(err, os.ErrNotExist) {
+		label = filepath.Base(base)
+	} else if err != nil {
+		return nil, err
+	}
+	max, crit := sensorLimits(path)
+	return &tempInput{
+		Chip:   dir,
+		Device: name,
+		Label:  label,
+		Path:   path,
+		Max:    max,
+		Crit:   crit,
+	}, nil
+}
+
+// isSuperIO reports whether device is the name of a Super I/O chip, which
+// monitors the motherboard (including the VRM and the chipset) on most
+// desktops. These are handled by the nct6775 and it87 drivers, which name
+// each chip by its model, such as nct6798 or it8686.
+func isSuperIO(device string) bool {
+	return strings.HasPrefix(device, "nct6") || strings.HasPrefix(device, "it8")
+}
+
+// hwmonIndex gives N for a path ending in hwmonN (for sorting).
+func hwmonIndex(dir string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "hwmon"))
+	return n
+}
+
+// tempIndex gives N for a path ending in tempN_input (or another sensor's
+// input, such as fanN_input) for sorting.
+func tempIndex(path string) int {
+	s := strings.TrimSuffix(filepath.Base(path), "_input")
+	n, _ := strconv.Atoi(strings.TrimLeft(s, "abcdefghijklmnopqrstuvwxyz"))
+	return n
+}
+
+// readMillidegrees reads a sysfs temperature file, which holds an integer
+// number of millidegrees Celsius.
+func readMillidegrees(path string) (int64, error) {
+	text, err := readFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+// sensorLimits reads the max and crit values (in millidegrees Celsius) of
+// the sensor whose input file is path. Each is 0 if the sensor doesn't have
+// it.
+func sensorLimits(path string) (max, crit int64) {
+	base := strings.TrimSuffix(path, "_input")
+	max, _ = readMillidegrees(base + "_max")
+	crit, _ = readMillidegrees(base + "_crit")
+	return max, crit
+}
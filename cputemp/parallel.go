@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// readTimeout is set by -read-timeout.
+var readTimeout = time.Second
+
+// errTimeout is the error given by readParallel for a read that took too
+// long.
+var errTimeout = errors.New("timed out")
+
+// A readResult is the result of one of the reads done by readParallel.
+type readResult[T any] struct {
+	val T
+	err error
+}
+
+// readParallel calls each of reads concurrently, giving up on any that
+// takes longer than readTimeout. (Some hwmon drivers, such as those for USB
+// sensors, occasionally hang; a hung read is left behind.)
+func readParallel[T any](reads []func() (T, error)) []readResult[T] {
+	results := make([]readResult[T], len(reads))
+	var wg sync.WaitGroup
+	for i, read := range reads {
+		i, read := i, read
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := make(chan readResult[T], 1)
+			go func() {
+				val, err := read()
+				done <- readResult[T]{val, err}
+			}()
+			select {
+			case results[i] = <-done:
+			case <-time.After(readTimeout):
+				results[i].err = fmt.Errorf("%w after %s", errTimeout, readTimeout)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,31 @@
+package main
+
+// smoothSamples is set by -smooth: the number of samples over which readings
+// are smoothed in watch and daemon modes (0 or 1 means no smoothing).
+var smoothSamples int
+
+// An ema is an exponential moving average with the smoothing factor of an
+// N-sample simple moving average (α = 2/(N+1)).
+type ema struct {
+	alpha float64
+	value float64
+	init  bool
+}
+
+func newEMA(n int) *ema {
+	if n < 1 {
+		n = 1
+	}
+	return &ema{alpha: 2 / float64(n+1)}
+}
+
+// add adds a sample and returns the new average.
+func (e *ema) add(x float64) float64 {
+	if !e.init {
+		e.value = x
+		e.init = true
+	} else {
+		e.value += e.alpha * (x - e.value)
+	}
+	return e.value
+}
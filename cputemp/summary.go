@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+)
+
+// A summaryEntry is one line of the -summary output.
+type summaryEntry struct {
+	Name         string `json:"name"` // cpu, gpu, chipset, a drive name, or a configured name
+	Device       string `json:"device,omitempty"`
+	Label        string `json:"label,omitempty"`
+	Millidegrees *int64 `json:"millidegrees,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	read func() (int64, error)
+}
+
+// printSummary prints the CPU, GPU, drive, chipset, and named motherboard
+// temperatures as a table or, if asJSON is set, as JSON. The sensors are
+// read in parallel (nvidia-smi, in particular, can be slow) and with a
+// timeout.
+func printSummary(asJSON bool) {
+	inputs, err := listTempInputs()
+	if err != nil {
+		fatalln("Error listing temperature sensors:", err)
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		fatal(err)
+	}
+	var entries []*summaryEntry
+	add := func(name string, in *tempInput) {
+		path := in.Path
+		entries = append(entries, &summaryEntry{
+			Name:   name,
+			Device: in.Device,
+			Label:  in.Label,
+			read:   func() (int64, error) { return readMillidegrees(path) },
+		})
+	}
+	if in := pickTempInput(inputs, sensorPreferences(cfg)); in != nil {
+		add("cpu", in)
+	}
+	if in := pickTempInput(inputs, amdGPUSensors); in != nil {
+		add("gpu", in)
+	} else if _, err := exec.LookPath("nvidia-smi"); err == nil {
+		entries = append(entries, &summaryEntry{Name: "gpu", Device: "nvidia", read: readNVIDIATemp})
+	}
+	for _, in := range driveTempInputs(inputs) {
+		add(driveName(in.Chip), in)
+	}
+	seen := make(map[string]bool)
+	for i := range inputs {
+		in := &inputs[i]
+		// Intel chipsets have chips named for the platform, such as
+		// pch_cannonlake.
+		if strings.HasPrefix(in.Device, "pch_") && !seen[in.Chip] {
+			seen[in.Chip] = true
+			add("chipset", in)
+		}
+	}
+	// Motherboard sensors are only included if they're named in the
+	// config: Super I/O chips have many inputs, most of which are
+	// unconnected and read nonsense.
+	for i := range inputs {
+		if name := cfg.sensorName(&inputs[i]); name != "" {
+			add(name, &inputs[i])
+		}
+	}
+
+	reads := make([]func() (int64, error), len(entries))
+	for i, e := range entries {
+		reads[i] = e.read
+	}
+	for i, r := range readParallel(reads) {
+		if r.err != nil {
+			entries[i].Error = r.err.Error()
+			continue
+		}
+		temp := r.val
+		entries[i].Millidegrees = &temp
+	}
+
+	if asJSON {
+		if entries == nil {
+			entries = []*summaryEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		value := "?"
+		if e.Millidegrees != nil {
+			value = formatTemp(float64(*e.Millidegrees), 0)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", e.Name, value)
+	}
+	tw.Flush()
+}
+
+// pickTempInput returns the first of sensors found among inputs, or nil if
+// there is none.
+func pickTempInput(inputs []tempInput, sensors []sensorConfig) *tempInput {
+	for _, s := range sensors {
+		for i := range inputs {
+			if inputs[i].Device == s.Device && inputs[i].Label == s.Label {
+				return &inputs[i]
+			}
+		}
+	}
+	return nil
+}
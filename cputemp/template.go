@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// A templateFormatter formats temperatures with the -format template.
+type templateFormatter struct {
+	tmpl   *template.Template
+	sensor func() string // as for newColorizer
+	other  string        // the device name if sensor returns "" (nvidia or battery)
+
+	// These are looked up the first time the template uses them.
+	crit          float64
+	critKnown     bool
+	device, label string
+	identKnown    bool
+}
+
+func (f *templateFormatter) format(milli float64) string {
+	var b strings.Builder
+	if err := f.tmpl.Execute(&b, &templateData{f, milli}); err != nil {
+		return "error: " + err.Error()
+	}
+	return b.String()
+}
+
+func (f *templateFormatter) critTemp() float64 {
+	if !f.critKnown {
+		f.crit = critTemp(f.sensor)
+		f.critKnown = true
+	}
+	return f.crit
+}
+
+func (f *templateFormatter) identify() {
+	if f.identKnown {
+		return
+	}
+	f.identKnown = true
+	path := f.sensor()
+	if path == "" {
+		f.device = f.other
+		return
+	}
+	if id, err := sensorIdentity(path); err == nil {
+		f.device, f.label, _ = strings.Cut(id, "/")
+	}
+}
+
+// templateData is the data for the -format template. Its fields are
+// methods so that the sensor is only looked up if the template needs it.
+type templateData struct {
+	f     *templateFormatter
+	milli float64 // millidegrees Celsius
+}
+
+// Millidegrees is the temperature in millidegrees Celsius.
+func (d *templateData) Millidegrees() int64 { return int64(d.milli) }
+
+// Celsius and Fahrenheit are the temperature in each scale, formatted to
+// -precision decimal places (default 0).
+func (d *templateData) Celsius() string    { return formatDegrees(d.milli/1000, 0) }
+func (d *templateData) Fahrenheit() string { return formatDegrees(d.milli/1000*9/5+32, 0) }
+
+// Temp is the temperature formatted according to the flags (as cputemp
+// prints it without -format).
+func (d *templateData) Temp() string { return formatTemp(d.milli, 0) }
+
+// Percent is the temperature as a percentage of the critical temperature,
+// or "?" if that isn't known.
+func (d *templateData) Percent() string {
+	if crit := d.f.critTemp(); crit > 0 {
+		return formatPercent(d.milli, crit)
+	}
+	return "?"
+}
+
+// Level is the alert level: normal, warning, or critical.
+func (d *templateData) Level() string { return levelNames[thresholdLevel(d.milli/1000)] }
+
+// thermometerIcons are the Font Awesome thermometer icons, from empty to
+// full.
+var thermometerIcons = []string{"\uf2cb", "\uf2ca", "\uf2c9", "\uf2c8", "\uf2c7"}
+
+// Icon is a thermometer icon that fills up as the temperature approaches
+// the critical temperature (or 100°C, if that isn't known).
+func (d *templateData) Icon() string {
+	crit := d.f.critTemp()
+	if crit <= 0 {
+		crit = 100e3
+	}
+	i := int(d.milli / crit * float64(len(thermometerIcons)))
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(thermometerIcons) {
+		i = len(thermometerIcons) - 1
+	}
+	return thermometerIcons[i]
+}
+
+// Device and Label identify the sensor, as in -all.
+func (d *templateData) Device() string { d.f.identify(); return d.f.device }
+func (d *templateData) Label() string  { d.f.identify(); return d.f.label }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// intervalFlag is the value of -watch, which may be given alone (as a boolean
+// flag) to use the default interval or given an interval.
+type intervalFlag time.Duration
+
+const defaultWatchInterval = time.Second
+
+func (f *intervalFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return time.Duration(*f).String()
+}
+
+func (f *intervalFlag) IsBoolFlag() bool { return true }
+
+func (f *intervalFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*f = intervalFlag(defaultWatchInterval)
+		return nil
+	case "false":
+		*f = 0
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	*f = intervalFlag(d)
+	return nil
+}
+
+// watch prints the temperature read by readTemp, formatted by show, every
+// interval (and immediately upon receiving SIGUSR1). If inline is set, each
+// reading overwrites the previous one on the terminal.
+func watch(readTemp func() (int64, error), show func(milli float64) string, interval time.Duration, inline bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refresh := make(chan os.Signal, 1)
+	signal.Notify(refresh, syscall.SIGUSR1)
+	var a alerter
+	avg := newEMA(smoothSamples)
+	for {
+		temp, err := readTemp()
+		if err != nil {
+			fatalln("Error reading temperature:", err)
+		}
+		a.update(temp)
+		s := show(avg.add(float64(temp)))
+		if inline {
+			// Return to the start of the line and clear it.
+			fmt.Printf("\r%s\x1b[K", s)
+		} else {
+			fmt.Println(s)
+		}
+		select {
+		case <-ticker.C:
+		case <-refresh:
+			ticker.Reset(interval)
+		}
+	}
+}
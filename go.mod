@@ -3,6 +3,7 @@ module github.com/cespare/utils
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/cespare/subcmd v1.1.0
 	github.com/joshuarubin/go-sway v1.2.0
 	golang.org/x/exp v0.0.0-20230425010034-47ecfdc1ba53
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// accelInterval is the longest time between steps that counts as
+	// holding down a key. It has to be longer than the usual delay before a
+	// key starts to repeat (600ms in sway by default).
+	accelInterval = 750 * time.Millisecond
+	// accelEvery is how many repeated steps it takes to double the step
+	// size.
+	accelEvery = 5
+	// accelMax is the largest multiple of the original step size.
+	accelMax = 4
+)
+
+// accelFactor returns the multiple of the step size to use for a step up or
+// down (kind), which is 1 at first and doubles every accelEvery steps in the
+// same direction with less than accelInterval between them. The last step is
+// recorded (except with -n) in $XDG_STATE_HOME/intelbacklight/repeat.
+func accelFactor(kind string) (float64, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return 0, err
+	}
+	path := filepath.Join(dir, "repeat")
+	now := time.Now()
+	if dryRun {
+		b, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		return accelMultiple(accelCount(kind, b, now)), nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	f, err := lockFile(path, unix.LOCK_EX)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	count := accelCount(kind, b, now)
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%s %d %d\n", kind, count, now.UnixNano())), 0); err != nil {
+		return 0, err
+	}
+	return accelMultiple(count), nil
+}
+
+// accelCount gives the number of steps in a row in the same direction
+// before this one, given the contents of the repeat file.
+func accelCount(kind string, b []byte, now time.Time) int {
+	var lastKind string
+	var count int
+	var nanos int64
+	fmt.Sscan(strings.TrimSpace(string(b)), &lastKind, &count, &nanos)
+	if lastKind == kind && now.Sub(time.Unix(0, nanos)) < accelInterval {
+		return count + 1
+	}
+	return 0
+}
+
+func accelMultiple(count int) float64 {
+	factor := 1.0
+	for i := accelEvery; i <= count && factor < accelMax; i += accelEvery {
+		factor *= 2
+	}
+	return factor
+}
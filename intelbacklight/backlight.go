@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	backlightDir = "/sys/class/backlight"
+	ledsDir      = "/sys/class/leds"
+)
+
+// A backlight is a device under /sys/class/backlight or, for a keyboard
+// backlight, /sys/class/leds.
+type backlight struct {
+	name      string // such as intel_backlight, amdgpu_bl0, or dell::kbd_backlight
+	dir       string
+	subsystem string // backlight or leds
+}
+
+// useLogind is set by -logind.
+var useLogind = true
+
+func (b *backlight) String() string { return b.name }
+
+func (b *backlight) brightness() (cur, max int64, err error) {
+	if max, err = b.tryRead("max_brightness"); err != nil {
+		return 0, 0, err
+	}
+	if cur, err = b.tryRead("brightness"); err != nil {
+		return 0, 0, err
+	}
+	return cur, max, nil
+}
+
+// setBrightness sets the brightness of b using logind or, if that doesn't
+// work, by writing to sysfs directly.
+func (b *backlight) setBrightness(n int64) error {
+	var logindErr error
+	if useLogind {
+		if logindErr = setBrightnessLogind(b.subsystem, b.name, n); logindErr == nil {
+			return nil
+		}
+	}
+	if err := b.tryWrite("brightness", n); err != nil {
+		if logindErr != nil {
+			return fmt.Errorf("cannot set brightness using logind (%s) or sysfs (%s)", logindErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// actualBrightness returns the brightness that the panel actually has,
+// which firmware may have quantized from the one requested. If the driver
+// doesn't say, it's the requested brightness.
+func (b *backlight) actualBrightness() (int64, error) {
+	n, err := b.tryRead("actual_brightness")
+	if errors.Is(err, os.ErrNotExist) {
+		return b.tryRead("brightness")
+	}
+	return n, err
+}
+
+// quantize deals with panels whose firmware only supports some brightness
+// levels, where a small change doesn't do anything. It is called after the
+// brightness was changed from cur to target, when the actual brightness was
+// before. It doesn't try out other brightnesses to find the panel's levels,
+// since each try would make the screen flash; instead, it learns them from
+// the actual brightness after each change (see loadLevels). If the change
+// left the actual brightness at before, it moves on to the next level in the
+// same direction, if it knows one. Otherwise the brightness stays at target,
+// so that the next step goes on from there. It returns the resulting
+// brightness.
+func (b *backlight) quantize(before, cur, target int64) (int64, error) {
+	if target == cur {
+		return target, nil
+	}
+	actual, err := b.actualBrightness()
+	if err != nil {
+		return target, err
+	}
+	levels, err := loadLevels()
+	if err != nil {
+		return target, err
+	}
+	key := levelKey{device: b.name, up: target > cur, from: before}
+	if actual != before {
+		// Only panels that quantize the brightness have levels.
+		if to, ok := levels[key]; actual != target && (!ok || to != actual) {
+			levels[key] = actual
+			return target, saveLevels(levels)
+		}
+		return target, nil
+	}
+	next, ok := levels[key]
+	if !ok {
+		return target, nil
+	}
+	if err := b.setBrightness(next); err != nil {
+		return target, err
+	}
+	if actual, err = b.actualBrightness(); err != nil {
+		return next, err
+	}
+	if actual == before {
+		// The recorded level is out of date.
+		delete(levels, key)
+		return next, saveLevels(levels)
+	}
+	return next, nil
+}
+
+func (b *backlight) tryRead(name string) (int64, error) {
+	s, err := b.readString(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func (b *backlight) readString(name string) (string, error) {
+	text, err := os.ReadFile(filepath.Join(b.dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(text)), nil
+}
+
+func (b *backlight) tryWrite(name string, n int64) error {
+	s := strconv.FormatInt(n, 10)
+	f, err := os.OpenFile(filepath.Join(b.dir, name), os.O_TRUNC|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(s)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// backlightTypes gives the preference order of each backlight type. The
+// devices of the GPU drivers (intel_backlight, amdgpu_bl0, and so on) are
+// raw; on many laptops there's also an ACPI (firmware) device that
+// doesn't actually work.
+var backlightTypes = map[string]int{
+	"raw":      0,
+	"platform": 1,
+	"firmware": 2,
+}
+
+// listBacklights lists the backlight devices in order of preference.
+func listBacklights() []*backlight {
+	dirs, err := filepath.Glob(filepath.Join(backlightDir, "*"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var bs []*backlight
+	for _, dir := range dirs {
+		bs = append(bs, &backlight{name: filepath.Base(dir), dir: dir, subsystem: "backlight"})
+	}
+	rank := func(b *backlight) int {
+		typ, err := b.readString("type")
+		if r, ok := backlightTypes[typ]; ok && err == nil {
+			return r
+		}
+		return len(backlightTypes)
+	}
+	sort.SliceStable(bs, func(i, j int) bool { return rank(bs[i]) < rank(bs[j]) })
+	return bs
+}
+
+// listKbdBacklights lists the keyboard backlights.
+func listKbdBacklights() []*backlight {
+	dirs, err := filepath.Glob(filepath.Join(ledsDir, "*kbd_backlight*"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var bs []*backlight
+	for _, dir := range dirs {
+		bs = append(bs, &backlight{name: filepath.Base(dir), dir: dir, subsystem: "leds"})
+	}
+	return bs
+}
+
+// findBacklight returns the named backlight (or LED) device, or nil if
+// there is no such device.
+func findBacklight(name string) *backlight {
+	for _, b := range []*backlight{
+		{name: name, dir: filepath.Join(backlightDir, name), subsystem: "backlight"},
+		{name: name, dir: filepath.Join(ledsDir, name), subsystem: "leds"},
+	} {
+		if _, err := os.Stat(b.dir); err == nil {
+			return b
+		}
+	}
+	return nil
+}
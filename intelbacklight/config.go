@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is the (optional) intelbacklight configuration file, which lives
+// at $XDG_CONFIG_HOME/intelbacklight/config.toml. For example:
+//
+//	steps = [0, 1, 2, 5, 10, 20, 35, 55, 80, 100]
+//	ac_brightness = 80
+//	battery_brightness = 40
+//
+//	[[profiles]]
+//	name = "day"
+//	brightness = 80
+//
+//	[[profiles]]
+//	name = "night"
+//	brightness = 25
+type config struct {
+	// Profiles are named brightness levels, in the order that
+	// "profile next" cycles through them.
+	Profiles []profile `toml:"profiles"`
+
+	// Steps is the step table for up and down: an increasing list of
+	// percentages.
+	Steps []float64 `toml:"steps"`
+
+	// ACBrightness and BatteryBrightness are the percentages that the
+	// power command sets when the machine is plugged in or unplugged.
+	ACBrightness      *float64 `toml:"ac_brightness"`
+	BatteryBrightness *float64 `toml:"battery_brightness"`
+}
+
+type profile struct {
+	Name       string  `toml:"name"`
+	Brightness float64 `toml:"brightness"` // percentage
+}
+
+func readConfig() (*config, error) {
+	cfg := new(config)
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("error establishing config dir: %s", err)
+	}
+	path := filepath.Join(dir, "intelbacklight", "config.toml")
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %s", path, err)
+	}
+	for _, p := range cfg.Profiles {
+		if p.Name == "" || p.Name == "next" || p.Brightness < 0 || p.Brightness > 100 {
+			return nil, fmt.Errorf("config file %s: each profile needs a name (other than next) and a brightness from 0 to 100", path)
+		}
+	}
+	for i, pct := range cfg.Steps {
+		if pct < 0 || pct > 100 || i > 0 && pct <= cfg.Steps[i-1] {
+			return nil, fmt.Errorf("config file %s: steps must be increasing percentages from 0 to 100", path)
+		}
+	}
+	for _, pct := range []*float64{cfg.ACBrightness, cfg.BatteryBrightness} {
+		if pct != nil && (*pct < 0 || *pct > 100) {
+			return nil, fmt.Errorf("config file %s: ac_brightness and battery_brightness must be from 0 to 100", path)
+		}
+	}
+	return cfg, nil
+}
+
+// nextProfile returns the profile after the one closest to the brightness
+// pct.
+func (cfg *config) nextProfile(pct float64) profile {
+	closest := 0
+	for i, p := range cfg.Profiles {
+		if abs(p.Brightness-pct) < abs(cfg.Profiles[closest].Brightness-pct) {
+			closest = i
+		}
+	}
+	return cfg.Profiles[(closest+1)%len(cfg.Profiles)]
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
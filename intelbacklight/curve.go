@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A curve maps brightness percentages to fractions of the maximum raw
+// brightness. Perceived brightness is far from linear in the raw value (the
+// bottom 10% of the raw range covers most of the useful dim levels), so a
+// perceptual curve makes each step feel about the same.
+type curve struct {
+	name string
+	// exp is the exponent of a power curve (1 is linear); if it's 0, the
+	// curve is logarithmic.
+	exp float64
+}
+
+// Set implements flag.Value.
+func (c *curve) Set(s string) error {
+	switch s {
+	case "linear":
+		*c = curve{name: s, exp: 1}
+	case "log":
+		*c = curve{name: s}
+	default:
+		exp, err := strconv.ParseFloat(s, 64)
+		if err != nil || exp <= 0 {
+			return fmt.Errorf("curve must be linear, log, or a positive exponent")
+		}
+		*c = curve{name: s, exp: exp}
+	}
+	return nil
+}
+
+func (c *curve) String() string { return c.name }
+
+// toRaw converts a percentage to a raw brightness value out of max.
+func (c *curve) toRaw(pct float64, max int64) int64 {
+	f := pct / 100
+	switch {
+	case f <= 0:
+		return 0
+	case f >= 1:
+		return max
+	}
+	if c.exp == 0 {
+		// Go from 1 at 0% (exclusive) to max at 100%.
+		return int64(math.Round(math.Pow(float64(max), f)))
+	}
+	return int64(math.Round(math.Pow(f, c.exp) * float64(max)))
+}
+
+// toPct converts a raw brightness value out of max to a percentage.
+func (c *curve) toPct(raw, max int64) float64 {
+	if raw <= 0 {
+		return 0
+	}
+	f := float64(raw) / float64(max)
+	if c.exp == 0 {
+		return math.Log(float64(raw)) / math.Log(float64(max)) * 100
+	}
+	return math.Pow(f, 1/c.exp) * 100
+}
+
+// A floor is the minimum brightness, either in raw units or as a
+// percentage of the maximum.
+type floor struct {
+	s   string
+	n   float64
+	pct bool
+}
+
+// Set implements flag.Value.
+func (f *floor) Set(s string) error {
+	t, pct := strings.CutSuffix(s, "%")
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n < 0 || !pct && n != math.Trunc(n) {
+		return fmt.Errorf("floor must be a number of raw units or a percentage (such as 1%%)")
+	}
+	*f = floor{s: s, n: n, pct: pct}
+	return nil
+}
+
+func (f *floor) String() string { return f.s }
+
+// raw gives the floor as a raw brightness value out of max. It's always at
+// least 1, so that the floor doesn't turn the backlight off.
+func (f *floor) raw(max int64) int64 {
+	n := int64(f.n)
+	if f.pct {
+		n = int64(math.Ceil(f.n / 100 * float64(max)))
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// stepsFlag is a step table: an increasing list of percentages.
+type stepsFlag []float64
+
+// Set implements flag.Value.
+func (f *stepsFlag) Set(s string) error {
+	var steps stepsFlag
+	for _, field := range strings.Split(s, ",") {
+		pct, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("bad percentage %q", field)
+		}
+		if len(steps) > 0 && pct <= steps[len(steps)-1] {
+			return errors.New("steps must be increasing")
+		}
+		steps = append(steps, pct)
+	}
+	*f = steps
+	return nil
+}
+
+func (f *stepsFlag) String() string {
+	var fields []string
+	for _, pct := range *f {
+		fields = append(fields, strconv.FormatFloat(pct, 'f', -1, 64))
+	}
+	return strings.Join(fields, ",")
+}
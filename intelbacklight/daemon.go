@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// cmdDaemon changes the brightness when the machine is plugged in or
+// unplugged and restores it after firmware resets it. In each case, it
+// waits for -settle first, so that a flaky power connection doesn't make
+// the screen flicker and so that the restored brightness isn't immediately
+// clobbered by whatever the firmware is still doing.
+func cmdDaemon(args []string) {
+	fs := newFlagSet("daemon", `Usage:
+
+  intelbacklight daemon [-settle duration] [-restore=false]
+
+The daemon command runs until it is killed, watching for events that call
+for changing the brightness.
+
+While it runs, the other commands send their changes to it over a unix
+socket (see -socket) and it makes them one at a time, so that keybindings,
+an idle dimmer, and the daemon itself don't race each other.
+
+It sets the brightness to the ac_brightness or battery_brightness percentage
+in the config file whenever the machine is plugged in or unplugged:
+
+  ac_brightness = 80
+  battery_brightness = 40
+
+It only lowers the brightness when the machine is unplugged and only raises
+it when the machine is plugged in. Either setting may be left out to leave
+the brightness alone in that case.
+
+Unless -restore=false is given, it also sets the brightness back to the one
+last set by intelbacklight (see restore) after the machine resumes from
+suspend, the lid is opened, or a monitor is connected or disconnected
+(docking or undocking), since firmware often resets the brightness then.
+`)
+	settle := fs.Duration("settle", 5*time.Second, "Wait until things have been quiet for `duration` before changing the brightness")
+	restore := fs.Bool("restore", true, "Restore the brightness after resume, lid open, and dock events")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	devices := selectedDevices()
+	cfg, err := readConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	power := cfg.ACBrightness != nil || cfg.BatteryBrightness != nil
+	sockPath, err := socketPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var server socketServer
+	server.listen(sockPath)
+
+	events := watchUevents()
+	var resumes <-chan string
+	if *restore {
+		resumes = watchResume()
+	}
+	ac := onAC()
+	infof("On AC: %t", ac)
+	var powerTimer, restoreTimer <-chan time.Time
+	for {
+		// The lock also keeps the daemon's own log messages out of the
+		// replies to requests.
+		select {
+		case e := <-events:
+			server.mu.Lock()
+			switch {
+			case e["SUBSYSTEM"] == "power_supply" && power:
+				powerTimer = time.After(*settle)
+			case e["SUBSYSTEM"] == "drm" && e["HOTPLUG"] == "1" && *restore:
+				infof("Monitor connected or disconnected")
+				restoreTimer = time.After(*settle)
+			}
+			server.mu.Unlock()
+		case what := <-resumes:
+			server.mu.Lock()
+			infof("Event: %s", what)
+			restoreTimer = time.After(*settle)
+			server.mu.Unlock()
+		case <-powerTimer:
+			powerTimer = nil
+			server.mu.Lock()
+			ac = updatePower(devices, cfg, ac)
+			server.mu.Unlock()
+		case <-restoreTimer:
+			restoreTimer = nil
+			server.mu.Lock()
+			restoreChanged(devices)
+			server.mu.Unlock()
+		}
+	}
+}
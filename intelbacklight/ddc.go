@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// A ddcDisplay is an external monitor whose brightness is controlled over
+// DDC/CI (VCP feature 0x10) using ddcutil.
+type ddcDisplay struct {
+	num       int    // ddcutil display number
+	model     string // such as DELL U2720Q, if known
+	serial    string // if known
+	connector string // DRM connector, such as DP-1, if known
+}
+
+func (d *ddcDisplay) String() string { return fmt.Sprintf("ddc:%d", d.num) }
+
+func (d *ddcDisplay) brightness() (cur, max int64, err error) {
+	out, err := ddcutil("--display", strconv.Itoa(d.num), "--brief", "getvcp", "10")
+	if err != nil {
+		return 0, 0, err
+	}
+	// The output looks like "VCP 10 C 50 100".
+	fields := strings.Fields(string(out))
+	if len(fields) != 5 || fields[0] != "VCP" || fields[2] != "C" {
+		return 0, 0, fmt.Errorf("cannot parse ddcutil output %q", out)
+	}
+	if cur, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse ddcutil output %q", out)
+	}
+	if max, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse ddcutil output %q", out)
+	}
+	return cur, max, nil
+}
+
+func (d *ddcDisplay) setBrightness(n int64) error {
+	_, err := ddcutil("--display", strconv.Itoa(d.num), "setvcp", "10", strconv.FormatInt(n, 10))
+	return err
+}
+
+// listDDCDisplays lists the monitors that support DDC/CI. It returns nil if
+// ddcutil isn't installed. (This is slow: ddcutil probes every I2C bus.)
+func listDDCDisplays() ([]*ddcDisplay, error) {
+	if _, err := exec.LookPath("ddcutil"); err != nil {
+		return nil, nil
+	}
+	out, err := ddcutil("detect", "--brief")
+	if err != nil {
+		return nil, err
+	}
+	// Each display looks like
+	//
+	//   Display 1
+	//      I2C bus:  /dev/i2c-4
+	//      DRM connector:  card1-DP-1
+	//      Monitor:  DEL:DELL U2720Q:ABC123
+	//
+	// (Older versions of ddcutil don't give the DRM connector.)
+	var displays []*ddcDisplay
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if s, ok := strings.CutPrefix(line, "Display "); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				continue
+			}
+			displays = append(displays, &ddcDisplay{num: n})
+			continue
+		}
+		if len(displays) == 0 {
+			continue
+		}
+		d := displays[len(displays)-1]
+		if s, ok := strings.CutPrefix(line, "Monitor:"); ok {
+			parts := strings.Split(strings.TrimSpace(s), ":")
+			if len(parts) >= 2 {
+				d.model = parts[1]
+			}
+			if len(parts) >= 3 {
+				d.serial = parts[2]
+			}
+		}
+		if s, ok := strings.CutPrefix(line, "DRM connector:"); ok {
+			// Drop the card prefix to get the name that the
+			// compositor uses.
+			s = strings.TrimSpace(s)
+			if _, name, ok := strings.Cut(s, "-"); ok && strings.HasPrefix(s, "card") {
+				s = name
+			}
+			d.connector = s
+		}
+	}
+	return displays, nil
+}
+
+// parseDDCName parses a device name of the form ddc:N.
+func parseDDCName(name string) (*ddcDisplay, bool) {
+	s, ok := strings.CutPrefix(name, "ddc:")
+	if !ok {
+		return nil, false
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return nil, false
+	}
+	return &ddcDisplay{num: n}, true
+}
+
+func ddcutil(args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("ddcutil"); err != nil {
+		return nil, errors.New("ddcutil not found")
+	}
+	cmd := exec.Command("ddcutil", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("ddcutil failed: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		if len(out) > 0 {
+			return nil, fmt.Errorf("ddcutil failed: %s", strings.TrimSpace(string(out)))
+		}
+		return nil, fmt.Errorf("ddcutil failed: %s", err)
+	}
+	return out, nil
+}
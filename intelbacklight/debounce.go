@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Holding down a brightness key runs intelbacklight many times a second.
+// Rather than have every run write to the device (which, for a DDC/CI
+// monitor, takes a good fraction of a second), each run records its target
+// in a pending file and only one of them (the writer) applies the targets,
+// once per debounceInterval, until they stop changing. Each run computes its
+// target starting from the previous run's target rather than the
+// brightness of the device, which may not have caught up yet.
+
+// debounceInterval is the minimum time between writes of a device's
+// brightness.
+const debounceInterval = 16 * time.Millisecond
+
+// pendingTTL is how long a pending target is used as the starting point
+// for the next change.
+const pendingTTL = time.Second
+
+// A pendingFile is the locked pending file of a device. It holds the latest
+// target brightness and when it was set.
+type pendingFile struct {
+	f *os.File
+}
+
+func pendingDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "pending")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// lockFile opens (creating if need be) and flocks the file. The lock is
+// released when the file is closed.
+func lockFile(path string, how int) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = unix.Flock(int(f.Fd()), how)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// openPending opens and locks the pending file of d.
+func openPending(d device) (*pendingFile, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := lockFile(filepath.Join(dir, d.String()), unix.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	return &pendingFile{f: f}, nil
+}
+
+// get returns the pending target, if one was set in the last pendingTTL.
+func (p *pendingFile) get() (target int64, ok bool) {
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return 0, false
+	}
+	b, err := io.ReadAll(p.f)
+	if err != nil {
+		return 0, false
+	}
+	var nanos int64
+	if _, err := fmt.Sscan(strings.TrimSpace(string(b)), &target, &nanos); err != nil {
+		return 0, false
+	}
+	if time.Since(time.Unix(0, nanos)) > pendingTTL {
+		return 0, false
+	}
+	return target, true
+}
+
+func (p *pendingFile) set(target int64) error {
+	if err := p.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := p.f.WriteAt([]byte(fmt.Sprintf("%d %d\n", target, time.Now().UnixNano())), 0)
+	return err
+}
+
+func (p *pendingFile) Close() error { return p.f.Close() }
+
+// setDebounced changes the brightness of d from cur to the pending target,
+// target, unless another run is already doing so, in which case it will
+// pick up the new target. If the change is relative (a step up or down) and
+// the panel quantizes the brightness, the brightness may go further than
+// target (see quantize). It returns the brightness it set.
+func setDebounced(d device, cur, target int64, relative bool) (int64, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return 0, err
+	}
+	w, err := lockFile(filepath.Join(dir, d.String()+".writer"), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return target, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+	b, _ := d.(*backlight)
+	if !relative {
+		b = nil
+	}
+	for {
+		var before int64
+		if b != nil {
+			if before, err = b.actualBrightness(); err != nil {
+				return 0, err
+			}
+		}
+		if err := fade(d, cur, target); err != nil {
+			return 0, err
+		}
+		written := target
+		if b != nil {
+			if written, err = b.quantize(before, cur, target); err != nil {
+				return 0, err
+			}
+			if written != target {
+				infof("%s: the actual brightness didn't change; going on to the next level, %d", d, written)
+				// Make the next run start from where the brightness
+				// really is.
+				if err := replacePending(d, target, written); err != nil {
+					return 0, err
+				}
+			}
+		}
+		time.Sleep(debounceInterval)
+		p, err := openPending(d)
+		if err != nil {
+			return 0, err
+		}
+		next, ok := p.get()
+		if !ok || next == written {
+			// Give up being the writer while holding the pending
+			// file, so that any later run takes over.
+			w.Close()
+			return written, p.Close()
+		}
+		p.Close()
+		cur, target = written, next
+	}
+}
+
+// replacePending changes the pending target of d from old to n (if it is
+// still old).
+func replacePending(d device, old, n int64) error {
+	p, err := openPending(d)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	if target, ok := p.get(); ok && target == old {
+		return p.set(n)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// A device is something whose brightness intelbacklight can control: a
+// backlight or an external monitor. Its String method gives the name by
+// which -device selects it.
+type device interface {
+	fmt.Stringer
+	// brightness returns the current and maximum brightness, in the
+	// device's raw units.
+	brightness() (cur, max int64, err error)
+	setBrightness(n int64) error
+}
+
+// listDevices lists the backlights, in order of preference, the keyboard
+// backlights, and then (if ddc is set) the external monitors.
+func listDevices(ddc bool) []device {
+	var ds []device
+	for _, b := range listBacklights() {
+		ds = append(ds, b)
+	}
+	for _, b := range listKbdBacklights() {
+		ds = append(ds, b)
+	}
+	if ddc {
+		displays, err := listDDCDisplays()
+		if err != nil {
+			log.Println("Error listing DDC/CI monitors:", err)
+		}
+		for _, d := range displays {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// allDevices returns the devices that -all applies to: the preferred
+// backlight (the others, if any, are usually different interfaces to the
+// same panel) and the external monitors.
+func allDevices() []device {
+	var ds []device
+	if bs := listBacklights(); len(bs) > 0 {
+		ds = append(ds, bs[0])
+	}
+	displays, err := listDDCDisplays()
+	if err != nil {
+		log.Println("Error listing DDC/CI monitors:", err)
+	}
+	for _, d := range displays {
+		ds = append(ds, d)
+	}
+	if len(ds) == 0 {
+		log.Fatal("No devices found")
+	}
+	return ds
+}
+
+// findDevice returns the named device (a backlight or LED name or, for an
+// external monitor, ddc:N) or, if name is empty, the preferred backlight
+// (or, if kbd is set, the keyboard backlight).
+func findDevice(name string, kbd bool) device {
+	if name == "" && kbd {
+		bs := listKbdBacklights()
+		if len(bs) == 0 {
+			log.Fatalf("No keyboard backlight found in %s", ledsDir)
+		}
+		return bs[0]
+	}
+	if name == "" {
+		bs := listBacklights()
+		if len(bs) == 0 {
+			log.Fatalf("No backlight devices found in %s", backlightDir)
+		}
+		return bs[0]
+	}
+	if d := lookupDevice(name); d != nil {
+		return d
+	}
+	log.Fatalf("No device %q (see intelbacklight list)", name)
+	panic("unreachable")
+}
+
+// lookupDevice returns the named device (a backlight or LED name or ddc:N),
+// or nil if there is no such device.
+func lookupDevice(name string) device {
+	if d, ok := parseDDCName(name); ok {
+		return d
+	}
+	if strings.ContainsRune(name, '/') {
+		return nil
+	}
+	if b := findBacklight(name); b != nil {
+		return b
+	}
+	return nil
+}
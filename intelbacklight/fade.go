@@ -0,0 +1,23 @@
+package main
+
+import "time"
+
+// fadeDuration is set by -fade.
+var fadeDuration time.Duration
+
+// fadeStep is the time between brightness changes while fading.
+const fadeStep = 20 * time.Millisecond
+
+// fade changes the brightness of d from cur to target gradually, over
+// fadeDuration.
+func fade(d device, cur, target int64) error {
+	steps := int64(fadeDuration / fadeStep)
+	for i := int64(1); i < steps; i++ {
+		v := cur + (target-cur)*i/steps
+		if err := d.setBrightness(v); err != nil {
+			return err
+		}
+		time.Sleep(fadeStep)
+	}
+	return d.setBrightness(target)
+}
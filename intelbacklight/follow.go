@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// follow prints the brightness of b, as a percentage, now and whenever it
+// changes. If asJSON is set, each line is a waybar custom module object.
+func follow(b *backlight, asJSON bool) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		log.Fatalln("inotify_init failed:", err)
+	}
+	// Writes to brightness cause modify events; the kernel also notifies
+	// watchers of actual_brightness when the brightness changes in other
+	// ways (such as through hardware brightness keys).
+	for _, name := range []string{"brightness", "actual_brightness"} {
+		path := filepath.Join(b.dir, name)
+		if _, err := unix.InotifyAddWatch(fd, path, unix.IN_MODIFY); err != nil && !errors.Is(err, unix.ENOENT) {
+			log.Fatalf("Cannot watch %s: %s", path, err)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	last := int64(-1)
+	print := func() {
+		cur, max, err := b.brightness()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if cur == last {
+			return
+		}
+		last = cur
+		pct := int(math.Round(brightnessCurve.toPct(cur, max)))
+		if !asJSON {
+			fmt.Printf("%d%%\n", pct)
+			return
+		}
+		msg := struct {
+			Text       string `json:"text"`
+			Percentage int    `json:"percentage"`
+			Tooltip    string `json:"tooltip"`
+		}{
+			Text:       fmt.Sprintf("%d%%", pct),
+			Percentage: pct,
+			Tooltip:    fmt.Sprintf("%s: %d/%d", b.name, cur, max),
+		}
+		if err := enc.Encode(msg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	print()
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		// Whatever the events are, they mean it's time to check again.
+		_, err := unix.Read(fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			log.Fatalln("Error reading inotify events:", err)
+		}
+		print()
+	}
+}
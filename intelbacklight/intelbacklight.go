@@ -1,66 +1,804 @@
 package main
 
 import (
-	"io/ioutil"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/cespare/subcmd"
 )
 
-const basePath = "/sys/class/backlight/intel_backlight"
+// These are set by flags.
+var (
+	brightnessCurve = curve{name: "linear", exp: 1}
+	minBrightness   = floor{s: "1", n: 1}
+	allowZero       bool
+	brightnessSteps stepsFlag // from the config file if not given
+	dryRun          bool
+	quiet           bool
+	deviceName      string
+	kbd             bool
+	all             bool
+	wrapAround      bool // set by -wrap
+	exactClamp      bool // set by -exact-clamp
+)
 
-func read(name string) int64 {
-	b, err := ioutil.ReadFile(filepath.Join(basePath, name))
-	if err != nil {
-		log.Fatal(err)
+// defaultStep is the percentage by which up and down change the brightness
+// if there is no step table.
+const defaultStep = 5.0
+
+// accelStep is the percentage by which up -accel and down -accel first
+// change the brightness.
+const accelStep = 2.0
+
+// An op is a change to the brightness.
+type op struct {
+	kind string // delta, up, down, set, min, max, save, restore, or profile
+	pct  float64
+	name string // profile name (or next)
+}
+
+// target gives the new raw brightness of a device with the current and
+// maximum brightness cur and max.
+func (o op) target(cur, max int64) int64 {
+	v, _ := o.clamp(o.rawTarget(cur, max), cur, max)
+	return v
+}
+
+// rawTarget is like target, but without the limits applied by clamp.
+func (o op) rawTarget(cur, max int64) int64 {
+	var newVal int64
+	switch o.kind {
+	case "min":
+		newVal = minBrightness.raw(max)
+	case "max":
+		newVal = max
+	case "set":
+		newVal = brightnessCurve.toRaw(o.pct, max)
+	case "up", "down":
+		if len(brightnessSteps) == 0 {
+			pct := defaultStep
+			if o.kind == "down" {
+				pct = -pct
+			}
+			return op{kind: "delta", pct: pct}.rawTarget(cur, max)
+		}
+		newVal = cur
+		if o.kind == "up" {
+			for _, step := range brightnessSteps {
+				// Skip steps that clamp doesn't let us move to.
+				v := brightnessCurve.toRaw(step, max)
+				if c, _ := o.clamp(v, cur, max); c > cur {
+					return v
+				}
+			}
+			if wrapAround {
+				newVal = brightnessCurve.toRaw(brightnessSteps[0], max)
+			}
+		} else {
+			for i := len(brightnessSteps) - 1; i >= 0; i-- {
+				v := brightnessCurve.toRaw(brightnessSteps[i], max)
+				if c, _ := o.clamp(v, cur, max); c < cur {
+					return v
+				}
+			}
+			if wrapAround {
+				newVal = brightnessCurve.toRaw(brightnessSteps[len(brightnessSteps)-1], max)
+			}
+		}
+	case "delta":
+		pct := brightnessCurve.toPct(cur, max) + o.pct
+		if wrapAround {
+			if v, ok := o.wrap(pct, cur, max); ok {
+				return v
+			}
+		}
+		newVal = brightnessCurve.toRaw(pct, max)
+		// At the dim end of a perceptual curve, a small step may not
+		// change the raw value; always move by at least one.
+		switch {
+		case o.pct > 0 && newVal <= cur:
+			newVal = cur + 1
+		case o.pct < 0 && newVal >= cur:
+			newVal = cur - 1
+		}
 	}
-	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
-	if err != nil {
-		log.Fatal(err)
+	return newVal
+}
+
+// floor gives the lowest raw brightness o may set.
+func (o op) floor(max int64) int64 {
+	if allowZero || o.kind == "restore" {
+		return 0
 	}
-	return n
+	return minBrightness.raw(max)
 }
 
-func write(name string, n int64) {
-	s := strconv.FormatInt(n, 10)
-	f, err := os.OpenFile(filepath.Join(basePath, name), os.O_TRUNC|os.O_WRONLY, 0)
-	if err != nil {
-		log.Fatal(err)
+// clamp limits the raw target v of o to the range from the floor (except
+// when restoring a recorded brightness) to max. If it changes v, it also
+// returns a description of the limit.
+func (o op) clamp(v, cur, max int64) (int64, string) {
+	lo := o.floor(max)
+	if v < lo {
+		if (o.kind == "delta" && o.pct < 0 || o.kind == "down") && lo > cur {
+			// Don't let a step down raise the brightness.
+			return cur, "the current brightness"
+		}
+		return lo, "the floor"
 	}
-	if _, err := f.Write([]byte(s)); err != nil {
-		log.Fatal(err)
+	if v > max {
+		return max, "the maximum"
 	}
-	if err := f.Close(); err != nil {
-		log.Fatal(err)
+	return v, ""
+}
+
+// wrap handles a change by a percentage to pct that, with -wrap, goes past
+// the floor or the maximum: the brightness carries on from the other end by
+// however far it went past or, with -exact-clamp, it stops at the end first
+// and then wraps around to exactly the other end. It reports whether pct
+// went past an end.
+func (o op) wrap(pct float64, cur, max int64) (int64, bool) {
+	lo := o.floor(max)
+	loPct := brightnessCurve.toPct(lo, max)
+	switch {
+	case pct > 100 && exactClamp && cur < max:
+		return max, true
+	case pct > 100 && exactClamp:
+		return lo, true
+	case pct > 100:
+		return brightnessCurve.toRaw(loPct+pct-100, max), true
+	case pct < loPct && exactClamp && cur > lo:
+		return lo, true
+	case pct < loPct && exactClamp:
+		return max, true
+	case pct < loPct:
+		return brightnessCurve.toRaw(100-(loPct-pct), max), true
 	}
+	return 0, false
+}
+
+var cmds = []subcmd.Command{
+	{
+		Name:        "get",
+		Description: "print the brightness",
+		Do:          cmdGet,
+	},
+	{
+		Name:        "set",
+		Description: "set the brightness to a percentage",
+		Do:          cmdSet,
+	},
+	{
+		Name:        "up",
+		Description: "increase the brightness",
+		Do:          func(args []string) { cmdStep("up", args) },
+	},
+	{
+		Name:        "down",
+		Description: "decrease the brightness",
+		Do:          func(args []string) { cmdStep("down", args) },
+	},
+	{
+		Name:        "profile",
+		Description: "switch to a brightness profile from the config file",
+		Do:          cmdProfile,
+	},
+	{
+		Name:        "save",
+		Description: "record the current brightness",
+		Do:          cmdSave,
+	},
+	{
+		Name:        "restore",
+		Description: "set the recorded brightness",
+		Do:          cmdRestore,
+	},
+	{
+		Name:        "sync",
+		Description: "set the external monitors to the brightness of the backlight",
+		Do:          cmdSync,
+	},
+	{
+		Name:        "list",
+		Description: "list the backlights and external monitors",
+		Do:          cmdList,
+	},
+	{
+		Name:        "daemon",
+		Description: "change the brightness when the machine is plugged in or unplugged",
+		Do:          cmdDaemon,
+	},
+	{
+		Name:        "install-udev",
+		Description: "install udev rules that allow setting the brightness through sysfs",
+		Do:          cmdInstallUdev,
+	},
+	{
+		Name:        "night",
+		Description: "set the color temperature of the outputs",
+		Do:          cmdNight,
+	},
+}
+
+// legacyArgs rewrites an operation in the syntax intelbacklight had before
+// it had commands (a delta such as 10 or -5, =pct, min, or max) into the
+// equivalent command, so that existing keybindings keep working.
+func legacyArgs(args []string) []string {
+	// Find the first argument after the flags. A negative delta looks like a
+	// flag, so check for numbers first.
+	i := 0
+	for i < len(args) && args[i] != "--" && strings.HasPrefix(args[i], "-") && args[i] != "-" {
+		if _, err := strconv.ParseFloat(args[i], 64); err == nil {
+			break
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		i++
+		if f := flag.Lookup(name); f != nil && !hasValue {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++ // the flag's value
+			}
+		}
+	}
+	if i > len(args) {
+		i = len(args) // a flag missing its value; flag.Parse will complain
+	}
+	flags, rest := args[:i:i], args[i:]
+	if len(rest) > 0 && rest[0] == "--" {
+		flags = append(flags, "--")
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return args
+	}
+	var cmd []string
+	switch arg := rest[0]; {
+	case arg == "min" || arg == "max":
+		cmd = []string{"set", arg}
+	case strings.HasPrefix(arg, "="):
+		cmd = []string{"set", arg[1:]}
+	default:
+		delta, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return args
+		}
+		if delta < 0 {
+			cmd = []string{"down", strings.TrimPrefix(arg, "-")}
+		} else {
+			cmd = []string{"up", strings.TrimPrefix(arg, "+")}
+		}
+	}
+	return append(append(flags, cmd...), rest[1:]...)
 }
 
 func main() {
 	log.SetFlags(0)
-	if len(os.Args) > 2 {
-		log.Fatal("usage: intelbacklight [delta]")
-	}
-	max := read("max_brightness")
-	cur := read("brightness")
-	if len(os.Args) == 1 {
-		pct := float64(cur) / float64(max) * 100
-		log.Printf("max: %d, current: %d (%.1f%%)", max, cur, pct)
+	flag.Var(&brightnessCurve, "curve", "Map percentages to brightness values with this `curve`: linear, log, or an exponent (such as 2.5)")
+	flag.Var(&minBrightness, "floor", "Don't go below this `brightness` (in raw units or, as in 1%, a percentage)")
+	flag.BoolVar(&allowZero, "allow-zero", false, "Allow turning the backlight off (ignoring -floor)")
+	flag.BoolVar(&useLogind, "logind", true, "Set the brightness using logind (over D-Bus) if possible, rather than writing to sysfs")
+	flag.StringVar(&deviceName, "device", "", "Use this `device` (a backlight in /sys/class/backlight or, for an external monitor, ddc:N) instead of the detected one")
+	outputName := flag.String("output", "", "Use the device that controls the brightness of this sway `output` (such as eDP-1 or DP-1)")
+	flag.BoolVar(&kbd, "kbd", false, "Use the keyboard backlight (with no -floor)")
+	flag.BoolVar(&all, "all", false, "Apply to the backlight and every DDC/CI monitor")
+	flag.BoolVar(&dryRun, "n", false, "Print the computed change (in raw units) instead of making it")
+	flag.BoolVar(&quiet, "q", false, "Don't log the changes made")
+	flag.StringVar(&socketFlag, "socket", "", "Path of the daemon's socket (default $XDG_RUNTIME_DIR/intelbacklight.sock)")
+	flag.BoolVar(&notifyOSD, "notify", false, "After changing the brightness, show it in a desktop notification (using notify-send)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  intelbacklight [flags] [COMMAND]
+
+where the flags are:
+
+`)
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, `
+and the possible commands are:
+
+`)
+		subcmd.PrintDefaults(cmds)
+		fmt.Fprint(os.Stderr, `
+With no command, intelbacklight prints the brightness (as with get).
+For compatibility with older versions, a delta such as 10 or -5 means up 10
+or down 5, =pct means set pct, and min and max mean set min and set max.
+
+Percentages are percentages of each device's range, following -curve. The
+flags select the device: by default, intelbacklight controls the preferred
+backlight; -device selects another one (see list), -output selects the one
+for a sway output, -kbd selects the keyboard backlight, and -all selects
+the backlight and every external monitor that can be controlled with DDC/CI.
+
+Run 'intelbacklight COMMAND -h' to see more information about a command.
+`)
+	}
+	flag.CommandLine.Parse(legacyArgs(os.Args[1:]))
+	if all && (deviceName != "" || kbd) {
+		log.Fatal("-all cannot be used with -device or -kbd")
+	}
+	if *outputName != "" {
+		if all || deviceName != "" || kbd {
+			log.Fatal("-output cannot be used with -all, -device, or -kbd")
+		}
+		deviceName = findOutputDevice(*outputName)
+	}
+	if kbd {
+		// Turning off the keyboard backlight is normal.
+		allowZero = true
+	}
+	if flag.NArg() == 0 {
+		cmdGet(nil)
 		return
 	}
-	delta, err := strconv.ParseFloat(os.Args[1], 64)
+	r := subcmd.New("intelbacklight", cmds, flag.ExitOnError)
+	r.Usage = flag.Usage
+	r.Run(flag.Args())
+}
+
+// selectedDevices returns the devices selected by -device, -kbd, and -all.
+func selectedDevices() []device {
+	if all {
+		return allDevices()
+	}
+	return []device{findDevice(deviceName, kbd)}
+}
+
+// run applies o to each of the selected devices (by way of the daemon, if
+// it's running).
+func run(o op) {
+	devices := selectedDevices()
+	handled, failed := false, false
+	if !dryRun {
+		handled, failed = runRemote(devices, o)
+	}
+	if !handled {
+		for _, d := range devices {
+			if err := apply(d, o); err != nil {
+				log.Printf("%s: %s", d, err)
+				failed = true
+			}
+		}
+	}
+	if notifyOSD && !dryRun && o.kind != "" && o.kind != "save" {
+		// With -all, the first device stands for all of them.
+		if err := sendOSD(devices[0]); err != nil {
+			log.Println("Cannot show notification:", err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// infof logs an informational message (unless -q was given).
+func infof(format string, args ...any) {
+	if !quiet {
+		log.Printf(format, args...)
+	}
+}
+
+// newFlagSet returns a FlagSet for the named command with the given usage
+// text (which is followed by the flags, if there are any).
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprint(os.Stderr, "\nFlags:\n\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func addFadeFlag(fs *flag.FlagSet) {
+	fs.DurationVar(&fadeDuration, "fade", 0, "Change the brightness gradually over `duration`")
+}
+
+// parsePct parses a percentage from 0 to 100.
+func parsePct(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(s, 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, fmt.Errorf("bad percentage %q", s)
+	}
+	return pct, nil
+}
+
+func cmdGet(args []string) {
+	fs := newFlagSet("get", `Usage:
+
+  intelbacklight get [-follow [-json]]
+
+The get command prints the brightness of each selected device.
+
+With -follow, it prints the brightness percentage of the backlight now and
+whenever it changes (by any means), for a status bar.
+`)
+	followMode := fs.Bool("follow", false, "Print the brightness percentage whenever it changes")
+	jsonOutput := fs.Bool("json", false, "With -follow, print waybar custom module JSON")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *followMode {
+		if all {
+			log.Fatal("-follow cannot be used with -all")
+		}
+		b, ok := findDevice(deviceName, kbd).(*backlight)
+		if !ok {
+			log.Fatal("-follow only works with backlights")
+		}
+		follow(b, *jsonOutput)
+	}
+	run(op{})
+}
+
+func cmdSet(args []string) {
+	fs := newFlagSet("set", `Usage:
+
+  intelbacklight set [-fade duration] pct|min|max
+
+The set command sets the brightness to the given percentage. The min and max
+commands set the lowest brightness (the -floor) and the highest brightness,
+respectively.
+`)
+	addFadeFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch s := fs.Arg(0); s {
+	case "min", "max":
+		run(op{kind: s})
+	default:
+		pct, err := parsePct(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run(op{kind: "set", pct: pct})
+	}
+}
+
+// cmdStep implements the up and down commands.
+func cmdStep(kind string, args []string) {
+	fs := newFlagSet(kind, fmt.Sprintf(`Usage:
+
+  intelbacklight %[1]s [-fade duration] [-steps percentages | -accel] [-wrap [-exact-clamp]] [pct]
+
+The %[1]s command changes the brightness by the given percentage or, if
+none is given, moves to the next step of the step table (from -steps or the
+config file). If there isn't a step table, it changes the brightness by 5%%.
+
+With -accel, it changes the brightness by the given percentage (2%% by
+default), doubling it (up to 8%%) as it's run repeatedly in quick succession
+(as when a brightness key is held down).
+
+With -wrap, going up past the maximum wraps around to the floor and going
+down past the floor wraps around to the maximum, so that a single key can
+cycle through the brightnesses. A step that goes past the end carries on
+from the other end by the remainder of the step; with -exact-clamp, it stops
+at the end instead, and only the next step wraps around (to exactly the
+other end).
+`, kind))
+	addFadeFlag(fs)
+	fs.Var(&brightnessSteps, "steps", "Move between these `percentages` (such as 0,1,2,5,10,20,35,55,80,100)")
+	accel := fs.Bool("accel", false, "Make the step bigger when run repeatedly in quick succession")
+	fs.BoolVar(&wrapAround, "wrap", false, "Wrap around past the maximum or the floor")
+	fs.BoolVar(&exactClamp, "exact-clamp", false, "With -wrap, stop at the maximum or floor before wrapping around")
+	fs.Parse(args)
+	if exactClamp && !wrapAround {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *accel {
+		if brightnessSteps != nil || fs.NArg() > 1 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		pct := accelStep
+		if fs.NArg() == 1 {
+			var err error
+			if pct, err = parsePct(fs.Arg(0)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		factor, err := accelFactor(kind)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pct *= factor
+		if kind == "down" {
+			pct = -pct
+		}
+		run(op{kind: "delta", pct: pct})
+		return
+	}
+	switch fs.NArg() {
+	case 0:
+		if brightnessSteps == nil {
+			cfg, err := readConfig()
+			if err != nil {
+				log.Fatal(err)
+			}
+			brightnessSteps = cfg.Steps
+		}
+		run(op{kind: kind})
+	case 1:
+		pct, err := parsePct(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if kind == "down" {
+			pct = -pct
+		}
+		run(op{kind: "delta", pct: pct})
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func cmdProfile(args []string) {
+	fs := newFlagSet("profile", `Usage:
+
+  intelbacklight profile [-fade duration] name|next
+
+The profile command sets the brightness of a profile listed in
+$XDG_CONFIG_HOME/intelbacklight/config.toml:
+
+  [[profiles]]
+  name = "day"
+  brightness = 80
+
+  [[profiles]]
+  name = "night"
+  brightness = 25
+
+"profile next" switches to the profile after the one closest to the current
+brightness.
+`)
+	addFadeFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	run(op{kind: "profile", name: fs.Arg(0)})
+}
+
+func cmdSave(args []string) {
+	fs := newFlagSet("save", `Usage:
+
+  intelbacklight save
+
+Each time intelbacklight sets the brightness, it records it in
+$XDG_STATE_HOME/intelbacklight/brightness. The save command records the
+current brightness (which may have been set by something else).
+`)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	run(op{kind: "save"})
+}
+
+func cmdRestore(args []string) {
+	fs := newFlagSet("restore", `Usage:
+
+  intelbacklight restore [-fade duration]
+
+The restore command sets the brightness to the value recorded in
+$XDG_STATE_HOME/intelbacklight/brightness (by the last change or save).
+`)
+	addFadeFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	run(op{kind: "restore"})
+}
+
+func cmdSync(args []string) {
+	fs := newFlagSet("sync", `Usage:
+
+  intelbacklight sync [-fade duration]
+
+The sync command sets the brightness of each external monitor to the same
+percentage as the backlight, so that changes with -all keep them in step.
+`)
+	addFadeFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if deviceName != "" || kbd {
+		log.Fatal("sync cannot be used with -device or -kbd")
+	}
+	devices := allDevices()
+	cur, max, err := devices[0].brightness()
 	if err != nil {
-		log.Fatalf("Bad delta %q: %s", os.Args[1], err)
+		log.Fatalf("%s: %s", devices[0], err)
 	}
-	deltaAbs := int64(delta / 100 * float64(max))
-	newVal := cur + deltaAbs
-	if newVal < 0 {
-		newVal = 0
+	o := op{kind: "set", pct: brightnessCurve.toPct(cur, max)}
+	failed := false
+	for _, d := range devices[1:] {
+		if err := apply(d, o); err != nil {
+			log.Printf("%s: %s", d, err)
+			failed = true
+		}
 	}
-	if newVal > max {
-		newVal = max
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cmdList(args []string) {
+	fs := newFlagSet("list", `Usage:
+
+  intelbacklight list
+
+The list command lists the backlight devices and the external monitors that
+can be controlled with DDC/CI (the device that is used by default, or the one
+given by -device, is marked with *).
+`)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ds := listDevices(true)
+	if len(ds) == 0 {
+		log.Fatal("No devices found")
+	}
+	selected := deviceName
+	if selected == "" {
+		selected = ds[0].String()
+	}
+	for _, d := range ds {
+		mark := " "
+		if d.String() == selected {
+			mark = "*"
+		}
+		var desc string
+		switch d := d.(type) {
+		case *backlight:
+			desc, _ = d.readString("type")
+			if d.subsystem == "leds" {
+				desc = "keyboard"
+			}
+		case *ddcDisplay:
+			desc = "DDC/CI"
+			if d.model != "" {
+				desc += " " + d.model
+			}
+		}
+		cur, max, err := d.brightness()
+		if err != nil || max <= 0 {
+			fmt.Printf("%s %s (%s)\n", mark, d, desc)
+			continue
+		}
+		fmt.Printf("%s %s (%s): %d/%d (%.1f%%)%s\n", mark, d, desc, cur, max, brightnessCurve.toPct(cur, max), actualNote(d, cur))
+	}
+}
+
+// actualNote describes the actual brightness of d if it isn't the
+// requested brightness, cur (because the firmware quantized it).
+func actualNote(d device, cur int64) string {
+	b, ok := d.(*backlight)
+	if !ok {
+		return ""
+	}
+	actual, err := b.actualBrightness()
+	if err != nil || actual == cur {
+		return ""
+	}
+	return fmt.Sprintf(", actual: %d", actual)
+}
+
+// findProfile finds the named profile in the config file or, if name is
+// next, the profile after the one closest to the current brightness pct.
+func findProfile(name string, pct float64) (profile, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return profile{}, err
+	}
+	if len(cfg.Profiles) == 0 {
+		return profile{}, errors.New("no profiles in the config file")
+	}
+	if name == "next" {
+		return cfg.nextProfile(pct), nil
+	}
+	for _, p := range cfg.Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return profile{}, fmt.Errorf("no profile named %q", name)
+}
+
+// apply applies o to d or, if o is the zero op, prints d's brightness.
+func apply(d device, o op) error {
+	cur, max, err := d.brightness()
+	if err != nil {
+		return err
+	}
+	if max <= 0 {
+		return fmt.Errorf("bad maximum brightness %d", max)
+	}
+	switch o.kind {
+	case "":
+		pct := brightnessCurve.toPct(cur, max)
+		log.Printf("%s: max: %d, current: %d (%.1f%%)%s", d, max, cur, pct, actualNote(d, cur))
+		return nil
+	case "save":
+		return saveState(d.String(), cur)
+	}
+
+	p, err := openPending(d)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	// Start from the target of a change that may not have been applied yet.
+	base := cur
+	if target, ok := p.get(); ok {
+		base = target
+	}
+	var raw int64
+	co := o // the op whose limits apply
+	switch o.kind {
+	case "restore":
+		state, err := loadState()
+		if err != nil {
+			return err
+		}
+		saved, ok := state[d.String()]
+		if !ok {
+			return errors.New("no saved brightness")
+		}
+		raw = saved
+	case "profile":
+		prof, err := findProfile(o.name, brightnessCurve.toPct(base, max))
+		if err != nil {
+			return err
+		}
+		infof("%s: profile %s", d, prof.Name)
+		co = op{kind: "set", pct: prof.Brightness}
+		raw = co.rawTarget(base, max)
+	default:
+		raw = o.rawTarget(base, max)
+	}
+	newVal, limit := co.clamp(raw, base, max)
+	if dryRun {
+		fmt.Printf("%s: current: %d/%d (%.1f%%), target: %d (%.1f%%), delta: %d",
+			d, base, max, brightnessCurve.toPct(base, max),
+			newVal, brightnessCurve.toPct(newVal, max), newVal-base)
+		if limit != "" {
+			fmt.Printf(" (clamped from %d to %s)", raw, limit)
+		}
+		fmt.Println()
+		return nil
+	}
+	if err := p.set(newVal); err != nil {
+		return err
+	}
+	p.Close()
+	infof("%s: changing %d -> %d (delta: %d)", d, base, newVal, newVal-base)
+	relative := o.kind == "delta" || o.kind == "up" || o.kind == "down"
+	newVal, err = setDebounced(d, cur, newVal, relative)
+	if err != nil {
+		return err
 	}
-	log.Printf("Changing %d -> %d (delta: %d)", cur, newVal, deltaAbs)
-	write("brightness", newVal)
+	return saveState(d.String(), newVal)
 }
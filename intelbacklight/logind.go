@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// setBrightnessLogind sets the brightness of the device by asking logind
+// (with the D-Bus method org.freedesktop.login1.Session.SetBrightness),
+// which permits the user of the active session to change it without write
+// access to sysfs. It talks to D-Bus using busctl.
+func setBrightnessLogind(subsystem, name string, n int64) error {
+	if _, err := exec.LookPath("busctl"); err != nil {
+		return errors.New("busctl not found")
+	}
+	cmd := exec.Command(
+		"busctl", "call", "--system",
+		"org.freedesktop.login1",
+		"/org/freedesktop/login1/session/auto",
+		"org.freedesktop.login1.Session",
+		"SetBrightness", "ssu", subsystem, name, strconv.FormatInt(n, 10),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("SetBrightness failed: %s", msg)
+		}
+		return fmt.Errorf("SetBrightness failed: %s", err)
+	}
+	return nil
+}
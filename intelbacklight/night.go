@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cespare/utils/internal/wl"
+	"golang.org/x/sys/unix"
+)
+
+// dayTemp is the color temperature (in kelvin) that leaves the colors
+// unchanged.
+const dayTemp = 6500
+
+// A clockTime is a time of day given as HH:MM.
+type clockTime struct {
+	s string
+	d time.Duration // since midnight
+}
+
+// Set implements flag.Value.
+func (c *clockTime) Set(s string) error {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return fmt.Errorf("time must be given as HH:MM")
+	}
+	*c = clockTime{s: s, d: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}
+	return nil
+}
+
+func (c *clockTime) String() string { return c.s }
+
+// A nightSchedule ramps between the day temperature and the night
+// temperature starting at sunset and sunrise.
+type nightSchedule struct {
+	sunset  clockTime
+	sunrise clockTime
+	ramp    time.Duration
+}
+
+func (s *nightSchedule) enabled() bool { return s.sunset.s != "" }
+
+// night gives how far into the night it is at t: 0 during the day, 1 at
+// night, and in between during the ramps.
+func (s *nightSchedule) night(t time.Time) float64 {
+	y, m, d := t.Date()
+	now := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	since := func(c clockTime) time.Duration {
+		return ((now-c.d)%(24*time.Hour) + 24*time.Hour) % (24 * time.Hour)
+	}
+	ramp := func(d time.Duration) float64 {
+		if s.ramp <= 0 || d >= s.ramp {
+			return 1
+		}
+		return float64(d) / float64(s.ramp)
+	}
+	// Whichever of sunset and sunrise was most recent says whether it's
+	// night or day.
+	ds, dr := since(s.sunset), since(s.sunrise)
+	if ds < dr {
+		return ramp(ds)
+	}
+	return 1 - ramp(dr)
+}
+
+// whitePoint gives the red, green, and blue multipliers of the color
+// temperature (in kelvin), using Tanner Helland's approximation of the
+// blackbody colors, normalized so that dayTemp is white.
+func whitePoint(kelvin float64) (r, g, b float64) {
+	rgb := func(kelvin float64) (r, g, b float64) {
+		t := kelvin / 100
+		if t <= 66 {
+			r = 255
+			g = 99.4708025861*math.Log(t) - 161.1195681661
+		} else {
+			r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+			g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+		}
+		switch {
+		case t >= 66:
+			b = 255
+		case t <= 19:
+			b = 0
+		default:
+			b = 138.5177312231*math.Log(t-10) - 305.0447927307
+		}
+		return r, g, b
+	}
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1, v)) }
+	r, g, b = rgb(kelvin)
+	r0, g0, b0 := rgb(dayTemp)
+	return clamp(r / r0), clamp(g / g0), clamp(b / b0)
+}
+
+// gammaRamps gives the red, green, and blue gamma ramps (in that order) of
+// the given size for the color temperature.
+func gammaRamps(size int, kelvin float64) []uint16 {
+	r, g, b := whitePoint(kelvin)
+	ramps := make([]uint16, 3*size)
+	for i := 0; i < size; i++ {
+		v := 65535 * float64(i) / float64(size-1)
+		ramps[i] = uint16(math.Round(v * r))
+		ramps[size+i] = uint16(math.Round(v * g))
+		ramps[2*size+i] = uint16(math.Round(v * b))
+	}
+	return ramps
+}
+
+// A gammaControl is a zwlr_gamma_control_v1 object for one output.
+type gammaControl struct {
+	id     uint32
+	output uint32 // the output's global name
+	size   int    // 0 until the compositor sends it
+}
+
+// setGamma sets the gamma ramps of the control for the color temperature.
+func setGamma(c *wl.Conn, gc *gammaControl, kelvin float64) error {
+	fd, err := unix.MemfdCreate("intelbacklight-gamma", unix.MFD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "gamma")
+	defer f.Close()
+	if err := binary.Write(f, binary.LittleEndian, gammaRamps(gc.size, kelvin)); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	return c.RequestFD(gc.id, 0, nil, int(f.Fd()))
+}
+
+const (
+	wlOutputInterface     = "wl_output"
+	gammaManagerInterface = "zwlr_gamma_control_manager_v1"
+)
+
+// cmdNight sets the color temperature of every output to the given
+// temperature or, with -sunset and -sunrise, to a temperature between
+// dayTemp and the given temperature according to the schedule. The
+// compositor restores the colors when this program exits, so it keeps
+// running until it is killed.
+func cmdNight(args []string) {
+	fs := newFlagSet("night", `Usage:
+
+  intelbacklight night [-sunset time -sunrise time [-ramp duration]] [kelvin]
+
+The night command sets the color temperature of every output (using the
+wlr-gamma-control Wayland protocol, which sway and other wlroots-based
+compositors support) to the given temperature (4000K by default). With
+-sunset and -sunrise, it changes from 6500K (the normal colors) to the given
+temperature at sunset each day and back at sunrise. The compositor restores
+the normal colors when intelbacklight exits, so night keeps running until it
+is killed.
+`)
+	var sched nightSchedule
+	fs.Var(&sched.sunset, "sunset", "Start changing to the night color temperature at this `time` (HH:MM) each day")
+	fs.Var(&sched.sunrise, "sunrise", "Start changing back to the day color temperature at this `time` (HH:MM) each day")
+	fs.DurationVar(&sched.ramp, "ramp", time.Hour, "With -sunset and -sunrise, change the color temperature gradually over this `duration`")
+	fs.Parse(args)
+	if (sched.sunset.s == "") != (sched.sunrise.s == "") {
+		log.Fatal("-sunset and -sunrise must be given together")
+	}
+	kelvin := 4000.0
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		k, err := strconv.ParseFloat(fs.Arg(0), 64)
+		if err != nil || k < 1000 || k > 10000 {
+			log.Fatalf("Bad color temperature %q (must be between 1000 and 10000 kelvin)", fs.Arg(0))
+		}
+		kelvin = k
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	temp := func() float64 {
+		if !sched.enabled() {
+			return kelvin
+		}
+		n := sched.night(time.Now())
+		return math.Round(dayTemp + (kelvin-dayTemp)*n)
+	}
+
+	c, err := wl.Dial()
+	if err != nil {
+		log.Fatalln("Cannot connect to the Wayland compositor:", err)
+	}
+	registry, err := c.GetRegistry()
+	if err != nil {
+		log.Fatal(err)
+	}
+	events := make(chan wl.Event)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			e, err := c.ReadEvent()
+			if err != nil {
+				errc <- err
+				return
+			}
+			events <- e
+		}
+	}()
+
+	// Collect the globals that exist now.
+	done, err := c.Sync()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var manager uint32
+	var outputs []uint32
+	handleGlobal := func(e wl.Event) (name uint32, iface string) {
+		r := wl.Reader(e.Args)
+		name, iface = r.ReadUint(), r.ReadString()
+		return name, iface
+	}
+	for done != 0 {
+		var e wl.Event
+		select {
+		case e = <-events:
+		case err := <-errc:
+			log.Fatal(err)
+		}
+		switch {
+		case e.Object == done:
+			if manager == 0 {
+				log.Fatal("The compositor doesn't support wlr-gamma-control")
+			}
+			done = 0
+		case e.Object == registry && e.Opcode == 0:
+			name, iface := handleGlobal(e)
+			switch iface {
+			case wlOutputInterface:
+				outputs = append(outputs, name)
+			case gammaManagerInterface:
+				if manager, err = c.Bind(registry, name, iface, 1); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+
+	controls := make(map[uint32]*gammaControl)
+	addOutput := func(name uint32) {
+		output, err := c.Bind(registry, name, wlOutputInterface, 1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gc := &gammaControl{id: c.NewID(), output: name}
+		var m wl.Message
+		m.PutUint(gc.id)
+		m.PutUint(output)
+		if err := c.Request(manager, 0, m); err != nil {
+			log.Fatal(err)
+		}
+		controls[gc.id] = gc
+	}
+	for _, name := range outputs {
+		addOutput(name)
+	}
+
+	cur := temp()
+	infof("Color temperature: %gK", cur)
+	var tick <-chan time.Time
+	if sched.enabled() {
+		tick = time.NewTicker(30 * time.Second).C
+	}
+	for {
+		select {
+		case e := <-events:
+			if e.Object == registry {
+				name, iface := handleGlobal(e)
+				switch {
+				case e.Opcode == 0 && iface == wlOutputInterface:
+					addOutput(name)
+				case e.Opcode == 1: // global_remove
+					for id, gc := range controls {
+						if gc.output == name {
+							c.Request(id, 1, nil) // destroy
+							delete(controls, id)
+						}
+					}
+				}
+				continue
+			}
+			gc, ok := controls[e.Object]
+			if !ok {
+				continue
+			}
+			switch e.Opcode {
+			case 0: // gamma_size
+				r := wl.Reader(e.Args)
+				gc.size = int(r.ReadUint())
+				if err := setGamma(c, gc, cur); err != nil {
+					log.Fatalln("Error setting gamma:", err)
+				}
+			case 1: // failed
+				log.Printf("Cannot set gamma of output %d (is another program such as wlsunset running?)", gc.output)
+				c.Request(gc.id, 1, nil)
+				delete(controls, gc.id)
+			}
+		case err := <-errc:
+			log.Fatal(err)
+		case <-tick:
+			t := temp()
+			if t == cur {
+				continue
+			}
+			cur = t
+			for _, gc := range controls {
+				if gc.size == 0 {
+					continue
+				}
+				if err := setGamma(c, gc, cur); err != nil {
+					log.Fatalln("Error setting gamma:", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// notifyOSD is set by -notify.
+var notifyOSD bool
+
+// sendOSD shows the brightness of d as a desktop notification with a
+// progress bar, using notify-send. Each notification replaces the previous
+// one (whose ID is recorded in the state directory) rather than piling up.
+func sendOSD(d device) error {
+	cur, max, err := d.brightness()
+	if err != nil {
+		return err
+	}
+	if max <= 0 {
+		return fmt.Errorf("bad maximum brightness %d", max)
+	}
+	pct := int(math.Round(brightnessCurve.toPct(cur, max)))
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	idPath := filepath.Join(dir, "notification")
+	args := []string{
+		"--app-name=intelbacklight",
+		"--print-id",
+		"--hint", fmt.Sprintf("int:value:%d", pct),
+		// dunst and mako also replace notifications that share this
+		// hint, which works even if the ID has been lost.
+		"--hint", "string:x-canonical-private-synchronous:intelbacklight",
+	}
+	if b, err := os.ReadFile(idPath); err == nil {
+		if id, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			args = append(args, "--replace-id", strconv.Itoa(id))
+		}
+	}
+	args = append(args, fmt.Sprintf("Brightness: %d%%", pct))
+	out, err := exec.Command("notify-send", args...).Output()
+	if err != nil {
+		return fmt.Errorf("notify-send failed: %s", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(idPath, out, 0o644)
+}
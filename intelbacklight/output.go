@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// internalOutputPrefixes are the prefixes of the names of laptop panels
+// (whose brightness is that of the backlight).
+var internalOutputPrefixes = []string{"eDP-", "LVDS-", "DSI-"}
+
+// findOutputDevice returns the name of the device that controls the
+// brightness of the sway output with the given name: the backlight for a
+// laptop panel, or otherwise the DDC/CI monitor on the same connector or
+// with the same serial number or model.
+func findOutputDevice(name string) string {
+	ctx := context.Background()
+	client, err := sway.New(ctx)
+	if err != nil {
+		log.Fatalln("Cannot connect to sway:", err)
+	}
+	outputs, err := client.GetOutputs(ctx)
+	if err != nil {
+		log.Fatalln("GET_OUTPUTS failed:", err)
+	}
+	var output *sway.Output
+	for i := range outputs {
+		if outputs[i].Name == name {
+			output = &outputs[i]
+		}
+	}
+	if output == nil {
+		log.Fatalf("No output %q", name)
+	}
+	for _, prefix := range internalOutputPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return findDevice("", false).String()
+		}
+	}
+
+	displays, err := listDDCDisplays()
+	if err != nil {
+		log.Fatalln("Error listing DDC/CI monitors:", err)
+	}
+	for _, d := range displays {
+		if d.connector == name {
+			return d.String()
+		}
+	}
+	if output.Serial != "" && output.Serial != "Unknown" {
+		for _, d := range displays {
+			if d.serial == output.Serial {
+				return d.String()
+			}
+		}
+	}
+	var matches []*ddcDisplay
+	for _, d := range displays {
+		if d.model != "" && d.model == output.Model {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		log.Fatalf("No DDC/CI monitor matches output %s (%s %s)", name, output.Make, output.Model)
+	case 1:
+		return matches[0].String()
+	}
+	log.Fatalf("More than one DDC/CI monitor matches output %s (%s %s)", name, output.Make, output.Model)
+	panic("unreachable")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// onAC reports whether the machine is running on external power. A machine
+// without any external power supplies (a desktop) is always on AC.
+func onAC() bool {
+	dirs, err := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	found := false
+	for _, dir := range dirs {
+		typ, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(typ)) {
+		case "Mains", "USB":
+		default:
+			continue
+		}
+		found = true
+		online, err := os.ReadFile(filepath.Join(dir, "online"))
+		if err == nil && strings.TrimSpace(string(online)) == "1" {
+			return true
+		}
+	}
+	return !found
+}
+
+// updatePower checks whether the machine has been plugged in or unplugged
+// (that is, whether onAC no longer returns ac) and, if so, sets the
+// brightness of the devices to the ac_brightness or battery_brightness from
+// the config file. Unplugging only ever lowers the brightness and plugging
+// in only ever raises it, so that a brightness the user chose isn't undone
+// for no reason. It returns the new value of ac.
+func updatePower(devices []device, cfg *config, ac bool) bool {
+	if onAC() == ac {
+		return ac
+	}
+	ac = !ac
+	infof("On AC: %t", ac)
+	target := cfg.BatteryBrightness
+	if ac {
+		target = cfg.ACBrightness
+	}
+	if target == nil {
+		return ac
+	}
+	for _, d := range devices {
+		cur, max, err := d.brightness()
+		if err != nil {
+			log.Printf("%s: %s", d, err)
+			continue
+		}
+		pct := brightnessCurve.toPct(cur, max)
+		if ac && pct >= *target || !ac && pct <= *target {
+			continue
+		}
+		if err := apply(d, op{kind: "set", pct: *target}); err != nil {
+			log.Printf("%s: %s", d, err)
+		}
+	}
+	return ac
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const lidGlob = "/proc/acpi/button/lid/*/state"
+
+// watchResume sends on the returned channel when the machine resumes from
+// suspend or the lid is opened, which are times that firmware often resets
+// the brightness. It notices a resume by the boot-time clock (which counts
+// time spent suspended) getting ahead of the monotonic clock (which
+// doesn't), and it polls the state of the lid.
+func watchResume() <-chan string {
+	ch := make(chan string)
+	go func() {
+		suspended := func() time.Duration {
+			var boot, mono unix.Timespec
+			if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &boot); err != nil {
+				log.Fatalln("clock_gettime failed:", err)
+			}
+			if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &mono); err != nil {
+				log.Fatalln("clock_gettime failed:", err)
+			}
+			return time.Duration(boot.Nano() - mono.Nano())
+		}
+		lastSuspended := suspended()
+		lastOpen := lidOpen()
+		for range time.Tick(2 * time.Second) {
+			if s := suspended(); s-lastSuspended > time.Second {
+				ch <- "resume"
+				lastSuspended = s
+			}
+			open := lidOpen()
+			if open && !lastOpen {
+				ch <- "lid open"
+			}
+			lastOpen = open
+		}
+	}()
+	return ch
+}
+
+// lidOpen reports whether the laptop lid is open (or there isn't one).
+func lidOpen() bool {
+	paths, _ := filepath.Glob(lidGlob)
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(b), "closed") {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreChanged sets each device whose brightness is not the one recorded
+// in the state file back to the recorded brightness.
+func restoreChanged(devices []device) {
+	state, err := loadState()
+	if err != nil {
+		log.Println("Error loading state:", err)
+		return
+	}
+	for _, d := range devices {
+		saved, ok := state[d.String()]
+		if !ok {
+			continue
+		}
+		cur, _, err := d.brightness()
+		if err != nil {
+			log.Printf("%s: %s", d, err)
+			continue
+		}
+		if cur == saved {
+			continue
+		}
+		if err := apply(d, op{kind: "restore"}); err != nil {
+			log.Printf("%s: %s", d, err)
+		}
+	}
+}
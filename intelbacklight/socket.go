@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// When the daemon is running, the other commands send their changes to it
+// over a unix socket rather than making them themselves, so that changes
+// from keybindings, an idle dimmer, and the daemon's own events are made
+// one at a time rather than racing each other.
+//
+// Each connection carries one request (a line of JSON) and one reply.
+
+// socketFlag is set by the global -socket flag.
+var socketFlag string
+
+// socketPath gives the path of the daemon's socket: the -socket flag or, by
+// default, $XDG_RUNTIME_DIR/intelbacklight.sock. Without either, there is no
+// socket (rather than a shared one in /tmp).
+func socketPath() (string, error) {
+	if socketFlag != "" {
+		return socketFlag, nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR must be defined (to place socket file)")
+	}
+	return filepath.Join(dir, "intelbacklight.sock"), nil
+}
+
+// A socketRequest asks the daemon to apply an op to some devices with the
+// settings given by the client's flags.
+type socketRequest struct {
+	Devices   []string      `json:"devices"`
+	Kind      string        `json:"kind"`
+	Pct       float64       `json:"pct,omitempty"`
+	Name      string        `json:"name,omitempty"`
+	Curve     string        `json:"curve"`
+	Floor     string        `json:"floor"`
+	AllowZero bool          `json:"allow_zero,omitempty"`
+	Steps     []float64     `json:"steps,omitempty"`
+	Wrap      bool          `json:"wrap,omitempty"`
+	Exact     bool          `json:"exact_clamp,omitempty"`
+	Fade      time.Duration `json:"fade,omitempty"`
+	Quiet     bool          `json:"quiet,omitempty"`
+}
+
+type socketReply struct {
+	Log    string `json:"log"` // what the daemon logged while applying the op
+	Failed bool   `json:"failed,omitempty"`
+}
+
+// runRemote asks the daemon, if it's running, to apply o to the devices.
+// It reports whether the daemon handled the request and, if so, whether
+// the op failed for any of the devices.
+func runRemote(devices []device, o op) (handled, failed bool) {
+	path, err := socketPath()
+	if err != nil {
+		return false, false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false, false
+	}
+	defer conn.Close()
+	req := socketRequest{
+		Kind:      o.kind,
+		Pct:       o.pct,
+		Name:      o.name,
+		Curve:     brightnessCurve.String(),
+		Floor:     minBrightness.String(),
+		AllowZero: allowZero,
+		Steps:     brightnessSteps,
+		Wrap:      wrapAround,
+		Exact:     exactClamp,
+		Fade:      fadeDuration,
+		Quiet:     quiet,
+	}
+	for _, d := range devices {
+		req.Devices = append(req.Devices, d.String())
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		log.Fatalln("Error sending request to the daemon:", err)
+	}
+	var reply socketReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		log.Fatalln("Error reading reply from the daemon:", err)
+	}
+	fmt.Fprint(os.Stderr, reply.Log)
+	return true, reply.Failed
+}
+
+// A socketServer applies the ops requested over the socket. Its mutex
+// must be held to change any brightness.
+type socketServer struct {
+	mu sync.Mutex
+}
+
+func (s *socketServer) listen(path string) {
+	// Don't take over the socket of a daemon that's still running.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		log.Fatalf("Another daemon is listening on %s", path)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		log.Fatalln("Error creating socket file:", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatalln("Error listening with socket file:", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Fatalln("Accept error:", err)
+			}
+			go s.handle(conn)
+		}
+	}()
+}
+
+func (s *socketServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req socketRequest
+	var reply socketReply
+	if err := json.Unmarshal(line, &req); err != nil {
+		reply = socketReply{Log: fmt.Sprintf("Bad request: %s\n", err), Failed: true}
+	} else {
+		reply = s.apply(&req)
+	}
+	json.NewEncoder(conn).Encode(reply)
+}
+
+// apply applies the request, returning what was logged.
+func (s *socketServer) apply(req *socketRequest) socketReply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The request's settings apply to this request only.
+	savedCurve, savedFloor, savedAllowZero := brightnessCurve, minBrightness, allowZero
+	savedSteps, savedFade, savedQuiet := brightnessSteps, fadeDuration, quiet
+	savedWrap, savedExact := wrapAround, exactClamp
+	defer func() {
+		brightnessCurve, minBrightness, allowZero = savedCurve, savedFloor, savedAllowZero
+		brightnessSteps, fadeDuration, quiet = savedSteps, savedFade, savedQuiet
+		wrapAround, exactClamp = savedWrap, savedExact
+		log.SetOutput(os.Stderr)
+	}()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	failed := false
+	for _, err := range []error{brightnessCurve.Set(req.Curve), minBrightness.Set(req.Floor)} {
+		if err != nil {
+			log.Print(err)
+			return socketReply{Log: buf.String(), Failed: true}
+		}
+	}
+	quiet = req.Quiet
+	allowZero = req.AllowZero
+	brightnessSteps = req.Steps
+	wrapAround, exactClamp = req.Wrap, req.Exact
+	fadeDuration = req.Fade
+	o := op{kind: req.Kind, pct: req.Pct, name: req.Name}
+	for _, name := range req.Devices {
+		d := lookupDevice(name)
+		if d == nil {
+			log.Printf("No device %q", name)
+			failed = true
+			continue
+		}
+		if err := apply(d, o); err != nil {
+			log.Printf("%s: %s", d, err)
+			failed = true
+		}
+	}
+	return socketReply{Log: buf.String(), Failed: failed}
+}
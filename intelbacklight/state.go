@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stateDir gives the directory $XDG_STATE_HOME/intelbacklight.
+func stateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "intelbacklight"), nil
+}
+
+// The state file records the last brightness set on each device, one
+// "name brightness" line per device.
+func statePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "brightness"), nil
+}
+
+func loadState() (map[string]int64, error) {
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]int64)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		state[name] = n
+	}
+	return state, scanner.Err()
+}
+
+// saveState records n as the brightness of the named device.
+func saveState(name string, n int64) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	state[name] = n
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var names []string
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s %d\n", name, state[name])
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// A levelKey identifies a step up or down from an actual brightness.
+type levelKey struct {
+	device string
+	up     bool
+	from   int64
+}
+
+// The levels file records the brightness levels of panels that quantize the
+// brightness, as learned from the actual brightness after each step: one
+// "name up|down from to" line per step that got from one level to the next.
+func levelsPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "levels"), nil
+}
+
+func loadLevels() (map[levelKey]int64, error) {
+	path, err := levelsPath()
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[levelKey]int64)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return levels, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var name, dir string
+		var from, to int64
+		if _, err := fmt.Sscan(scanner.Text(), &name, &dir, &from, &to); err != nil {
+			continue
+		}
+		levels[levelKey{device: name, up: dir == "up", from: from}] = to
+	}
+	return levels, scanner.Err()
+}
+
+func saveLevels(levels map[levelKey]int64) error {
+	path, err := levelsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var lines []string
+	for k, to := range levels {
+		dir := "down"
+		if k.up {
+			dir = "up"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d %d\n", k.device, dir, k.from, to))
+	}
+	sort.Strings(lines)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
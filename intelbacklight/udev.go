@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const udevRulePath = "/etc/udev/rules.d/90-intelbacklight.rules"
+
+// udevRules gives udev rules that let the group write the brightness of the
+// backlights and keyboard backlights.
+func udevRules(bs []*backlight, group string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by intelbacklight install-udev: let the %s group set the brightness.\n", group)
+	for _, bl := range bs {
+		path := fmt.Sprintf("/sys/class/%s/%%k/brightness", bl.subsystem)
+		fmt.Fprintf(&b,
+			"ACTION==\"add\", SUBSYSTEM==\"%s\", KERNEL==\"%s\", RUN+=\"/bin/chgrp %s %s\", RUN+=\"/bin/chmod g+w %s\"\n",
+			bl.subsystem, bl.name, group, path, path,
+		)
+	}
+	return b.String()
+}
+
+func cmdInstallUdev(args []string) {
+	fs := newFlagSet("install-udev", fmt.Sprintf(`Usage:
+
+  intelbacklight install-udev [-group name] [-print]
+
+The install-udev command writes udev rules to %s that give
+a group write access to the brightness files of the backlights and keyboard
+backlights on this machine, and then applies them. (This is only needed if
+logind can't be used; see -logind.) It uses sudo unless it is run as root.
+`, udevRulePath))
+	group := fs.String("group", "video", "Give write access to this `group`")
+	printOnly := fs.Bool("print", false, "Print the rules instead of installing them")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	bs := append(listBacklights(), listKbdBacklights()...)
+	if len(bs) == 0 {
+		log.Fatal("No backlight devices found")
+	}
+	rules := udevRules(bs, *group)
+	if *printOnly {
+		fmt.Print(rules)
+		return
+	}
+	var sudo []string
+	if os.Geteuid() != 0 {
+		sudo = []string{"sudo"}
+	}
+	runCmd := func(stdin string, args ...string) {
+		args = append(sudo, args...)
+		cmd := exec.Command(args[0], args[1:]...)
+		if stdin != "" {
+			cmd.Stdin = strings.NewReader(stdin)
+		}
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatalf("%s failed: %s", strings.Join(args, " "), err)
+		}
+	}
+	runCmd(rules, "tee", udevRulePath)
+	runCmd("", "udevadm", "control", "--reload")
+	runCmd("", "udevadm", "trigger", "--action=add", "--subsystem-match=backlight", "--subsystem-match=leds")
+	log.Printf("Installed %s", udevRulePath)
+	if !inGroup(*group) {
+		log.Printf("You aren't in the %s group; add yourself with 'sudo usermod -aG %[1]s $USER' and log in again", *group)
+	}
+}
+
+// inGroup reports whether the process is in the named group.
+func inGroup(name string) bool {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return false
+	}
+	gids, err := os.Getgroups()
+	if err != nil {
+		return false
+	}
+	for _, gid := range gids {
+		if strconv.Itoa(gid) == g.Gid {
+			return true
+		}
+	}
+	return false
+}
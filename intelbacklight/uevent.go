@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"log"
+
+	"golang.org/x/sys/unix"
+)
+
+// A uevent is a kernel device event, such as a power supply going online or
+// a monitor being connected. It maps keys (such as ACTION and SUBSYSTEM) to
+// values.
+type uevent map[string]string
+
+// parseUevent parses a kernel uevent message: a header ("action@devpath")
+// followed by KEY=VALUE fields, all NUL-terminated.
+func parseUevent(b []byte) uevent {
+	e := make(uevent)
+	for i, field := range bytes.Split(b, []byte{0}) {
+		if i == 0 {
+			continue
+		}
+		if k, v, ok := bytes.Cut(field, []byte("=")); ok {
+			e[string(k)] = string(v)
+		}
+	}
+	return e
+}
+
+// watchUevents sends each kernel uevent on the returned channel.
+func watchUevents() <-chan uevent {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		log.Fatalln("Cannot open uevent socket:", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		log.Fatalln("Cannot bind uevent socket:", err)
+	}
+	ch := make(chan uevent)
+	go func() {
+		buf := make([]byte, 8192)
+		for {
+			n, err := unix.Read(fd, buf)
+			if err == unix.EINTR || err == unix.ENOBUFS {
+				continue
+			}
+			if err != nil {
+				log.Fatalln("Error reading uevents:", err)
+			}
+			ch <- parseUevent(buf[:n])
+		}
+	}()
+	return ch
+}
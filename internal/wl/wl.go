@@ -0,0 +1,206 @@
+// Package wl is a minimal Wayland client: just enough of the wire protocol
+// to bind globals and exchange messages with them, for the tools that speak
+// wlroots protocols (intelbacklight's night light and swayctrl's toplevel
+// backend). Wayland messages are in the host's byte order, which this
+// assumes is little-endian.
+package wl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// A Conn is a connection to the Wayland compositor. Requests may be sent
+// from multiple goroutines; events must be read from one.
+type Conn struct {
+	conn *net.UnixConn
+
+	mu     sync.Mutex // serializes requests
+	nextID uint32
+}
+
+// An Event is a message from the compositor.
+type Event struct {
+	Object uint32
+	Opcode uint16
+	Args   []byte
+}
+
+// DisplayID is the object ID of wl_display, which is always 1.
+const DisplayID = 1
+
+// Dial connects to the compositor at $WAYLAND_DISPLAY (by default,
+// wayland-0 in $XDG_RUNTIME_DIR).
+func Dial() (*Conn, error) {
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		name = "wayland-0"
+	}
+	if !filepath.IsAbs(name) {
+		dir := os.Getenv("XDG_RUNTIME_DIR")
+		if dir == "" {
+			return nil, errors.New("XDG_RUNTIME_DIR is not set")
+		}
+		name = filepath.Join(dir, name)
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn), nil
+}
+
+func newConn(conn *net.UnixConn) *Conn {
+	return &Conn{conn: conn, nextID: DisplayID + 1}
+}
+
+func (c *Conn) Close() error { return c.conn.Close() }
+
+// NewID allocates an object ID.
+func (c *Conn) NewID() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// A Message builds the arguments of a request.
+type Message []byte
+
+func (m *Message) PutUint(v uint32) {
+	*m = binary.LittleEndian.AppendUint32(*m, v)
+}
+
+func (m *Message) PutString(s string) {
+	m.PutUint(uint32(len(s) + 1))
+	*m = append(*m, s...)
+	*m = append(*m, 0)
+	for len(*m)%4 != 0 {
+		*m = append(*m, 0)
+	}
+}
+
+// Request sends a request to the object.
+func (c *Conn) Request(object uint32, opcode uint16, args Message) error {
+	return c.RequestFD(object, opcode, args, -1)
+}
+
+// RequestFD is like Request, but if fd is not -1, it is passed along with
+// the message.
+func (c *Conn) RequestFD(object uint32, opcode uint16, args Message, fd int) error {
+	msg := make([]byte, 8, 8+len(args))
+	binary.LittleEndian.PutUint32(msg, object)
+	binary.LittleEndian.PutUint32(msg[4:], uint32(8+len(args))<<16|uint32(opcode))
+	msg = append(msg, args...)
+	var oob []byte
+	if fd >= 0 {
+		oob = unix.UnixRights(fd)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _, err := c.conn.WriteMsgUnix(msg, oob, nil)
+	return err
+}
+
+// ReadEvent reads the next event from the compositor. It returns an error
+// if the event is a wl_display error.
+func (c *Conn) ReadEvent() (Event, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+		return Event{}, err
+	}
+	e := Event{Object: binary.LittleEndian.Uint32(hdr[:])}
+	sizeOp := binary.LittleEndian.Uint32(hdr[4:])
+	e.Opcode = uint16(sizeOp)
+	size := int(sizeOp >> 16)
+	if size < 8 {
+		return Event{}, fmt.Errorf("bad Wayland message size %d", size)
+	}
+	e.Args = make([]byte, size-8)
+	if _, err := io.ReadFull(c.conn, e.Args); err != nil {
+		return Event{}, err
+	}
+	if e.Object == DisplayID && e.Opcode == 0 {
+		// wl_display.error(object, code, message)
+		r := Reader(e.Args)
+		object, code := r.ReadUint(), r.ReadUint()
+		return Event{}, fmt.Errorf("Wayland error (object %d, code %d): %s", object, code, r.ReadString())
+	}
+	return e, nil
+}
+
+// A Reader reads the arguments of an event.
+type Reader []byte
+
+func (r *Reader) ReadUint() uint32 {
+	if len(*r) < 4 {
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(*r)
+	*r = (*r)[4:]
+	return v
+}
+
+func (r *Reader) ReadString() string {
+	n := int(r.ReadUint())
+	padded := (n + 3) &^ 3
+	if n == 0 || len(*r) < padded {
+		return ""
+	}
+	s := string((*r)[:n-1])
+	*r = (*r)[padded:]
+	return s
+}
+
+// ReadUintArray reads an array argument of uint32s.
+func (r *Reader) ReadUintArray() []uint32 {
+	n := int(r.ReadUint())
+	padded := (n + 3) &^ 3
+	if len(*r) < padded {
+		return nil
+	}
+	a := make([]uint32, n/4)
+	for i := range a {
+		a[i] = binary.LittleEndian.Uint32((*r)[4*i:])
+	}
+	*r = (*r)[padded:]
+	return a
+}
+
+// GetRegistry sends wl_display.get_registry and returns the registry's ID.
+func (c *Conn) GetRegistry() (uint32, error) {
+	id := c.NewID()
+	var m Message
+	m.PutUint(id)
+	return id, c.Request(DisplayID, 1, m)
+}
+
+// Sync sends wl_display.sync. The compositor sends wl_callback.done on the
+// returned object once it has handled all the earlier requests.
+func (c *Conn) Sync() (uint32, error) {
+	id := c.NewID()
+	var m Message
+	m.PutUint(id)
+	return id, c.Request(DisplayID, 0, m)
+}
+
+// Bind sends wl_registry.bind for the global with the given name and
+// returns the new object's ID.
+func (c *Conn) Bind(registry, name uint32, iface string, version uint32) (uint32, error) {
+	id := c.NewID()
+	var m Message
+	m.PutUint(name)
+	m.PutString(iface)
+	m.PutUint(version)
+	m.PutUint(id)
+	return id, c.Request(registry, 0, m)
+}
@@ -0,0 +1,113 @@
+package wl
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+// connPair returns two Conns connected to each other. Since requests and
+// events have the same format, each can read what the other sends.
+func connPair(t *testing.T) (*Conn, *Conn) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns [2]*Conn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "wl")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = newConn(c.(*net.UnixConn))
+		t.Cleanup(func() { c.Close() })
+	}
+	return conns[0], conns[1]
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	client, server := connPair(t)
+	var m Message
+	m.PutUint(7)
+	m.PutString("zwlr_foreign_toplevel_manager_v1")
+	m.PutUint(12) // the array's size in bytes
+	for _, v := range []uint32{1, 2, 3} {
+		m.PutUint(v)
+	}
+	m.PutString("")
+	if err := client.Request(5, 3, m); err != nil {
+		t.Fatal(err)
+	}
+	e, err := server.ReadEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Object != 5 || e.Opcode != 3 {
+		t.Fatalf("got object %d, opcode %d; want 5, 3", e.Object, e.Opcode)
+	}
+	r := Reader(e.Args)
+	if got := r.ReadUint(); got != 7 {
+		t.Errorf("got uint %d; want 7", got)
+	}
+	if got, want := r.ReadString(), "zwlr_foreign_toplevel_manager_v1"; got != want {
+		t.Errorf("got string %q; want %q", got, want)
+	}
+	if got, want := r.ReadUintArray(), []uint32{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("got array %v; want %v", got, want)
+	}
+	if got := r.ReadString(); got != "" {
+		t.Errorf("got string %q; want empty", got)
+	}
+	if len(r) != 0 {
+		t.Errorf("%d bytes left over", len(r))
+	}
+}
+
+func TestRequestFD(t *testing.T) {
+	client, server := connPair(t)
+	f, err := os.CreateTemp(t.TempDir(), "ramp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := client.RequestFD(9, 0, nil, int(f.Fd())); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := server.conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("got control messages %v (err %v); want 1", msgs, err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("got fds %v (err %v); want 1", fds, err)
+	}
+	unix.Close(fds[0])
+}
+
+func TestDisplayError(t *testing.T) {
+	client, server := connPair(t)
+	var m Message
+	m.PutUint(12)
+	m.PutUint(1)
+	m.PutString("invalid seat")
+	if err := server.Request(DisplayID, 0, m); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.ReadEvent()
+	if err == nil || !strings.Contains(err.Error(), "object 12, code 1): invalid seat") {
+		t.Errorf("got error %v; want a Wayland error", err)
+	}
+}
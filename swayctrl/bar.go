@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/exp/slices"
+)
+
+func cmdBar(args []string) {
+	fs := flag.NewFlagSet("bar", flag.ExitOnError)
+	output := fs.String("output", "", "Output whose bars are changed (default: the focused output)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl bar [-output name] toggle
+  swayctrl bar [-output name] mode dock|hide|invisible|overlay
+
+The bar command changes the mode of the bars displayed on an output (by
+default, the focused one). The toggle subcommand switches bars between dock and
+hide modes.
+
+Note that sway sets the mode per bar, not per output, so a bar that isn't
+restricted to particular outputs in the sway config is changed everywhere.
+`)
+	}
+	fs.Parse(args)
+
+	var mode string
+	switch {
+	case fs.NArg() == 1 && fs.Arg(0) == "toggle":
+	case fs.NArg() == 2 && fs.Arg(0) == "mode":
+		mode = fs.Arg(1)
+		switch mode {
+		case "dock", "hide", "invisible", "overlay":
+		default:
+			log.Fatalf("Bad bar mode %q", mode)
+		}
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	if *output == "" {
+		*output = focusedOutput(ctx, client)
+	}
+	ids, err := client.GetBarIDs(ctx)
+	if err != nil {
+		log.Fatalln("GET_BAR_CONFIG failed:", err)
+	}
+	var matched bool
+	for _, id := range ids {
+		// go-sway's BarConfig doesn't include the outputs field.
+		var bar struct {
+			Mode    string   `json:"mode"`
+			Outputs []string `json:"outputs"`
+		}
+		if err := ipcQuery(ctx, ipcGetBarConfig, id, &bar); err != nil {
+			log.Fatalln("GET_BAR_CONFIG failed:", err)
+		}
+		if len(bar.Outputs) > 0 && !slices.Contains(bar.Outputs, "*") && !slices.Contains(bar.Outputs, *output) {
+			continue
+		}
+		matched = true
+		newMode := mode
+		if newMode == "" {
+			newMode = "hide"
+			if bar.Mode != "dock" {
+				newMode = "dock"
+			}
+		}
+		command := fmt.Sprintf("bar %q mode %s", id, newMode)
+		if err := runCommand(ctx, client, command); err != nil {
+			log.Fatalf("Error running command %q: %s", command, err)
+		}
+	}
+	if !matched {
+		log.Fatalf("No bars are displayed on output %s", *output)
+	}
+}
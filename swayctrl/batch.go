@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cespare/subcmd"
+	"github.com/joshuarubin/go-sway"
+)
+
+// These are used to share connections between the commands run by batch.
+var (
+	inBatch     bool
+	batchClient sway.Client
+	batchDaemon *daemonConn
+)
+
+// rootRunner runs the top-level commands. (It is set by main to avoid an
+// initialization cycle with cmds.)
+var rootRunner *subcmd.Runner
+
+func cmdBatch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl batch [file]
+
+The batch command runs swayctrl commands read one per line from the file (or
+from stdin if no file is given). All the commands share a single sway IPC
+connection and a single connection to the daemon, which is much faster than
+running swayctrl once per command. For example:
+
+  focus -appid firefox
+  focus-follows-mouse no
+  display off DP-1
+
+Arguments may be quoted with single or double quotes. Blank lines and lines
+beginning with # are ignored. Commands run in order and the batch stops at the
+first one that fails.
+`)
+	}
+	fs.Parse(args)
+
+	var r io.Reader
+	switch fs.NArg() {
+	case 0:
+		r = os.Stdin
+	case 1:
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	if inBatch {
+		log.Fatal("Cannot run batch within a batch")
+	}
+	inBatch = true
+	defer func() {
+		if batchDaemon != nil {
+			batchDaemon.Close()
+		}
+	}()
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmdArgs, err := splitArgs(line)
+		if err != nil {
+			log.Fatalf("Line %d: %s", lineNum, err)
+		}
+		rootRunner.Run(cmdArgs)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalln("Error reading commands:", err)
+	}
+}
+
+// batchNewClient returns the shared sway client, creating it if needed.
+func batchNewClient(ctx context.Context) sway.Client {
+	if batchClient == nil {
+		client, err := sway.New(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		batchClient = wrapClient(client)
+	}
+	return batchClient
+}
+
+// batchDialDaemon returns the shared daemon connection, creating it if needed.
+func batchDialDaemon() (*daemonConn, error) {
+	if batchDaemon == nil {
+		c, err := dialDaemon()
+		if err != nil {
+			return nil, err
+		}
+		batchDaemon = c
+	}
+	return batchDaemon, nil
+}
+
+// splitArgs splits a line into arguments separated by spaces. An argument may
+// be quoted with single or double quotes (with no escapes).
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var inArg bool
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
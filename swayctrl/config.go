@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// config is the (optional) swayctrl configuration file, which lives at
+// $XDG_CONFIG_HOME/swayctrl/config.json.
+type config struct {
+	// Menu is a dmenu-style command (passed to /bin/sh -c) used to prompt
+	// for input. It reads choices from stdin and prints the selection.
+	Menu string `json:"menu"`
+
+	// OutputProfiles are the profiles used by 'swayctrl output profile'
+	// and the daemon.
+	OutputProfiles []outputProfile `json:"output_profiles"`
+
+	// Notify lists the rules for the daemon's desktop notifications.
+	Notify []notifyRule `json:"notify"`
+
+	// FloatPresets are the window sizes and positions used by
+	// 'swayctrl float-toggle'.
+	FloatPresets []floatPreset `json:"float_presets"`
+
+	// SwallowParents are the app IDs of the windows (usually terminals)
+	// that the daemon hides when they launch another window.
+	SwallowParents []string `json:"swallow_parents"`
+
+	// TitleTriggers are the daemon's rules for window title changes.
+	TitleTriggers []titleTrigger `json:"title_triggers"`
+}
+
+func defaultConfig() *config {
+	return &config{
+		Menu: "wofi --dmenu",
+	}
+}
+
+// loadConfig reads the config file, if it exists, on top of the defaults.
+func loadConfig() *config {
+	cfg, err := readConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+func readConfig() (*config, error) {
+	cfg := defaultConfig()
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("error establishing config dir: %s", err)
+	}
+	path := filepath.Join(dir, "swayctrl", "config.json")
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %s", err)
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %s", path, err)
+	}
+	return cfg, nil
+}
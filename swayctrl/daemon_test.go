@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+func windowEvent(change sway.WindowEventChange, id int64, appID string) sway.WindowEvent {
+	return sway.WindowEvent{Change: change, Container: *testWindow(id, appID, "")}
+}
+
+func workspaceEvent(change sway.WorkspaceEventChange, name string) sway.WorkspaceEvent {
+	return sway.WorkspaceEvent{Change: change, Current: testWorkspace(100, name)}
+}
+
+func TestDaemonWindowEvents(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		max    int
+		events []sway.WindowEvent
+		want   []listWindow
+	}{
+		{
+			name: "focus",
+			max:  10,
+			events: []sway.WindowEvent{
+				windowEvent(sway.WindowFocus, 10, "foot"),
+				windowEvent(sway.WindowFocus, 11, "firefox"),
+				windowEvent(sway.WindowFocus, 10, "foot"),
+			},
+			want: []listWindow{{10, "foot"}, {11, "firefox"}},
+		},
+		{
+			name: "missing app id",
+			max:  10,
+			events: []sway.WindowEvent{
+				windowEvent(sway.WindowFocus, 10, ""),
+			},
+			want: []listWindow{{10, "?"}},
+		},
+		{
+			name: "close",
+			max:  10,
+			events: []sway.WindowEvent{
+				windowEvent(sway.WindowFocus, 10, "foot"),
+				windowEvent(sway.WindowFocus, 11, "firefox"),
+				windowEvent(sway.WindowClose, 11, "firefox"),
+			},
+			want: []listWindow{{10, "foot"}},
+		},
+		{
+			name: "other changes are ignored",
+			max:  10,
+			events: []sway.WindowEvent{
+				windowEvent(sway.WindowFocus, 10, "foot"),
+				windowEvent(sway.WindowNew, 11, "firefox"),
+				windowEvent(sway.WindowTitle, 11, "firefox"),
+				windowEvent(sway.WindowMove, 11, "firefox"),
+			},
+			want: []listWindow{{10, "foot"}},
+		},
+		{
+			name: "eviction",
+			max:  2,
+			events: []sway.WindowEvent{
+				windowEvent(sway.WindowFocus, 10, "foot"),
+				windowEvent(sway.WindowFocus, 11, "firefox"),
+				windowEvent(sway.WindowFocus, 12, "mpv"),
+				windowEvent(sway.WindowClose, 10, "foot"),
+			},
+			want: []listWindow{{12, "mpv"}, {11, "firefox"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestDaemon(newFakeSway(testTree()), tt.max)
+			for _, e := range tt.events {
+				h.Window(context.Background(), e)
+			}
+			var got []listWindow
+			if _, err := callRPC(h, "mru", nil, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonCycle(t *testing.T) {
+	ctx := context.Background()
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	mru := func() []listWindow {
+		t.Helper()
+		var windows []listWindow
+		if _, err := callRPC(h, "mru", nil, &windows); err != nil {
+			t.Fatal(err)
+		}
+		return windows
+	}
+
+	h.Window(ctx, windowEvent(sway.WindowFocus, 10, "foot"))
+	h.Window(ctx, windowEvent(sway.WindowFocus, 11, "firefox"))
+	h.Window(ctx, windowEvent(sway.WindowFocus, 12, "mpv"))
+	start := []listWindow{{12, "mpv"}, {11, "firefox"}, {10, "foot"}}
+
+	var got []listWindow
+	if _, err := callRPC(h, "cycle.begin", nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, start) {
+		t.Fatalf("cycle.begin: got %v; want %v", got, start)
+	}
+	h.Window(ctx, windowEvent(sway.WindowFocus, 11, "firefox"))
+	h.Window(ctx, windowEvent(sway.WindowFocus, 10, "foot"))
+	if got := mru(); !slices.Equal(got, start) {
+		t.Fatalf("MRU changed during cycle: got %v; want %v", got, start)
+	}
+	if _, err := callRPC(h, "cycle.commit", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []listWindow{{10, "foot"}, {12, "mpv"}, {11, "firefox"}}
+	if got := mru(); !slices.Equal(got, want) {
+		t.Fatalf("after cycle.commit: got %v; want %v", got, want)
+	}
+
+	// If the window landed on during a cycle closes, commit leaves the
+	// order alone.
+	if _, err := callRPC(h, "cycle.begin", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	h.Window(ctx, windowEvent(sway.WindowFocus, 11, "firefox"))
+	h.Window(ctx, windowEvent(sway.WindowClose, 11, "firefox"))
+	if _, err := callRPC(h, "cycle.commit", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	want = []listWindow{{10, "foot"}, {12, "mpv"}}
+	if got := mru(); !slices.Equal(got, want) {
+		t.Fatalf("after closing the cycled-to window: got %v; want %v", got, want)
+	}
+}
+
+func TestDaemonMode(t *testing.T) {
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	var mode string
+	if _, err := callRPC(h, "mode", nil, &mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "default" {
+		t.Fatalf("initial mode: got %q; want %q", mode, "default")
+	}
+	h.Mode(context.Background(), sway.ModeEvent{Change: "resize"})
+	if _, err := callRPC(h, "mode", nil, &mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "resize" {
+		t.Fatalf("got mode %q; want %q", mode, "resize")
+	}
+}
+
+func TestDaemonRestoreLabel(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeSway(testTree())
+	h := newTestDaemon(fake, 10)
+
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceRename, "3:mail"))
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceInit, "4"))
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceInit, "3:mail"))
+	if got := fake.ran(); len(got) > 0 {
+		t.Fatalf("unexpected commands: %q", got)
+	}
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceInit, "3"))
+	want := []string{`rename workspace "3" to "3:mail"`}
+	if got := fake.ran(); !slices.Equal(got, want) {
+		t.Fatalf("got commands %q; want %q", got, want)
+	}
+
+	// Clearing the label forgets it.
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceRename, "3"))
+	h.Workspace(ctx, workspaceEvent(sway.WorkspaceInit, "3"))
+	if got := fake.ran(); len(got) > 0 {
+		t.Fatalf("unexpected commands after clearing label: %q", got)
+	}
+}
+
+func TestDaemonMoveFollowers(t *testing.T) {
+	follower := testWindow(10, "mpv", "video")
+	follower.Marks = []string{followMark + "10"}
+	fake := newFakeSway(testTree(
+		testWorkspace(3, "1", follower, testWindow(11, "foot", "")),
+		testWorkspace(4, "2", testWindow(12, "firefox", "")),
+	))
+	h := newTestDaemon(fake, 10)
+
+	h.Workspace(context.Background(), workspaceEvent(sway.WorkspaceFocus, "1"))
+	if got := fake.ran(); len(got) > 0 {
+		t.Fatalf("follower already on the workspace but got commands %q", got)
+	}
+	h.Workspace(context.Background(), workspaceEvent(sway.WorkspaceFocus, "2"))
+	want := []string{`[con_id=10] move container to workspace "2"`}
+	if got := fake.ran(); !slices.Equal(got, want) {
+		t.Fatalf("got commands %q; want %q", got, want)
+	}
+}
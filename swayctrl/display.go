@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func cmdDisplay(args []string) {
+	fs := flag.NewFlagSet("display", flag.ExitOnError)
+	allButFocused := fs.Bool("all-but-focused", false, "Apply to all outputs except the focused one")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl display [-all-but-focused] off|on|toggle [output]
+
+The display command turns the power to outputs off or on (as with the sway
+'output <name> power' command). It applies to the named output or, if none is
+given, to all outputs. If -all-but-focused is given, it applies to all outputs
+except the focused one.
+
+The toggle subcommand turns the outputs off if any of them is on and turns
+them on otherwise.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	action := fs.Arg(0)
+	switch action {
+	case "off", "on", "toggle":
+	default:
+		log.Fatalf("Bad display action %q (must be off, on, or toggle)", action)
+	}
+	var name string
+	if fs.NArg() == 2 {
+		if *allButFocused {
+			log.Fatal("An output cannot be given with -all-but-focused")
+		}
+		name = fs.Arg(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	// go-sway's Output doesn't include the power field.
+	var outputs []struct {
+		Name   string `json:"name"`
+		Active bool   `json:"active"`
+		Power  bool   `json:"power"`
+	}
+	if err := ipcQuery(ctx, ipcGetOutputs, "", &outputs); err != nil {
+		log.Fatalln("GET_OUTPUTS failed:", err)
+	}
+	var focused string
+	if *allButFocused {
+		focused = focusedOutput(ctx, client)
+	}
+	var names []string
+	var anyOn bool
+	for _, o := range outputs {
+		if !o.Active {
+			continue
+		}
+		if name != "" && o.Name != name {
+			continue
+		}
+		if o.Name == focused {
+			continue
+		}
+		names = append(names, o.Name)
+		if o.Power {
+			anyOn = true
+		}
+	}
+	if len(names) == 0 {
+		if name != "" {
+			log.Fatalf("No active output named %q", name)
+		}
+		log.Fatal("No matching outputs")
+	}
+	if action == "toggle" {
+		action = "on"
+		if anyOn {
+			action = "off"
+		}
+	}
+	for _, name := range names {
+		command := fmt.Sprintf("output %q power %s", name, action)
+		if err := runCommand(ctx, client, command); err != nil {
+			log.Fatalf("Error running command %q: %s", command, err)
+		}
+	}
+}
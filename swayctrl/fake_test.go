@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// fakeSway is an in-memory swayClient. It serves a fixed tree and records
+// the commands it is asked to run (without applying them).
+type fakeSway struct {
+	mu       sync.Mutex
+	root     *sway.Node
+	commands []string
+}
+
+func newFakeSway(root *sway.Node) *fakeSway {
+	return &fakeSway{root: root}
+}
+
+func (f *fakeSway) GetTree(ctx context.Context) (*sway.Node, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.root, nil
+}
+
+// GetWorkspaces derives the workspaces from the tree. A workspace is visible
+// if it is the first one on its output and focused if it contains the
+// focused node.
+func (f *fakeSway) GetWorkspaces(ctx context.Context) ([]sway.Workspace, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var workspaces []sway.Workspace
+	for _, o := range f.root.Nodes {
+		for i, ws := range o.Nodes {
+			num, _, ok := parseWorkspaceName(ws.Name)
+			if !ok {
+				num = -1
+			}
+			workspaces = append(workspaces, sway.Workspace{
+				Num:     int64(num),
+				Name:    ws.Name,
+				Visible: i == 0,
+				Focused: ws.FocusedNode() != nil,
+				Output:  o.Name,
+			})
+		}
+	}
+	return workspaces, nil
+}
+
+func (f *fakeSway) RunCommand(ctx context.Context, command string) ([]sway.RunCommandReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, command)
+	n := strings.Count(command, ";") + 1
+	replies := make([]sway.RunCommandReply, n)
+	for i := range replies {
+		replies[i].Success = true
+	}
+	return replies, nil
+}
+
+// ran returns the commands run so far and forgets them.
+func (f *fakeSway) ran() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := f.commands
+	f.commands = nil
+	return commands
+}
+
+// newTestDaemon returns a daemon handler using fake for all of its sway
+// connections.
+func newTestDaemon(fake *fakeSway, mruSize int) *daemonHandler {
+	h := newDaemonHandler(fake, defaultConfig(), false, mruSize)
+	h.dial = func(context.Context) (swayClient, error) { return fake, nil }
+	return h
+}
+
+// testTree builds a tree with a single output holding workspaces.
+func testTree(workspaces ...*sway.Node) *sway.Node {
+	return &sway.Node{
+		ID:   1,
+		Name: "root",
+		Type: sway.NodeRoot,
+		Nodes: []*sway.Node{{
+			ID:    2,
+			Name:  "eDP-1",
+			Type:  sway.NodeOutput,
+			Nodes: workspaces,
+		}},
+	}
+}
+
+func testWorkspace(id int64, name string, windows ...*sway.Node) *sway.Node {
+	return &sway.Node{
+		ID:    id,
+		Name:  name,
+		Type:  sway.NodeWorkspace,
+		Nodes: windows,
+	}
+}
+
+func testWindow(id int64, appID, title string) *sway.Node {
+	return &sway.Node{
+		ID:    id,
+		Name:  title,
+		Type:  sway.NodeCon,
+		AppID: &appID,
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+// A floatPreset is a size and position given to a window by float-toggle.
+type floatPreset struct {
+	Name string `json:"name"`
+
+	// AppID, if set, makes this the default preset for windows with
+	// this app ID (or X11 class). A preset with neither a name nor an app
+	// ID is the default for all other windows.
+	AppID string `json:"app_id"`
+
+	// Width and Height are as in the sway 'resize set' command (for
+	// example, "800 px" or "50 ppt").
+	Width  string `json:"width"`
+	Height string `json:"height"`
+
+	// Position is as in the sway 'move position' command (for example,
+	// "center", "cursor", or "10 ppt 10 ppt").
+	Position string `json:"position"`
+}
+
+func (p *floatPreset) commands() []string {
+	var cmds []string
+	if p.Width != "" && p.Height != "" {
+		cmds = append(cmds, fmt.Sprintf("resize set width %s height %s", p.Width, p.Height))
+	}
+	if p.Position != "" {
+		cmds = append(cmds, "move position "+p.Position)
+	}
+	return cmds
+}
+
+func findFloatPreset(presets []floatPreset, name, appID string) *floatPreset {
+	if name != "" {
+		for i := range presets {
+			if presets[i].Name == name {
+				return &presets[i]
+			}
+		}
+		log.Fatalf("No float preset named %q", name)
+	}
+	for i := range presets {
+		if presets[i].AppID != "" && presets[i].AppID == appID {
+			return &presets[i]
+		}
+	}
+	for i := range presets {
+		if presets[i].Name == "" && presets[i].AppID == "" {
+			return &presets[i]
+		}
+	}
+	return nil
+}
+
+// These mark prefixes record the position of a window that float-toggle
+// made floating: the window belongs after the container marked
+// floatAfterMark+id or before the one marked floatBeforeMark+id.
+// (Marks that start with an underscore aren't displayed by sway.)
+const (
+	floatAfterMark  = "_swayctrl_float_after_"
+	floatBeforeMark = "_swayctrl_float_before_"
+)
+
+func cmdFloatToggle(args []string) {
+	fs := flag.NewFlagSet("float-toggle", flag.ExitOnError)
+	presetName := fs.String("preset", "", "Name of the float preset to apply")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl float-toggle [-preset name]
+
+The float-toggle command toggles the focused window between tiling and floating.
+
+When the window becomes floating, it is given the size and position of a preset
+from the float_presets list in the config file: the one named by -preset or,
+by default, the first preset for the window's app ID or the first preset with
+no name or app ID. For example:
+
+  "float_presets": [
+    {"width": "60 ppt", "height": "70 ppt", "position": "center"},
+    {"app_id": "mpv", "width": "640 px", "height": "360 px", "position": "95 ppt 5 ppt"}
+  ]
+
+When the window is tiled again, it is returned to where it was before (next to
+the same sibling container) rather than wherever sway would put it.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	focused := root.FocusedNode()
+	if focused == nil {
+		log.Fatal("No focused node")
+	}
+	switch focused.Type {
+	case sway.NodeCon:
+		preset := findFloatPreset(loadConfig().FloatPresets, *presetName, nodeAppID(focused))
+		makeFloating(ctx, client, root, focused, preset)
+	case sway.NodeFloatingCon:
+		makeTiled(ctx, client, root, focused)
+	default:
+		log.Fatal("Focused node is not a window")
+	}
+}
+
+func makeFloating(ctx context.Context, client sway.Client, root, n *sway.Node, preset *floatPreset) {
+	if parent := findParent(root, n.ID); parent != nil {
+		i := slices.IndexFunc(parent.Nodes, func(c *sway.Node) bool { return c.ID == n.ID })
+		var mark string
+		var sibling *sway.Node
+		switch {
+		case i > 0:
+			mark, sibling = floatAfterMark, parent.Nodes[i-1]
+		case i == 0 && len(parent.Nodes) > 1:
+			mark, sibling = floatBeforeMark, parent.Nodes[1]
+		}
+		if sibling != nil {
+			command := fmt.Sprintf("[con_id=%d] mark --add %s%d", sibling.ID, mark, n.ID)
+			if err := runCommand(ctx, client, command); err != nil {
+				log.Fatalf("Error running command %q: %s", command, err)
+			}
+		}
+	}
+	cmds := []string{"floating enable"}
+	if preset != nil {
+		cmds = append(cmds, preset.commands()...)
+	}
+	command := fmt.Sprintf("[con_id=%d] %s", n.ID, strings.Join(cmds, ", "))
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+func makeTiled(ctx context.Context, client sway.Client, root, n *sway.Node) {
+	after := fmt.Sprintf("%s%d", floatAfterMark, n.ID)
+	before := fmt.Sprintf("%s%d", floatBeforeMark, n.ID)
+	var mark, fixup string
+	walkTree(root, func(m *sway.Node) {
+		switch {
+		case slices.Contains(m.Marks, after):
+			mark = after
+		case slices.Contains(m.Marks, before):
+			mark = before
+			// After moving next to the marked sibling, swap places.
+			fixup = "move left"
+			if parent := findParent(root, m.ID); parent != nil {
+				switch parent.Layout {
+				case sway.LayoutSplitV, sway.LayoutStacked:
+					fixup = "move up"
+				}
+			}
+		}
+	})
+	cmds := []string{"floating disable"}
+	if mark != "" {
+		cmds = append(cmds, "move container to mark "+mark)
+		if fixup != "" {
+			cmds = append(cmds, fixup)
+		}
+	}
+	cmds = append(cmds, "focus")
+	command := fmt.Sprintf("[con_id=%d] %s", n.ID, strings.Join(cmds, ", "))
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+	if mark != "" {
+		command := "unmark " + mark
+		if err := runCommand(ctx, client, command); err != nil {
+			log.Fatalf("Error running command %q: %s", command, err)
+		}
+	}
+}
+
+// findParent returns the parent of the node with the given ID, or nil if
+// there is no such node (or it is root).
+func findParent(root *sway.Node, id int64) *sway.Node {
+	var parent *sway.Node
+	walkTree(root, func(n *sway.Node) {
+		for _, c := range n.Nodes {
+			if c.ID == id {
+				parent = n
+			}
+		}
+		for _, c := range n.FloatingNodes {
+			if c.ID == id {
+				parent = n
+			}
+		}
+	})
+	return parent
+}
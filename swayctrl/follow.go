@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+// followMark is the prefix of the marks (followMark+con_id) on windows that
+// follow the focused workspace.
+const followMark = "_swayctrl_follow_"
+
+func cmdFollow(args []string) {
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl follow [toggle|on|off]
+
+The follow command marks the focused window (or, with off, unmarks it) so that
+it follows the focused workspace: whenever another workspace is focused, the
+daemon moves the window there. This is handy for a small floating video or notes
+window. With no argument (or toggle), the mark is toggled. The daemon must be
+running.
+`)
+	}
+	fs.Parse(args)
+
+	action := "toggle"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		action = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	var markCmd string
+	switch action {
+	case "toggle":
+		markCmd = "mark --add --toggle"
+	case "on":
+		markCmd = "mark --add"
+	case "off":
+		markCmd = "unmark"
+	default:
+		log.Fatalf("Bad follow action %q", action)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	focused := root.FocusedNode()
+	if focused == nil || !isWindow(focused) {
+		log.Fatal("Focused node is not a window")
+	}
+	command := fmt.Sprintf("[con_id=%d] %s %s%d", focused.ID, markCmd, followMark, focused.ID)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+// moveFollowers moves the windows marked by 'swayctrl follow' to workspace ws.
+func (h *daemonHandler) moveFollowers(ctx context.Context, ws string) {
+	root, err := h.client.GetTree(ctx)
+	if err != nil {
+		log.Println("GET_TREE failed:", err)
+		return
+	}
+	followers := treeSelect(root, func(n *sway.Node) bool {
+		return slices.IndexFunc(n.Marks, func(m string) bool {
+			return strings.HasPrefix(m, followMark)
+		}) >= 0
+	})
+	for _, n := range followers {
+		if w := findAncestor(root, n.ID, sway.NodeWorkspace); w != nil && w.Name == ws {
+			continue
+		}
+		command := fmt.Sprintf("[con_id=%d] move container to workspace %q", n.ID, ws)
+		if h.verbose {
+			log.Printf("Moving follower: %s", command)
+		}
+		if err := runCommand(ctx, h.client, command); err != nil {
+			log.Printf("Error running command %q: %s", command, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+func cmdGeometry(args []string) {
+	fs := flag.NewFlagSet("geometry", flag.ExitOnError)
+	var sel windowSelector
+	sel.addFlags(fs)
+	border := fs.Bool("border", false, "Include the window's borders")
+	titlebar := fs.Bool("titlebar", false, "Include the window's title bar (or tabs)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl geometry [flags...]
+
+where the flags are:
+`)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, `
+The geometry command prints the absolute geometry of a window in the "X,Y WxH"
+form accepted by grim, slurp, and wf-recorder. For example:
+
+  wf-recorder -g "$(swayctrl geometry -appid mpv)"
+
+The window is the first visible window matching -title, -appid, and -workspace
+(as for the focus command) or, if none of those are given, the focused window.
+
+By default, the geometry is that of the window's contents. The -border and
+-titlebar flags extend it to include the borders and the title bar. For a
+window in a tabbed or stacked container, the title bar is the whole tab bar.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	n := sel.visibleWindow(root)
+	r := windowGeometry(n)
+	if *border {
+		r = n.Rect
+	}
+	if *titlebar {
+		top := n.Rect.Y - n.DecoRect.Height
+		if parent := findParent(root, n.ID); parent != nil {
+			switch parent.Layout {
+			case sway.LayoutTabbed, sway.LayoutStacked:
+				top = parent.Rect.Y
+			}
+		}
+		if top < r.Y {
+			r.Height += r.Y - top
+			r.Y = top
+		}
+	}
+	fmt.Println(formatRect(r))
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/subcmd"
+	"github.com/joshuarubin/go-sway"
+)
+
+// Workspace groups are sets of workspaces that are shown together. The
+// workspaces of the active group have their ordinary names; those of the
+// other groups are renamed to group+groupSep+name to get them out of the
+// way. The daemon tracks the active group.
+const (
+	groupSep     = "/"
+	defaultGroup = "default"
+)
+
+// splitGroupWorkspace splits the name of a workspace in an inactive group
+// into the group and the workspace's ordinary name.
+func splitGroupWorkspace(name string) (group, ws string, ok bool) {
+	return strings.Cut(name, groupSep)
+}
+
+// groupInfo is the result type of the group.list method.
+type groupInfo struct {
+	Name       string
+	Active     bool
+	Workspaces []string // ordinary names
+	// Focused is the most recently focused workspace in the group (if
+	// known).
+	Focused string
+}
+
+var groupCmds = []subcmd.Command{
+	{
+		Name:        "switch",
+		Description: "make a workspace group active",
+		Do:          cmdGroupSwitch,
+	},
+	{
+		Name:        "list",
+		Description: "list the workspace groups",
+		Do:          cmdGroupList,
+	},
+	{
+		Name:        "move-window",
+		Description: "move the focused window to a workspace in another group",
+		Do:          cmdGroupMoveWindow,
+	},
+}
+
+func cmdGroup(args []string) {
+	r := subcmd.New("swayctrl group", groupCmds, flag.ExitOnError)
+	r.Run(args)
+}
+
+func cmdGroupSwitch(args []string) {
+	fs := flag.NewFlagSet("switch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl group switch name
+
+The switch command makes the named workspace group active (creating it if it
+doesn't exist). The workspaces of the previously active group are renamed with
+a "group/" prefix and those of the new group get their ordinary names back.
+Then the most recently focused workspace of the new group is focused, and each
+output that would still show a workspace of another group gets an empty
+workspace instead.
+
+The group starts out as "default". Workspace names must not contain "/".
+The daemon must be running. With -n, the sway commands are printed instead
+(and the active group doesn't change).
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if dryRun {
+		var commands []string
+		callDaemon("group.switch", map[string]any{"name": fs.Arg(0), "dry_run": true}, &commands)
+		for _, command := range commands {
+			fmt.Println(command)
+		}
+		return
+	}
+	callDaemon("group.switch", map[string]string{"name": fs.Arg(0)}, nil)
+}
+
+func cmdGroupList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl group list
+
+The list command prints each workspace group with its workspaces. The active
+group is marked with *. The daemon must be running.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var groups []groupInfo
+	callDaemon("group.list", nil, &groups)
+	for _, g := range groups {
+		marker := " "
+		if g.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\t%s\n", marker, g.Name, strings.Join(g.Workspaces, ", "))
+	}
+}
+
+func cmdGroupMoveWindow(args []string) {
+	fs := flag.NewFlagSet("move-window", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl group move-window name [workspace]
+
+The move-window command moves the focused window to a workspace in the named
+group: the given workspace or, by default, the group's most recently focused
+workspace. The daemon must be running.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	name := fs.Arg(0)
+	ws := fs.Arg(1)
+	var groups []groupInfo
+	callDaemon("group.list", nil, &groups)
+	var active bool
+	for _, g := range groups {
+		if g.Name != name {
+			continue
+		}
+		active = g.Active
+		if ws == "" {
+			if g.Focused != "" {
+				ws = g.Focused
+			} else if len(g.Workspaces) > 0 {
+				ws = g.Workspaces[0]
+			}
+		}
+	}
+	if ws == "" {
+		ws = "1"
+	}
+	if !active {
+		ws = name + groupSep + ws
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	command := fmt.Sprintf("move container to workspace %q", ws)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+// groupFocus records that the workspace named ws was focused.
+func (h *daemonHandler) groupFocus(ws string) {
+	if _, _, ok := splitGroupWorkspace(ws); ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.groupFocused[h.group] = ws
+}
+
+func (h *daemonHandler) rpcGroupList() ([]groupInfo, error) {
+	// As with windows, use a separate sway connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := h.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := client.GetWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	byName := map[string]*groupInfo{
+		h.group: {Name: h.group, Active: true, Workspaces: []string{}},
+	}
+	for _, ws := range workspaces {
+		group, name, ok := splitGroupWorkspace(ws.Name)
+		if !ok {
+			group, name = h.group, ws.Name
+		}
+		g, ok := byName[group]
+		if !ok {
+			g = &groupInfo{Name: group}
+			byName[group] = g
+		}
+		g.Workspaces = append(g.Workspaces, name)
+	}
+	var groups []groupInfo
+	for _, g := range byName {
+		g.Focused = h.groupFocused[g.Name]
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// rpcGroupSwitch implements group.switch. With dry_run, it returns the
+// commands it would run instead of running them (and leaves the active group
+// alone).
+func (h *daemonHandler) rpcGroupSwitch(params json.RawMessage) ([]string, error) {
+	var p struct {
+		Name   string `json:"name"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "group.switch requires a name"}
+	}
+	if strings.Contains(p.Name, groupSep) {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("group name cannot contain %q", groupSep)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := h.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := client.GetWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	old := h.group
+	if p.Name == old {
+		h.mu.Unlock()
+		return nil, nil
+	}
+	if !p.DryRun {
+		h.group = p.Name
+	}
+	focus := h.groupFocused[p.Name]
+	h.mu.Unlock()
+
+	commands := groupSwitchCommands(workspaces, old, p.Name, focus)
+	if p.DryRun {
+		return commands, nil
+	}
+	return nil, h.runCommandList(ctx, client, commands)
+}
+
+// groupSwitchCommands gives the sway commands that switch from the group old
+// to the group name, given the current workspaces and the most recently
+// focused workspace of the new group (if known).
+func groupSwitchCommands(workspaces []sway.Workspace, old, name, focus string) []string {
+	// Hide the old group first so its names are free.
+	var commands []string
+	var show []string
+	renamed := make([]sway.Workspace, len(workspaces))
+	for i, ws := range workspaces {
+		renamed[i] = ws
+		group, wsName, ok := splitGroupWorkspace(ws.Name)
+		switch {
+		case !ok:
+			renamed[i].Name = old + groupSep + ws.Name
+			commands = append(commands, fmt.Sprintf("rename workspace %q to %q", ws.Name, renamed[i].Name))
+		case group == name:
+			renamed[i].Name = wsName
+			show = append(show, fmt.Sprintf("rename workspace %q to %q", ws.Name, wsName))
+		}
+	}
+	commands = append(commands, show...)
+
+	// Fill any outputs still showing hidden workspaces, then focus.
+	used := make(map[int]bool)
+	shown := make(map[string]string) // output -> workspace of the new group
+	for _, ws := range renamed {
+		if num, _, ok := parseWorkspaceName(ws.Name); ok {
+			used[num] = true
+		}
+		if _, _, ok := splitGroupWorkspace(ws.Name); !ok {
+			shown[ws.Output] = ws.Name
+		}
+	}
+	num := 1
+	for _, ws := range renamed {
+		if !ws.Visible {
+			continue
+		}
+		if _, _, ok := splitGroupWorkspace(ws.Name); !ok {
+			continue
+		}
+		target, ok := shown[ws.Output]
+		if !ok {
+			for used[num] {
+				num++
+			}
+			used[num] = true
+			target = strconv.Itoa(num)
+		}
+		commands = append(commands, fmt.Sprintf("focus output %q", ws.Output), fmt.Sprintf("workspace %q", target))
+	}
+	if focus == "" {
+		focus = "1"
+		for _, ws := range renamed {
+			if _, _, ok := splitGroupWorkspace(ws.Name); !ok {
+				focus = ws.Name
+				break
+			}
+		}
+	}
+	return append(commands, fmt.Sprintf("workspace %q", focus))
+}
+
+// runCommandList is like runCommands but uses the given client and returns
+// any error.
+func (h *daemonHandler) runCommandList(ctx context.Context, client swayClient, commands []string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	command := strings.Join(commands, "; ")
+	if h.verbose {
+		log.Printf("Running: %s", command)
+	}
+	if err := runCommand(ctx, client, command); err != nil {
+		return fmt.Errorf("error running command %q: %s", command, err)
+	}
+	return nil
+}
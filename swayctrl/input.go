@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cespare/subcmd"
+)
+
+var inputCmds = []subcmd.Command{
+	{
+		Name:        "list",
+		Description: "list input devices",
+		Do:          cmdInputList,
+	},
+	{
+		Name:        "touchpad",
+		Description: "toggle touchpad events",
+		Do:          cmdInputTouchpad,
+	},
+	{
+		Name:        "layout",
+		Description: "cycle keyboard layouts",
+		Do:          cmdInputLayout,
+	},
+	{
+		Name:        "accel",
+		Description: "set pointer acceleration",
+		Do:          cmdInputAccel,
+	},
+}
+
+func cmdInput(args []string) {
+	r := subcmd.New("swayctrl input", inputCmds, flag.ExitOnError)
+	r.Run(args)
+}
+
+func cmdInputList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	typ := fs.String("type", "", "Only list devices of this type (keyboard, pointer, touchpad, ...)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl input list [-type type]
+
+The list command prints the input devices known to sway along with their
+identifiers (for use in sway input commands), types, and names. For keyboards,
+the active layout is shown; for libinput devices, whether events are enabled.
+`)
+	}
+	fs.Parse(args)
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	inputs, err := client.GetInputs(ctx)
+	if err != nil {
+		log.Fatalln("GET_INPUTS failed:", err)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, in := range inputs {
+		if *typ != "" && in.Type != *typ {
+			continue
+		}
+		var extra []string
+		if in.XKBActiveLayoutName != nil {
+			extra = append(extra, "layout="+*in.XKBActiveLayoutName)
+		}
+		if in.LibInput != nil && in.LibInput.SendEvents != "" {
+			extra = append(extra, "events="+in.LibInput.SendEvents)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", in.Identifier, in.Type, in.Name, strings.Join(extra, " "))
+	}
+	tw.Flush()
+}
+
+func cmdInputTouchpad(args []string) {
+	fs := flag.NewFlagSet("touchpad", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl input touchpad [toggle|enable|disable]
+
+The touchpad command enables or disables events from all touchpads. With no
+argument (or "toggle"), each touchpad is switched to the opposite state.
+`)
+	}
+	fs.Parse(args)
+
+	action := "toggle"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		action = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	switch action {
+	case "toggle", "enable", "disable":
+	default:
+		log.Fatalf("Bad touchpad action %q", action)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	inputs, err := client.GetInputs(ctx)
+	if err != nil {
+		log.Fatalln("GET_INPUTS failed:", err)
+	}
+	var found bool
+	for _, in := range inputs {
+		if in.Type != "touchpad" || in.LibInput == nil {
+			continue
+		}
+		found = true
+		state := "enabled"
+		switch action {
+		case "disable":
+			state = "disabled"
+		case "toggle":
+			if in.LibInput.SendEvents == "enabled" {
+				state = "disabled"
+			}
+		}
+		command := fmt.Sprintf("input %q events %s", in.Identifier, state)
+		if err := runCommand(ctx, client, command); err != nil {
+			log.Fatalf("Error running command %q: %s", command, err)
+		}
+	}
+	if !found {
+		log.Fatal("No touchpads found")
+	}
+}
+
+func cmdInputLayout(args []string) {
+	fs := flag.NewFlagSet("layout", flag.ExitOnError)
+	device := fs.String("device", "type:keyboard", "Input identifier (or type:... selector)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl input layout [-device id] [next|prev|<index>]
+
+The layout command switches the keyboard layout (among those configured with
+xkb_layout) to the next or previous one (next is the default) or to the layout
+with the given index. It applies to all keyboards unless -device is given.
+`)
+	}
+	fs.Parse(args)
+
+	which := "next"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		which = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	if which != "next" && which != "prev" {
+		if _, err := strconv.Atoi(which); err != nil {
+			log.Fatalf("Bad layout %q (must be next, prev, or an index)", which)
+		}
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	command := fmt.Sprintf("input %q xkb_switch_layout %s", *device, which)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+func cmdInputAccel(args []string) {
+	fs := flag.NewFlagSet("accel", flag.ExitOnError)
+	device := fs.String("device", "type:pointer", "Input identifier (or type:... selector)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl input accel [-device id] <speed>
+
+The accel command sets the pointer acceleration speed, which is a number
+between -1 and 1. It applies to all pointers (mice) unless -device is given.
+Negative speeds must follow a -- argument (as in 'swayctrl input accel -- -0.5').
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	speed, err := strconv.ParseFloat(fs.Arg(0), 64)
+	if err != nil || speed < -1 || speed > 1 {
+		log.Fatalf("Bad acceleration speed %q (must be between -1 and 1)", fs.Arg(0))
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	command := fmt.Sprintf("input %q pointer_accel %g", *device, speed)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
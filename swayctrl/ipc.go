@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// ipcMessageType is a sway IPC message type.
+type ipcMessageType uint32
+
+const (
+	ipcSubscribe       ipcMessageType = 2
+	ipcGetOutputs      ipcMessageType = 3
+	ipcGetBarConfig    ipcMessageType = 6
+	ipcGetBindingState ipcMessageType = 12
+
+	ipcEventWorkspace       ipcMessageType = 0x80000000
+	ipcEventOutput          ipcMessageType = 0x80000001
+	ipcEventMode            ipcMessageType = 0x80000002
+	ipcEventWindow          ipcMessageType = 0x80000003
+	ipcEventBarConfigUpdate ipcMessageType = 0x80000004
+	ipcEventBinding         ipcMessageType = 0x80000005
+	ipcEventShutdown        ipcMessageType = 0x80000006
+	ipcEventTick            ipcMessageType = 0x80000007
+	ipcEventBarStateUpdate  ipcMessageType = 0x80000014
+	ipcEventInput           ipcMessageType = 0x80000015
+)
+
+// ipcEventNames maps event types to the names used to subscribe to them.
+var ipcEventNames = map[ipcMessageType]string{
+	ipcEventWorkspace:       "workspace",
+	ipcEventOutput:          "output",
+	ipcEventMode:            "mode",
+	ipcEventWindow:          "window",
+	ipcEventBarConfigUpdate: "barconfig_update",
+	ipcEventBinding:         "binding",
+	ipcEventShutdown:        "shutdown",
+	ipcEventTick:            "tick",
+	ipcEventBarStateUpdate:  "bar_state_update",
+	ipcEventInput:           "input",
+}
+
+var ipcMagic = [6]byte{'i', '3', '-', 'i', 'p', 'c'}
+
+type ipcHeader struct {
+	Magic  [6]byte
+	Length uint32
+	Type   ipcMessageType
+}
+
+// ipcQuery sends a single message to sway over a new connection and decodes
+// the JSON reply into v. This is for the few messages (and reply fields) that
+// go-sway doesn't expose.
+func ipcQuery(ctx context.Context, typ ipcMessageType, payload string, v any) error {
+	_, err := traceCall(fmt.Sprintf("message type %d %q", typ, payload), func() (struct{}, error) {
+		return struct{}{}, ipcQuery1(ctx, typ, payload, v)
+	})
+	return err
+}
+
+func ipcQuery1(ctx context.Context, typ ipcMessageType, payload string, v any) error {
+	conn, err := ipcDial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := ipcWrite(conn, typ, payload); err != nil {
+		return err
+	}
+	replyType, reply, err := ipcRead(conn)
+	if err != nil {
+		return err
+	}
+	if replyType != typ {
+		return fmt.Errorf("got IPC reply of type %d; want %d", replyType, typ)
+	}
+	return json.Unmarshal(reply, v)
+}
+
+// ipcSubscribeRaw subscribes to the named events and calls fn with the raw
+// payload of each one until ctx is done or the connection fails. This is for
+// event types that go-sway doesn't deliver (such as output events).
+func ipcSubscribeRaw(ctx context.Context, events []string, fn func(typ ipcMessageType, payload []byte)) error {
+	conn, err := ipcDial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	b, err := json.Marshal(events)
+	if err != nil {
+		panic(err)
+	}
+	if err := ipcWrite(conn, ipcSubscribe, string(b)); err != nil {
+		return err
+	}
+	_, reply, err := ipcRead(conn)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("subscription to %s failed", b)
+	}
+	for {
+		typ, payload, err := ipcRead(conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		fn(typ, payload)
+	}
+}
+
+func ipcDial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", os.Getenv("SWAYSOCK"))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+func ipcWrite(w io.Writer, typ ipcMessageType, payload string) error {
+	h := ipcHeader{Magic: ipcMagic, Length: uint32(len(payload)), Type: typ}
+	if err := binary.Write(w, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, payload)
+	return err
+}
+
+func ipcRead(r io.Reader) (ipcMessageType, []byte, error) {
+	var h ipcHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return 0, nil, err
+	}
+	if h.Magic != ipcMagic {
+		return 0, nil, errors.New("bad magic in IPC message")
+	}
+	payload := make([]byte, h.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return h.Type, payload, nil
+}
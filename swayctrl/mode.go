@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"os"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+func cmdMode(args []string) {
+	fs := flag.NewFlagSet("mode", flag.ExitOnError)
+	waybar := fs.Bool("waybar", false, "Print JSON for a waybar custom module")
+	showDefault := fs.Bool("show-default", false, `Print the "default" mode rather than an empty line`)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl mode [-waybar] [-show-default]
+
+The mode command prints the current sway binding mode and then prints it again
+each time it changes. The default mode is printed as an empty line (so that a
+bar only shows custom modes) unless -show-default is given.
+
+If -waybar is given, each line is a JSON object suitable for a waybar custom
+module with "return-type": "json", with the mode as both the text and the
+class.
+`)
+	}
+	fs.Parse(args)
+
+	p := &modePrinter{waybar: *waybar, showDefault: *showDefault}
+	ctx := context.Background()
+	// As in focustitle, print the initial mode upon the first tick event so
+	// that no change is missed.
+	handler := &modeHandler{EventHandler: sway.NoOpEventHandler(), p: p}
+	if err := sway.Subscribe(ctx, handler, sway.EventTypeMode, sway.EventTypeTick); err != nil {
+		log.Fatalln("Error with subscription:", err)
+	}
+}
+
+type modeHandler struct {
+	p *modePrinter
+	sway.EventHandler
+}
+
+func (h *modeHandler) Tick(ctx context.Context, e sway.TickEvent) {
+	if !e.First {
+		return
+	}
+	var state struct {
+		Name string `json:"name"`
+	}
+	if err := ipcQuery(ctx, ipcGetBindingState, "", &state); err != nil {
+		log.Fatalln("GET_BINDING_STATE failed:", err)
+	}
+	h.p.print(state.Name)
+}
+
+func (h *modeHandler) Mode(ctx context.Context, e sway.ModeEvent) {
+	h.p.print(e.Change)
+}
+
+type modePrinter struct {
+	waybar      bool
+	showDefault bool
+}
+
+func (p *modePrinter) print(mode string) {
+	if mode == "default" && !p.showDefault {
+		mode = ""
+	}
+	if !p.waybar {
+		fmt.Println(mode)
+		return
+	}
+	b, err := json.Marshal(struct {
+		Text  string `json:"text"`
+		Class string `json:"class"`
+	}{
+		Text:  html.EscapeString(mode),
+		Class: mode,
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+}
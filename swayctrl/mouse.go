@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+func cmdFocusFollowsMouse(args []string) {
+	fs := flag.NewFlagSet("focus-follows-mouse", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl focus-follows-mouse [toggle|yes|no|always]
+
+The focus-follows-mouse command sets sway's focus_follows_mouse option. With no
+argument (or toggle), it switches between no and the previous setting (yes, to
+begin with, unless the sway config says otherwise).
+
+Since sway doesn't report the current setting, swayctrl remembers the last
+value it set in $XDG_RUNTIME_DIR.
+`)
+	}
+	fs.Parse(args)
+
+	value := "toggle"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		value = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	statePath := focusFollowsMouseStatePath()
+	// The state file holds the current value and the last value other
+	// than no, separated by a space.
+	var cur, on string
+	b, err := os.ReadFile(statePath)
+	switch {
+	case err == nil:
+		cur, on, _ = strings.Cut(strings.TrimSpace(string(b)), " ")
+	case errors.Is(err, os.ErrNotExist):
+		cur = configFocusFollowsMouse(ctx, client)
+		on = cur
+	default:
+		log.Fatalln("Error reading focus_follows_mouse state:", err)
+	}
+	if on == "" || on == "no" {
+		on = "yes"
+	}
+
+	switch value {
+	case "toggle":
+		value = "no"
+		if cur == "no" {
+			value = on
+		}
+	case "yes", "always":
+		on = value
+	case "no":
+	default:
+		log.Fatalf("Bad focus_follows_mouse value %q", value)
+	}
+	command := "focus_follows_mouse " + value
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+	if dryRun {
+		return
+	}
+	if err := os.WriteFile(statePath, []byte(value+" "+on+"\n"), 0o644); err != nil {
+		log.Fatalln("Error writing focus_follows_mouse state:", err)
+	}
+}
+
+func focusFollowsMouseStatePath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		log.Fatalln("XDG_RUNTIME_DIR must be defined (to store focus_follows_mouse state)")
+	}
+	return filepath.Join(dir, "swayctrl-focus-follows-mouse")
+}
+
+// configFocusFollowsMouse finds the focus_follows_mouse setting in the sway
+// config (not including any included files).
+func configFocusFollowsMouse(ctx context.Context, client sway.Client) string {
+	cfg, err := client.GetConfig(ctx)
+	if err != nil {
+		log.Fatalln("GET_CONFIG failed:", err)
+	}
+	value := "yes"
+	for _, line := range strings.Split(cfg.Config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "focus_follows_mouse" {
+			value = fields[1]
+		}
+	}
+	return value
+}
+
+// focusCommand returns the sway command that focuses n and, if warp is set,
+// moves the pointer to its center.
+func focusCommand(n *sway.Node, warp bool) string {
+	command := fmt.Sprintf("[con_id=%d] focus", n.ID)
+	if warp {
+		x := n.Rect.X + n.Rect.Width/2
+		y := n.Rect.Y + n.Rect.Height/2
+		command += fmt.Sprintf("; seat - cursor set %d %d", x, y)
+	}
+	return command
+}
+
+// findNode returns the node in root with the given ID, or nil if there is
+// none.
+func findNode(root *sway.Node, id int64) *sway.Node {
+	nodes := treeSelect(root, func(n *sway.Node) bool { return n.ID == id })
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestWindowMRUList(t *testing.T) {
+	type op struct {
+		del   bool
+		id    int64
+		appID string
+	}
+	front := func(id int64, appID string) op { return op{id: id, appID: appID} }
+	del := func(id int64) op { return op{del: true, id: id} }
+
+	for _, tt := range []struct {
+		name string
+		max  int
+		ops  []op
+		want []listWindow
+	}{
+		{
+			name: "empty",
+			max:  3,
+			want: []listWindow{},
+		},
+		{
+			name: "bring new id to front",
+			max:  3,
+			ops:  []op{front(1, "a"), front(2, "b")},
+			want: []listWindow{{2, "b"}, {1, "a"}},
+		},
+		{
+			name: "bring existing id to front",
+			max:  3,
+			ops:  []op{front(1, "a"), front(2, "b"), front(3, "c"), front(1, "a")},
+			want: []listWindow{{1, "a"}, {3, "c"}, {2, "b"}},
+		},
+		{
+			name: "existing id updates app id",
+			max:  3,
+			ops:  []op{front(1, "a"), front(2, "b"), front(1, "z")},
+			want: []listWindow{{1, "z"}, {2, "b"}},
+		},
+		{
+			name: "delete",
+			max:  3,
+			ops:  []op{front(1, "a"), front(2, "b"), front(3, "c"), del(2)},
+			want: []listWindow{{3, "c"}, {1, "a"}},
+		},
+		{
+			name: "delete missing id",
+			max:  3,
+			ops:  []op{front(1, "a"), del(2)},
+			want: []listWindow{{1, "a"}},
+		},
+		{
+			name: "delete then re-add",
+			max:  3,
+			ops:  []op{front(1, "a"), front(2, "b"), del(1), front(1, "a")},
+			want: []listWindow{{1, "a"}, {2, "b"}},
+		},
+		{
+			name: "evict least recently used",
+			max:  2,
+			ops:  []op{front(1, "a"), front(2, "b"), front(3, "c")},
+			want: []listWindow{{3, "c"}, {2, "b"}},
+		},
+		{
+			name: "evict after reorder",
+			max:  2,
+			ops:  []op{front(1, "a"), front(2, "b"), front(1, "a"), front(3, "c")},
+			want: []listWindow{{3, "c"}, {1, "a"}},
+		},
+		{
+			name: "evicted id can come back",
+			max:  2,
+			ops:  []op{front(1, "a"), front(2, "b"), front(3, "c"), front(1, "a")},
+			want: []listWindow{{1, "a"}, {3, "c"}},
+		},
+		{
+			name: "delete evicted id",
+			max:  1,
+			ops:  []op{front(1, "a"), front(2, "b"), del(1)},
+			want: []listWindow{{2, "b"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newWindowMRUList(tt.max)
+			for _, op := range tt.ops {
+				if op.del {
+					l.delete(op.id)
+				} else {
+					l.bringFront(op.id, op.appID)
+				}
+				checkMRUInvariants(t, l)
+			}
+			if got := l.all(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkMRUInvariants checks that l.m indexes exactly the elements of l.l and
+// that l holds at most l.max windows.
+func checkMRUInvariants(t *testing.T, l *windowMRUList) {
+	t.Helper()
+	if l.l.Len() > l.max {
+		t.Fatalf("list has %d windows; max is %d", l.l.Len(), l.max)
+	}
+	if len(l.m) != l.l.Len() {
+		t.Fatalf("map has %d entries; list has %d", len(l.m), l.l.Len())
+	}
+	for e := l.l.Front(); e != nil; e = e.Next() {
+		id := e.Value.(listWindow).ID
+		if l.m[id] != e {
+			t.Fatalf("map entry for %d doesn't point at its list element", id)
+		}
+	}
+}
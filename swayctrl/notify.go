@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/exec"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// A notifyRule describes when the daemon sends a desktop notification.
+type notifyRule struct {
+	// Event is the kind of event that triggers the notification:
+	//
+	//   urgent: a window that is not visible becomes urgent
+	//   new:    a window is opened
+	Event string `json:"event"`
+
+	// AppID restricts the rule to windows with this app ID (or X11 class).
+	// If empty, the rule applies to all windows.
+	AppID string `json:"app_id"`
+
+	// MinStartupSecs applies to "new" rules: a notification is sent only
+	// if the window appeared at least this long after its process started.
+	// This is useful for being told when a slow application is ready.
+	MinStartupSecs float64 `json:"min_startup_secs"`
+}
+
+func (r *notifyRule) matches(change sway.WindowEventChange, appID string) bool {
+	if r.AppID != "" && r.AppID != appID {
+		return false
+	}
+	switch r.Event {
+	case "urgent":
+		return change == sway.WindowUrgent
+	case "new":
+		return change == sway.WindowNew
+	}
+	return false
+}
+
+// notify sends notifications for the window event according to the
+// configured rules.
+func (h *daemonHandler) notify(ctx context.Context, e sway.WindowEvent) {
+	switch e.Change {
+	case sway.WindowUrgent, sway.WindowNew:
+	default:
+		return
+	}
+	appID := nodeAppID(&e.Container)
+	cfg := h.config()
+	for i := range cfg.Notify {
+		r := &cfg.Notify[i]
+		if !r.matches(e.Change, appID) {
+			continue
+		}
+		switch r.Event {
+		case "urgent":
+			if e.Container.Urgent == nil || !*e.Container.Urgent {
+				continue
+			}
+			if h.windowVisible(ctx, e.Container.ID) {
+				continue
+			}
+			sendNotification("Urgent: "+appID, e.Container.Name)
+		case "new":
+			if r.MinStartupSecs > 0 {
+				if e.Container.PID == nil {
+					continue
+				}
+				age, err := processAge(int(*e.Container.PID))
+				if err != nil {
+					log.Printf("Cannot determine age of process %d: %s", *e.Container.PID, err)
+					continue
+				}
+				if age.Seconds() < r.MinStartupSecs {
+					continue
+				}
+			}
+			sendNotification("Opened: "+appID, e.Container.Name)
+		}
+		return
+	}
+}
+
+// windowVisible reports whether the window with the given ID is on a visible
+// workspace.
+func (h *daemonHandler) windowVisible(ctx context.Context, id int64) bool {
+	root, err := h.client.GetTree(ctx)
+	if err != nil {
+		log.Println("GET_TREE failed:", err)
+		return false
+	}
+	ws := findAncestor(root, id, sway.NodeWorkspace)
+	if ws == nil {
+		return false
+	}
+	workspaces, err := h.client.GetWorkspaces(ctx)
+	if err != nil {
+		log.Println("GET_WORKSPACES failed:", err)
+		return false
+	}
+	for _, w := range workspaces {
+		if w.Name == ws.Name {
+			return w.Visible
+		}
+	}
+	return false
+}
+
+func sendNotification(summary, body string) {
+	cmd := exec.Command("notify-send", "--app-name=swayctrl", summary, body)
+	if err := cmd.Start(); err != nil {
+		log.Println("Error running notify-send:", err)
+		return
+	}
+	go cmd.Wait()
+}
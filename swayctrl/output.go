@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cespare/subcmd"
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+var outputCmds = []subcmd.Command{
+	{
+		Name:        "send-window",
+		Description: "send the focused window (or workspace) to the next/previous output",
+		Do:          cmdOutputSendWindow,
+	},
+	{
+		Name:        "profile",
+		Description: "apply an output profile from the config file",
+		Do:          cmdOutputProfile,
+	},
+}
+
+func cmdOutput(args []string) {
+	r := subcmd.New("swayctrl output", outputCmds, flag.ExitOnError)
+	r.Run(args)
+}
+
+func cmdOutputSendWindow(args []string) {
+	fs := flag.NewFlagSet("send-window", flag.ExitOnError)
+	workspace := fs.Bool("workspace", false, "Send the whole focused workspace")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl output send-window [-workspace] next|prev
+
+The send-window command moves the focused window to the next or previous output
+(ordered left-to-right, then top-to-bottom) and keeps it focused. Floating
+windows keep their position and size relative to the output, scaled to the new
+output's dimensions, rather than being dumped wherever sway puts them.
+
+If -workspace is given, the whole focused workspace is moved instead and each of
+its floating windows is repositioned in the same way.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var delta int
+	switch fs.Arg(0) {
+	case "next":
+		delta = 1
+	case "prev":
+		delta = -1
+	default:
+		log.Fatalf("Bad direction %q (must be next or prev)", fs.Arg(0))
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	focused := root.FocusedNode()
+	if focused == nil {
+		log.Fatal("No focused node")
+	}
+	src := findAncestor(root, focused.ID, sway.NodeOutput)
+	if src == nil {
+		log.Fatal("Cannot determine the output of the focused node")
+	}
+	outputs := activeOutputs(root)
+	i := slices.IndexFunc(outputs, func(n *sway.Node) bool { return n.ID == src.ID })
+	if i < 0 {
+		log.Fatal("Focused node is not on an active output")
+	}
+	if len(outputs) < 2 {
+		return
+	}
+	dst := outputs[(i+delta+len(outputs))%len(outputs)]
+
+	if *workspace {
+		ws := findAncestor(root, focused.ID, sway.NodeWorkspace)
+		if ws == nil {
+			log.Fatal("Cannot determine the workspace of the focused node")
+		}
+		command := fmt.Sprintf("move workspace to output %q", dst.Name)
+		if err := runCommand(ctx, client, command); err != nil {
+			log.Fatalf("Error running command %q: %s", command, err)
+		}
+		for _, n := range ws.FloatingNodes {
+			placeFloating(ctx, client, n, src, dst)
+		}
+		return
+	}
+
+	switch focused.Type {
+	case sway.NodeCon, sway.NodeFloatingCon:
+	default:
+		log.Fatal("Focused node is not a window")
+	}
+	command := fmt.Sprintf("[con_id=%d] move container to output %q", focused.ID, dst.Name)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+	if focused.Type == sway.NodeFloatingCon {
+		placeFloating(ctx, client, focused, src, dst)
+	}
+	command = fmt.Sprintf("[con_id=%d] focus", focused.ID)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+// activeOutputs returns the (non-scratchpad) outputs in the tree, ordered by
+// their position in the layout.
+func activeOutputs(root *sway.Node) []*sway.Node {
+	var outputs []*sway.Node
+	for _, n := range root.Nodes {
+		if n.Type == sway.NodeOutput && n.Name != "__i3" {
+			outputs = append(outputs, n)
+		}
+	}
+	slices.SortFunc(outputs, func(n0, n1 *sway.Node) bool {
+		if n0.Rect.X != n1.Rect.X {
+			return n0.Rect.X < n1.Rect.X
+		}
+		return n0.Rect.Y < n1.Rect.Y
+	})
+	return outputs
+}
+
+// placeFloating moves and resizes the floating window n, which was located on
+// output src, so that it occupies the same proportion of dst.
+func placeFloating(ctx context.Context, client sway.Client, n, src, dst *sway.Node) {
+	if src.Rect.Width == 0 || src.Rect.Height == 0 {
+		return
+	}
+	sx := float64(dst.Rect.Width) / float64(src.Rect.Width)
+	sy := float64(dst.Rect.Height) / float64(src.Rect.Height)
+	x := dst.Rect.X + int64(float64(n.Rect.X-src.Rect.X)*sx)
+	y := dst.Rect.Y + int64(float64(n.Rect.Y-src.Rect.Y)*sy)
+	w := int64(float64(n.Rect.Width) * sx)
+	h := int64(float64(n.Rect.Height) * sy)
+	command := fmt.Sprintf(
+		"[con_id=%d] resize set %d px %d px, move absolute position %d px %d px",
+		n.ID, w, h, x, y,
+	)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+// findAncestor locates the node with the given ID and returns its closest
+// ancestor (or the node itself) of type typ. It returns nil if either the node
+// or such an ancestor cannot be found.
+func findAncestor(root *sway.Node, id int64, typ sway.NodeType) *sway.Node {
+	var find func(n, candidate *sway.Node) *sway.Node
+	find = func(n, candidate *sway.Node) *sway.Node {
+		if n.Type == typ {
+			candidate = n
+		}
+		if n.ID == id {
+			return candidate
+		}
+		for _, c := range n.FloatingNodes {
+			if m := find(c, candidate); m != nil {
+				return m
+			}
+		}
+		for _, c := range n.Nodes {
+			if m := find(c, candidate); m != nil {
+				return m
+			}
+		}
+		return nil
+	}
+	return find(root, nil)
+}
+
+// focusedOutput returns the name of the output of the focused workspace.
+func focusedOutput(ctx context.Context, client sway.Client) string {
+	workspaces, err := client.GetWorkspaces(ctx)
+	if err != nil {
+		log.Fatalln("GET_WORKSPACES failed:", err)
+	}
+	for _, ws := range workspaces {
+		if ws.Focused {
+			return ws.Output
+		}
+	}
+	log.Fatal("No focused workspace")
+	panic("unreachable")
+}
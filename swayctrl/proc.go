@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// processAge returns how long ago the process with the given PID started.
+func processAge(pid int) (time.Duration, error) {
+	startTicks, err := procStatField(pid, 22)
+	if err != nil {
+		return 0, err
+	}
+	b, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	uptimeFields := strings.Fields(string(b))
+	if len(uptimeFields) == 0 {
+		return 0, fmt.Errorf("malformed /proc/uptime")
+	}
+	uptime, err := strconv.ParseFloat(uptimeFields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	// starttime is in units of USER_HZ, which is 100 on Linux.
+	start := float64(startTicks) / 100
+	return time.Duration((uptime - start) * float64(time.Second)), nil
+}
+
+// parentPID returns the PID of the parent of the process with the given PID.
+func parentPID(pid int) (int, error) {
+	ppid, err := procStatField(pid, 4)
+	return int(ppid), err
+}
+
+// procStatField returns the nth (1-indexed, as in proc(5)) field of
+// /proc/[pid]/stat, which must be numeric.
+func procStatField(pid, n int) (int64, error) {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The command name (field 2) may contain spaces, so start after it.
+	s := string(b)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 || n < 3 {
+		return 0, fmt.Errorf("malformed stat file for pid %d", pid)
+	}
+	fields := strings.Fields(s[i+1:])
+	if len(fields) < n-2 {
+		return 0, fmt.Errorf("malformed stat file for pid %d", pid)
+	}
+	return strconv.ParseInt(fields[n-3], 10, 64)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// An outputProfile is a set of output configurations that is applied when
+// exactly the outputs it describes are connected (in the manner of kanshi).
+type outputProfile struct {
+	Name    string               `json:"name"`
+	Outputs []outputProfileEntry `json:"outputs"`
+}
+
+type outputProfileEntry struct {
+	// Match identifies an output by its name (such as "eDP-1"), its serial
+	// number, or its full "make model serial" description.
+	Match string `json:"match"`
+
+	Disable   bool    `json:"disable"`
+	Mode      string  `json:"mode"`     // as in "2560x1440@59.951Hz"
+	Position  string  `json:"position"` // as in "1920,0"
+	Scale     float64 `json:"scale"`
+	Transform string  `json:"transform"`
+}
+
+func (e *outputProfileEntry) matches(o *sway.Output) bool {
+	switch e.Match {
+	case o.Name, outputDescription(o):
+		return true
+	case o.Serial:
+		return o.Serial != "" && o.Serial != "Unknown"
+	}
+	return false
+}
+
+func (e *outputProfileEntry) command(name string) string {
+	if e.Disable {
+		return fmt.Sprintf("output %q disable", name)
+	}
+	parts := []string{fmt.Sprintf("output %q enable", name)}
+	if e.Mode != "" {
+		parts = append(parts, "mode "+e.Mode)
+	}
+	if e.Position != "" {
+		parts = append(parts, "position "+strings.Replace(e.Position, ",", " ", 1))
+	}
+	if e.Scale != 0 {
+		parts = append(parts, fmt.Sprintf("scale %g", e.Scale))
+	}
+	if e.Transform != "" {
+		parts = append(parts, "transform "+e.Transform)
+	}
+	return strings.Join(parts, " ")
+}
+
+// outputDescription gives the "make model serial" string that sway accepts
+// in place of an output name.
+func outputDescription(o *sway.Output) string {
+	return fmt.Sprintf("%s %s %s", o.Make, o.Model, o.Serial)
+}
+
+// match reports whether p describes exactly the given outputs. If so, it
+// returns the output (by index) matched by each entry of p.
+func (p *outputProfile) match(outputs []sway.Output) ([]int, bool) {
+	if len(p.Outputs) != len(outputs) {
+		return nil, false
+	}
+	used := make([]bool, len(outputs))
+	matched := make([]int, len(p.Outputs))
+outer:
+	for i := range p.Outputs {
+		for j := range outputs {
+			if !used[j] && p.Outputs[i].matches(&outputs[j]) {
+				used[j] = true
+				matched[i] = j
+				continue outer
+			}
+		}
+		return nil, false
+	}
+	return matched, true
+}
+
+func (p *outputProfile) apply(ctx context.Context, client sway.Client, outputs []sway.Output, matched []int) error {
+	// Enable outputs before disabling any so that sway always has somewhere
+	// to put the workspaces.
+	var enable, disable []string
+	for i, j := range matched {
+		e := &p.Outputs[i]
+		if e.Disable {
+			disable = append(disable, e.command(outputs[j].Name))
+		} else {
+			enable = append(enable, e.command(outputs[j].Name))
+		}
+	}
+	for _, command := range append(enable, disable...) {
+		if err := runCommand(ctx, client, command); err != nil {
+			return fmt.Errorf("error running command %q: %s", command, err)
+		}
+	}
+	return nil
+}
+
+// outputsKey identifies a set of connected outputs.
+func outputsKey(outputs []sway.Output) string {
+	var descs []string
+	for i := range outputs {
+		descs = append(descs, outputs[i].Name+"/"+outputDescription(&outputs[i]))
+	}
+	sort.Strings(descs)
+	return strings.Join(descs, "\n")
+}
+
+func cmdOutputProfile(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl output profile [name]
+
+The profile command applies an output profile from the output_profiles list
+in the config file. If no name is given, the first profile matching the set
+of connected outputs is used. A profile matches if each of its outputs matches
+one connected output (by name, serial number, or "make model serial") and
+there are no other connected outputs. For example:
+
+  "output_profiles": [
+    {
+      "name": "docked",
+      "outputs": [
+        {"match": "eDP-1", "disable": true},
+        {"match": "Dell Inc. DELL U2720Q ABC123", "mode": "3840x2160@60Hz", "position": "0,0", "scale": 1.5}
+      ]
+    }
+  ]
+
+If the daemon is running, it applies the matching profile (if any) at startup
+and whenever an output is connected or disconnected.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	profiles := loadConfig().OutputProfiles
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	outputs, err := client.GetOutputs(ctx)
+	if err != nil {
+		log.Fatalln("GET_OUTPUTS failed:", err)
+	}
+	if fs.NArg() == 1 {
+		for i := range profiles {
+			p := &profiles[i]
+			if p.Name != fs.Arg(0) {
+				continue
+			}
+			matched, ok := p.match(outputs)
+			if !ok {
+				log.Fatalf("Profile %q does not match the connected outputs", p.Name)
+			}
+			if err := p.apply(ctx, client, outputs, matched); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		log.Fatalf("No profile named %q", fs.Arg(0))
+	}
+	p, err := applyMatchingProfile(ctx, client, profiles, outputs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if p == nil {
+		log.Fatal("No profile matches the connected outputs")
+	}
+	log.Printf("Applied profile %q", p.Name)
+}
+
+// applyMatchingProfile applies the first of profiles that matches outputs.
+// It returns the applied profile or nil if none matched.
+func applyMatchingProfile(ctx context.Context, client sway.Client, profiles []outputProfile, outputs []sway.Output) (*outputProfile, error) {
+	for i := range profiles {
+		p := &profiles[i]
+		matched, ok := p.match(outputs)
+		if !ok {
+			continue
+		}
+		return p, p.apply(ctx, client, outputs, matched)
+	}
+	return nil, nil
+}
+
+// watchOutputs applies the matching output profile now and then again each
+// time the set of connected outputs changes.
+func (h *daemonHandler) watchOutputs(ctx context.Context) {
+	// Use a separate connection from the event handlers.
+	client := newClient(ctx)
+	var lastKey string
+	check := func() {
+		outputs, err := client.GetOutputs(ctx)
+		if err != nil {
+			log.Println("GET_OUTPUTS failed:", err)
+			return
+		}
+		key := outputsKey(outputs)
+		if key == lastKey {
+			return
+		}
+		lastKey = key
+		p, err := applyMatchingProfile(ctx, client, h.config().OutputProfiles, outputs)
+		if err != nil {
+			log.Println("Error applying output profile:", err)
+			return
+		}
+		if h.verbose && p != nil {
+			log.Printf("Applied output profile %q", p.Name)
+		}
+	}
+	check()
+	// go-sway doesn't handle output events, so subscribe to them separately.
+	err := ipcSubscribeRaw(ctx, []string{"output"}, func(typ ipcMessageType, _ []byte) {
+		if typ == ipcEventOutput {
+			check()
+		}
+	})
+	log.Fatalln("Error with output subscription:", err)
+}
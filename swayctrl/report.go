@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A titleBucket groups focus time by window title for report.
+type titleBucket struct {
+	name string
+	re   *regexp.Regexp
+}
+
+func cmdReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	timeRange := fs.String("range", "today", "Time range: today, week, or all")
+	jsonOutput := fs.Bool("json", false, "Print JSON instead of a table")
+	var buckets []titleBucket
+	fs.Func("bucket", "Group windows whose title matches `name=regexp` under name (may be repeated)", func(s string) error {
+		name, expr, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("bad bucket %q (must be name=regexp)", s)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("bad regexp in bucket %q: %s", s, err)
+		}
+		buckets = append(buckets, titleBucket{name, re})
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl report [flags...]
+
+where the flags are:
+`)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, `
+The report command prints how long windows have been focused, using the
+statistics collected by the daemon, for a time range: today (since midnight),
+week (the past 7 days), or all (everything the daemon has). The daemon keeps
+detailed statistics for a little over a week.
+
+By default, time is grouped by app ID. If -bucket is given, it is grouped by
+title instead: each window's time goes to the first bucket whose regexp
+matches the window title, or to "other" if none match. For example:
+
+  swayctrl report -range week -bucket 'code=Visual Studio|nvim' -bucket 'mail=Gmail'
+
+The daemon must be running.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	now := time.Now()
+	var since time.Time
+	switch *timeRange {
+	case "today":
+		y, m, d := now.Date()
+		since = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	case "week":
+		since = now.AddDate(0, 0, -7)
+	case "all":
+	default:
+		log.Fatalf("Bad -range %q (must be today, week, or all)", *timeRange)
+	}
+
+	var spans []focusSpan
+	callDaemon("stats.spans", map[string]time.Time{"since": since}, &spans)
+	totals := make(map[string]time.Duration)
+	for _, span := range spans {
+		if span.Start.Before(since) {
+			span.Start = since
+		}
+		key := span.AppID
+		if len(buckets) > 0 {
+			key = "other"
+			for _, b := range buckets {
+				if b.re.MatchString(span.Title) {
+					key = b.name
+					break
+				}
+			}
+		}
+		totals[key] += span.End.Sub(span.Start)
+	}
+
+	type row struct {
+		Name    string  `json:"name"`
+		Seconds float64 `json:"seconds"`
+	}
+	var rows []row
+	var total time.Duration
+	for name, d := range totals {
+		rows = append(rows, row{name, d.Seconds()})
+		total += d
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Seconds != rows[j].Seconds {
+			return rows[i].Seconds > rows[j].Seconds
+		}
+		return rows[i].Name < rows[j].Name
+	})
+
+	if *jsonOutput {
+		if rows == nil {
+			rows = []row{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(rows); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range rows {
+		d := time.Duration(r.Seconds * float64(time.Second))
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\n", r.Name, d.Round(time.Second), 100*d.Seconds()/total.Seconds())
+	}
+	tw.Flush()
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// The daemon speaks JSON-RPC 2.0 over its unix socket. Each request and
+// response is a single line of JSON; a client may send any number of requests
+// over one connection. As usual, a request without an id is a notification:
+// the daemon runs the method but doesn't respond.
+//
+// The methods are:
+//
+//   mru           -> []listWindow: windows, most recently focused first
+//   windows       -> []windowInfo: all windows in the tree
+//   activate      -> null: focus the window {"id": ..., "warp": bool}
+//   cycle.begin   -> []listWindow: freeze the MRU order (as for alt-tab) and
+//                    return it; focus changes aren't recorded until commit
+//   cycle.commit  -> null: end the cycle, recording the focused window
+//   rules.reload  -> null: reload the config file
+//   stats         -> focusStats: focus time per app since startup or reset
+//   stats.spans   -> []focusSpan: the periods of focus of each window (over
+//                    the past week at most) that ended after {"since": time}
+//   stats.reset   -> null: reset the focus time statistics
+//   mode          -> string: the current binding mode
+//   group.list    -> []groupInfo: the workspace groups
+//   group.switch  -> null: make the group {"name": ...} active; with
+//                    "dry_run": true, return the []string of sway commands
+//                    it would run instead
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("daemon error %d: %s", e.Code, e.Message)
+}
+
+// Standard JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// windowInfo is the result type of the windows method. It is also how a
+// windowSource describes a window.
+type windowInfo struct {
+	ID        int64
+	AppID     string
+	Title     string
+	Workspace string // empty with the toplevel backend
+	Focused   bool
+	Visible   bool
+
+	node *sway.Node // set by the sway source
+}
+
+// activateParams are the parameters of the activate method.
+type activateParams struct {
+	ID   int64 `json:"id"`
+	Warp bool  `json:"warp"`
+}
+
+// focusStats is the result type of the stats method.
+type focusStats struct {
+	Since time.Time
+	// ByApp is the total time (in seconds) each app ID has been focused.
+	ByApp map[string]float64
+}
+
+// daemonSock is set by the global -socket flag.
+var daemonSock string
+
+// daemonSockPath gives the path of the daemon's socket: the -socket flag,
+// $SWAYCTRL_SOCK, or by default a file in $XDG_RUNTIME_DIR named after the
+// sway socket, so that each sway instance can have its own daemon.
+func daemonSockPath() string {
+	if daemonSock != "" {
+		return daemonSock
+	}
+	if path := os.Getenv("SWAYCTRL_SOCK"); path != "" {
+		return path
+	}
+	sockDir := os.Getenv("XDG_RUNTIME_DIR")
+	if sockDir == "" {
+		log.Fatalln("XDG_RUNTIME_DIR must be defined (to place socket file)")
+	}
+	name := "swayctrl.sock"
+	if swaySock := os.Getenv("SWAYSOCK"); swaySock != "" {
+		// The sway socket is named like sway-ipc.$UID.$PID.sock.
+		name = "swayctrl." + strings.TrimPrefix(filepath.Base(swaySock), "sway-ipc.")
+	}
+	return filepath.Join(sockDir, name)
+}
+
+// A daemonConn is a client connection to the daemon.
+type daemonConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int
+}
+
+func dialDaemon() (*daemonConn, error) {
+	conn, err := net.Dial("unix", daemonSockPath())
+	if err != nil {
+		return nil, err
+	}
+	return &daemonConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *daemonConn) Close() error {
+	return c.conn.Close()
+}
+
+// call calls the given method and decodes the result into result (unless
+// result is nil).
+func (c *daemonConn) call(method string, params, result any) error {
+	c.nextID++
+	req := rpcRequest{JSONRPC: "2.0", Method: method}
+	req.ID, _ = json.Marshal(c.nextID)
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = b
+	}
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return err
+	}
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if string(resp.ID) != string(req.ID) {
+		return fmt.Errorf("daemon response ID %s does not match request ID %s", resp.ID, req.ID)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// callDaemon makes a single call to the daemon, exiting on failure.
+func callDaemon(method string, params, result any) {
+	var c *daemonConn
+	var err error
+	if inBatch {
+		c, err = batchDialDaemon()
+	} else {
+		c, err = dialDaemon()
+		if err == nil {
+			defer c.Close()
+		}
+	}
+	if err != nil {
+		log.Fatalln("Error connecting to local daemon (is it running?):", err)
+	}
+	if err := c.call(method, params, result); err != nil {
+		log.Fatalf("Error calling daemon method %s: %s", method, err)
+	}
+}
+
+func (h *daemonHandler) listen(sockPath string) {
+	if err := os.RemoveAll(sockPath); err != nil {
+		log.Fatalln("Error creating socket file:", err)
+	}
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		log.Fatalln("Error listening with socket file:", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Fatalln("Accept error:", err)
+			}
+			go h.serveConn(conn)
+		}
+	}()
+}
+
+func (h *daemonHandler) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		resp := h.handleRPC(line)
+		if resp == nil {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handleRPC handles a request. It returns nil for a notification.
+func (h *daemonHandler) handleRPC(line []byte) *rpcResponse {
+	resp := &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		return resp
+	}
+	if len(req.ID) > 0 {
+		resp.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}
+		return resp
+	}
+	result, err := h.rpcMethod(req.Method, req.Params)
+	if len(req.ID) == 0 {
+		if err != nil {
+			log.Printf("Error in notification %s: %s", req.Method, err)
+		} else if h.verbose {
+			log.Printf("RPC %s (notification)", req.Method)
+		}
+		return nil
+	}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		resp.Error = rerr
+		return resp
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = b
+	if h.verbose {
+		log.Printf("RPC %s", req.Method)
+	}
+	return resp
+}
+
+func (h *daemonHandler) rpcMethod(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "mru":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.list.all(), nil
+	case "windows":
+		return h.windows()
+	case "activate":
+		var p activateParams
+		if err := json.Unmarshal(params, &p); err != nil || p.ID == 0 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "activate requires an id"}
+		}
+		return nil, h.activate(p)
+	case "cycle.begin":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.cycling = true
+		h.cycleFocus = nil
+		return h.list.all(), nil
+	case "cycle.commit":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.cycling = false
+		if w := h.cycleFocus; w != nil {
+			h.list.bringFront(w.ID, w.AppID)
+			h.cycleFocus = nil
+		}
+		return nil, nil
+	case "rules.reload":
+		cfg, err := readConfig()
+		if err != nil {
+			return nil, err
+		}
+		h.mu.Lock()
+		h.cfg = cfg
+		h.mu.Unlock()
+		return nil, nil
+	case "stats":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.stats.snapshot(time.Now()), nil
+	case "stats.spans":
+		var p struct {
+			Since time.Time `json:"since"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.stats.spansSince(p.Since, time.Now()), nil
+	case "stats.reset":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.stats.reset(time.Now())
+		return nil, nil
+	case "mode":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.mode, nil
+	case "group.list":
+		return h.rpcGroupList()
+	case "group.switch":
+		commands, err := h.rpcGroupSwitch(params)
+		if err != nil {
+			return nil, err
+		}
+		return commands, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("no such method %q", method)}
+}
+
+// source returns the windowSource for RPC methods: the toplevel manager
+// or, with sway, a separate connection (to avoid interfering with the event
+// handlers' one).
+func (h *daemonHandler) source(ctx context.Context) (windowSource, error) {
+	if h.src != nil {
+		return h.src, nil
+	}
+	client, err := h.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return swaySource{client}, nil
+}
+
+func (h *daemonHandler) windows() ([]windowInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	src, err := h.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return src.windows(ctx)
+}
+
+func (h *daemonHandler) activate(p activateParams) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	src, err := h.source(ctx)
+	if err != nil {
+		return err
+	}
+	return src.activate(ctx, windowInfo{ID: p.ID}, p.Warp)
+}
+
+// treeWindows lists the windows in root, in tree order.
+func treeWindows(root *sway.Node) []windowInfo {
+	windows := []windowInfo{}
+	for _, ws := range treeSelect(root, func(n *sway.Node) bool { return n.Type == sway.NodeWorkspace }) {
+		for _, n := range treeSelect(ws, isWindow) {
+			windows = append(windows, windowInfo{
+				ID:        n.ID,
+				AppID:     nodeAppID(n),
+				Title:     n.Name,
+				Workspace: ws.Name,
+				Focused:   n.Focused,
+				Visible:   n.Visible != nil && *n.Visible,
+				node:      n,
+			})
+		}
+	}
+	return windows
+}
+
+// isWindow reports whether n is a view (as opposed to a split container).
+func isWindow(n *sway.Node) bool {
+	switch n.Type {
+	case sway.NodeCon, sway.NodeFloatingCon:
+		return len(n.Nodes) == 0 && len(n.FloatingNodes) == 0
+	}
+	return false
+}
+
+// focusTracker accumulates how long each app has been focused. It also keeps
+// a log of the individual spans of focus (for up to maxSpanAge).
+type focusTracker struct {
+	since    time.Time
+	byApp    map[string]time.Duration
+	spans    []focusSpan
+	cur      string // app ID of the focused window, if any
+	curTitle string
+	curSince time.Time
+}
+
+const maxSpanAge = 8 * 24 * time.Hour
+
+// A focusSpan is a period during which one window was focused.
+type focusSpan struct {
+	AppID string
+	Title string
+	Start time.Time
+	End   time.Time
+}
+
+func newFocusTracker(now time.Time) *focusTracker {
+	t := new(focusTracker)
+	t.reset(now)
+	return t
+}
+
+func (t *focusTracker) reset(now time.Time) {
+	t.since = now
+	t.byApp = make(map[string]time.Duration)
+	t.spans = nil
+	t.curSince = now
+}
+
+// focus records that a window with the given app ID and title became focused
+// ("" means no window is focused).
+func (t *focusTracker) focus(appID, title string, now time.Time) {
+	if t.cur != "" {
+		t.byApp[t.cur] += now.Sub(t.curSince)
+		t.spans = append(t.spans, focusSpan{AppID: t.cur, Title: t.curTitle, Start: t.curSince, End: now})
+		i := 0
+		for i < len(t.spans) && now.Sub(t.spans[i].End) > maxSpanAge {
+			i++
+		}
+		t.spans = t.spans[i:]
+	}
+	t.cur = appID
+	t.curTitle = title
+	t.curSince = now
+}
+
+func (t *focusTracker) snapshot(now time.Time) focusStats {
+	stats := focusStats{Since: t.since, ByApp: make(map[string]float64)}
+	for app, d := range t.byApp {
+		stats.ByApp[app] = d.Seconds()
+	}
+	if t.cur != "" {
+		stats.ByApp[t.cur] += now.Sub(t.curSince).Seconds()
+	}
+	return stats
+}
+
+// spansSince returns the focus spans that end after since, including the
+// current one.
+func (t *focusTracker) spansSince(since, now time.Time) []focusSpan {
+	spans := []focusSpan{}
+	for _, span := range t.spans {
+		if span.End.After(since) {
+			spans = append(spans, span)
+		}
+	}
+	if t.cur != "" {
+		spans = append(spans, focusSpan{AppID: t.cur, Title: t.curTitle, Start: t.curSince, End: now})
+	}
+	return spans
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+// callRPC calls method on h as a client would (with request ID 1) and decodes
+// the result into result, if non-nil.
+func callRPC(h *daemonHandler, method string, params, result any) (*rpcResponse, error) {
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = b
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := h.handleRPC(line)
+	if resp == nil {
+		return nil, errors.New("no response")
+	}
+	if resp.Error != nil {
+		return resp, resp.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return resp, fmt.Errorf("bad result %s: %s", resp.Result, err)
+		}
+	}
+	return resp, nil
+}
+
+func TestHandleRPCErrors(t *testing.T) {
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	for _, tt := range []struct {
+		line   string
+		wantID string
+		code   int
+	}{
+		{`{"jsonrpc": "2.0", "id": 1, "method": "mru"`, "null", rpcParseError},
+		{`{"jsonrpc": "1.0", "id": 2, "method": "mru"}`, "2", rpcInvalidRequest},
+		{`{"jsonrpc": "2.0", "id": 3}`, "3", rpcInvalidRequest},
+		{`{"jsonrpc": "2.0", "id": "x", "method": "nope"}`, `"x"`, rpcMethodNotFound},
+		{`{"jsonrpc": "2.0", "id": 5, "method": "stats.spans", "params": {"since": 1}}`, "5", rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 6, "method": "group.switch", "params": {}}`, "6", rpcInvalidParams},
+	} {
+		resp := h.handleRPC([]byte(tt.line))
+		if resp == nil {
+			t.Errorf("%s: no response", tt.line)
+			continue
+		}
+		if string(resp.ID) != tt.wantID {
+			t.Errorf("%s: got id %s; want %s", tt.line, resp.ID, tt.wantID)
+		}
+		if resp.Error == nil {
+			t.Errorf("%s: got result %s; want error code %d", tt.line, resp.Result, tt.code)
+			continue
+		}
+		if resp.Error.Code != tt.code {
+			t.Errorf("%s: got error %v; want code %d", tt.line, resp.Error, tt.code)
+		}
+	}
+}
+
+func TestRPCWindows(t *testing.T) {
+	term := testWindow(10, "foot", "~")
+	term.Focused = true
+	floating := testWindow(12, "mpv", "video")
+	floating.Type = sway.NodeFloatingCon
+	ws2 := testWorkspace(4, "2")
+	ws2.FloatingNodes = []*sway.Node{floating}
+	split := &sway.Node{
+		ID:    5,
+		Type:  sway.NodeCon,
+		Nodes: []*sway.Node{testWindow(11, "firefox", "Mozilla Firefox")},
+	}
+	fake := newFakeSway(testTree(testWorkspace(3, "1", term, split), ws2))
+	h := newTestDaemon(fake, 10)
+
+	var got []windowInfo
+	if _, err := callRPC(h, "windows", nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []windowInfo{
+		{ID: 10, AppID: "foot", Title: "~", Workspace: "1", Focused: true},
+		{ID: 11, AppID: "firefox", Title: "Mozilla Firefox", Workspace: "1"},
+		{ID: 12, AppID: "mpv", Title: "video", Workspace: "2"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestRPCGroupList(t *testing.T) {
+	fake := newFakeSway(testTree(
+		testWorkspace(3, "1"),
+		testWorkspace(4, "work"+groupSep+"1"),
+	))
+	h := newTestDaemon(fake, 10)
+	h.Workspace(context.Background(), workspaceEvent(sway.WorkspaceFocus, "1"))
+
+	var got []groupInfo
+	if _, err := callRPC(h, "group.list", nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got groups %+v; want 2", got)
+	}
+	if g := got[0]; g.Name != defaultGroup || !g.Active || g.Focused != "1" || !slices.Equal(g.Workspaces, []string{"1"}) {
+		t.Errorf("got default group %+v", g)
+	}
+	if g := got[1]; g.Name != "work" || g.Active || !slices.Equal(g.Workspaces, []string{"1"}) {
+		t.Errorf("got work group %+v", g)
+	}
+}
+
+func TestRPCGroupSwitch(t *testing.T) {
+	fake := newFakeSway(testTree(
+		testWorkspace(3, "1"),
+		testWorkspace(4, "2"),
+		testWorkspace(5, "work"+groupSep+"1"),
+	))
+	h := newTestDaemon(fake, 10)
+	want := []string{
+		`rename workspace "1" to "default/1"`,
+		`rename workspace "2" to "default/2"`,
+		`rename workspace "work/1" to "1"`,
+		`focus output "eDP-1"`,
+		`workspace "1"`,
+		`workspace "1"`,
+	}
+
+	// A dry run returns the commands without running them.
+	var got []string
+	if _, err := callRPC(h, "group.switch", map[string]any{"name": "work", "dry_run": true}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("dry run: got commands %q; want %q", got, want)
+	}
+	if ran := fake.ran(); len(ran) > 0 {
+		t.Errorf("dry run: ran %q", ran)
+	}
+	if h.group != defaultGroup {
+		t.Errorf("dry run: active group is %q", h.group)
+	}
+
+	if _, err := callRPC(h, "group.switch", map[string]any{"name": "work"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ran, want := fake.ran(), []string{strings.Join(want, "; ")}; !slices.Equal(ran, want) {
+		t.Errorf("got commands %q; want %q", ran, want)
+	}
+	if h.group != "work" {
+		t.Errorf("active group is %q; want work", h.group)
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	client, server := net.Pipe()
+	defer client.Close()
+	go h.serveConn(server)
+
+	// Several requests can share a connection; each gets one response
+	// line with the matching ID. Notifications get none.
+	r := bufio.NewScanner(client)
+	for i, method := range []string{"mode", "stats.reset", "nope"} {
+		fmt.Fprintf(client, `{"jsonrpc": "2.0", "method": %q}`+"\n", method)
+		fmt.Fprintf(client, `{"jsonrpc": "2.0", "id": %d, "method": %q}`+"\n", i, method)
+		if !r.Scan() {
+			t.Fatalf("no response to %s: %v", method, r.Err())
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(r.Bytes(), &resp); err != nil {
+			t.Fatalf("bad response %q: %s", r.Bytes(), err)
+		}
+		if got, want := string(resp.ID), fmt.Sprint(i); got != want {
+			t.Errorf("%s: got id %s; want %s", method, got, want)
+		}
+		if (resp.Error != nil) != (method == "nope") {
+			t.Errorf("%s: got error %v", method, resp.Error)
+		}
+	}
+}
+
+func TestRPCActivate(t *testing.T) {
+	fake := newFakeSway(testTree(testWorkspace(3, "1", testWindow(10, "foot", ""), testWindow(11, "firefox", ""))))
+	h := newTestDaemon(fake, 10)
+	if _, err := callRPC(h, "activate", activateParams{ID: 11}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[con_id=11] focus"}
+	if got := fake.ran(); !slices.Equal(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+	if _, err := callRPC(h, "activate", activateParams{ID: 12}, nil); err == nil {
+		t.Error("activating a missing window: got nil error")
+	}
+	if _, err := callRPC(h, "activate", nil, nil); err == nil {
+		t.Error("activate without params: got nil error")
+	}
+}
+
+func TestHandleRPCNotification(t *testing.T) {
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	h.Window(context.Background(), windowEvent(sway.WindowFocus, 10, "foot"))
+	h.Window(context.Background(), windowEvent(sway.WindowFocus, 11, "firefox"))
+
+	// A notification runs the method but gets no response, even on error.
+	for _, line := range []string{
+		`{"jsonrpc": "2.0", "method": "cycle.begin"}`,
+		`{"jsonrpc": "2.0", "method": "nope"}`,
+	} {
+		if resp := h.handleRPC([]byte(line)); resp != nil {
+			t.Errorf("%s: got response %+v", line, resp)
+		}
+	}
+	h.Window(context.Background(), windowEvent(sway.WindowFocus, 10, "foot"))
+	var mru []listWindow
+	if _, err := callRPC(h, "mru", nil, &mru); err != nil {
+		t.Fatal(err)
+	}
+	if want := []listWindow{{11, "firefox"}, {10, "foot"}}; !slices.Equal(mru, want) {
+		t.Errorf("cycle.begin notification: got MRU %v; want %v", mru, want)
+	}
+
+	// Requests that can't be parsed still get an error response.
+	for _, line := range []string{
+		`{"jsonrpc": "2.0", "method": "mru"`,
+		`{"jsonrpc": "2.0"}`,
+	} {
+		if resp := h.handleRPC([]byte(line)); resp == nil || resp.Error == nil {
+			t.Errorf("%s: got response %+v; want an error", line, resp)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"regexp"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// A windowSelector holds the window-matching flags shared by the commands
+// that operate on a chosen window.
+type windowSelector struct {
+	title     string
+	appID     string
+	workspace string
+}
+
+func (s *windowSelector) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.title, "title", "", "Window title (regex match)")
+	fs.StringVar(&s.appID, "appid", "", "App ID or, for Xwayland windows, X11 class (exact match)")
+	fs.StringVar(&s.workspace, "workspace", "", "Workspace name (exact match)")
+}
+
+// empty reports whether no selector flags were given.
+func (s *windowSelector) empty() bool {
+	return s.title == "" && s.appID == "" && s.workspace == ""
+}
+
+// focusedSelector is a selector value that stands for the corresponding
+// property of the focused window.
+const focusedSelector = "__focused__"
+
+// relative reports whether any selector value is focusedSelector.
+func (s *windowSelector) relative() bool {
+	return s.title == focusedSelector || s.appID == focusedSelector || s.workspace == focusedSelector
+}
+
+// matcher returns a function that reports whether a node in root is a window
+// matching the selector. Any focusedSelector values are resolved using the
+// focused window in root.
+func (s *windowSelector) matcher(root *sway.Node) func(*sway.Node) bool {
+	title, appID, workspace := s.title, s.appID, s.workspace
+	if s.relative() {
+		focused := root.FocusedNode()
+		if focused == nil {
+			log.Fatal("No focused node")
+		}
+		if title == focusedSelector {
+			title = "^" + regexp.QuoteMeta(focused.Name) + "$"
+		}
+		if appID == focusedSelector {
+			appID = nodeAppID(focused)
+		}
+		if workspace == focusedSelector {
+			ws := findAncestor(root, focused.ID, sway.NodeWorkspace)
+			if ws == nil {
+				log.Fatal("Cannot determine the workspace of the focused node")
+			}
+			workspace = ws.Name
+		}
+	}
+
+	titleRE := compileTitle(title)
+	var inWorkspace map[int64]struct{}
+	if workspace != "" {
+		inWorkspace = make(map[int64]struct{})
+		for _, ws := range treeSelect(root, func(n *sway.Node) bool {
+			return n.Type == sway.NodeWorkspace && n.Name == workspace
+		}) {
+			walkTree(ws, func(n *sway.Node) { inWorkspace[n.ID] = struct{}{} })
+		}
+	}
+
+	return func(n *sway.Node) bool {
+		switch n.Type {
+		case sway.NodeCon, sway.NodeFloatingCon:
+		default:
+			return false
+		}
+		if titleRE != nil && !titleRE.MatchString(n.Name) {
+			return false
+		}
+		if appID != "" && nodeAppID(n) != appID {
+			return false
+		}
+		if inWorkspace != nil {
+			if _, ok := inWorkspace[n.ID]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// windowMatcher is like matcher, but for the windows listed by a
+// windowSource.
+func (s *windowSelector) windowMatcher(windows []windowInfo) func(windowInfo) bool {
+	title, appID, workspace := s.title, s.appID, s.workspace
+	if s.relative() {
+		focused := focusedWindow(windows)
+		if focused == nil {
+			log.Fatal("No focused window")
+		}
+		if title == focusedSelector {
+			title = "^" + regexp.QuoteMeta(focused.Title) + "$"
+		}
+		if appID == focusedSelector {
+			appID = focused.AppID
+		}
+		if workspace == focusedSelector {
+			workspace = focused.Workspace
+		}
+	}
+	titleRE := compileTitle(title)
+	return func(w windowInfo) bool {
+		if titleRE != nil && !titleRE.MatchString(w.Title) {
+			return false
+		}
+		if appID != "" && w.AppID != appID {
+			return false
+		}
+		if workspace != "" && w.Workspace != workspace {
+			return false
+		}
+		return true
+	}
+}
+
+// compileTitle compiles the -title regex, if any.
+func compileTitle(title string) *regexp.Regexp {
+	if title == "" {
+		return nil
+	}
+	re, err := regexp.Compile(title)
+	if err != nil {
+		log.Fatalln("Bad -title regex:", err)
+	}
+	return re
+}
+
+// visibleWindow returns the first visible window in root matching the
+// selector or, if the selector is empty, the focused window. It exits if
+// there is no such window.
+func (s *windowSelector) visibleWindow(root *sway.Node) *sway.Node {
+	if s.empty() {
+		n := root.FocusedNode()
+		if n == nil || !isWindow(n) {
+			log.Fatal("Focused node is not a window")
+		}
+		return n
+	}
+	pick := s.matcher(root)
+	matches := treeSelect(root, func(n *sway.Node) bool {
+		return pick(n) && n.Visible != nil && *n.Visible
+	})
+	if len(matches) == 0 {
+		log.Fatalln("No visible match")
+	}
+	return matches[0]
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+func TestWindowSelectorMatcher(t *testing.T) {
+	focused := testWindow(10, "foot", "~/src")
+	focused.Focused = true
+	root := testTree(
+		testWorkspace(3, "1", focused, testWindow(11, "firefox", "Mozilla Firefox")),
+		testWorkspace(4, "2", testWindow(12, "foot", "htop"), testWindow(13, "firefox", "Docs - Mozilla Firefox")),
+	)
+	for _, tt := range []struct {
+		sel  windowSelector
+		want []int64
+	}{
+		{windowSelector{}, []int64{10, 11, 12, 13}},
+		{windowSelector{appID: "foot"}, []int64{10, 12}},
+		{windowSelector{title: "Firefox$"}, []int64{11, 13}},
+		{windowSelector{title: "^Docs"}, []int64{13}},
+		{windowSelector{workspace: "2"}, []int64{12, 13}},
+		{windowSelector{appID: "firefox", workspace: "1"}, []int64{11}},
+		{windowSelector{appID: "mpv"}, nil},
+		{windowSelector{appID: focusedSelector}, []int64{10, 12}},
+		{windowSelector{workspace: focusedSelector}, []int64{10, 11}},
+		{windowSelector{title: focusedSelector}, []int64{10}},
+	} {
+		var got []int64
+		for _, n := range treeSelect(root, tt.sel.matcher(root)) {
+			got = append(got, n.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: got %v; want %v", tt.sel, got, tt.want)
+		}
+	}
+}
+
+func TestWindowSelectorVisibleWindow(t *testing.T) {
+	visible, hidden := true, false
+	w0 := testWindow(10, "foot", "a")
+	w0.Visible = &hidden
+	w1 := testWindow(11, "foot", "b")
+	w1.Visible = &visible
+	w2 := testWindow(12, "firefox", "c")
+	w2.Visible = &visible
+	w2.Focused = true
+	root := testTree(testWorkspace(3, "1", w0, w1, w2))
+
+	for _, tt := range []struct {
+		sel  windowSelector
+		want *sway.Node
+	}{
+		{windowSelector{}, w2},
+		{windowSelector{appID: "foot"}, w1},
+	} {
+		if got := tt.sel.visibleWindow(root); got != tt.want {
+			t.Errorf("%+v: got window %d; want %d", tt.sel, got.ID, tt.want.ID)
+		}
+	}
+}
+
+func TestWindowSelectorWindowMatcher(t *testing.T) {
+	windows := []windowInfo{
+		{ID: 10, AppID: "foot", Title: "~/src", Workspace: "1", Focused: true},
+		{ID: 11, AppID: "firefox", Title: "Mozilla Firefox", Workspace: "1"},
+		{ID: 12, AppID: "foot", Title: "htop", Workspace: "2"},
+		{ID: 13, AppID: "firefox", Title: "Docs - Mozilla Firefox"},
+	}
+	for _, tt := range []struct {
+		sel  windowSelector
+		want []int64
+	}{
+		{windowSelector{appID: "foot"}, []int64{10, 12}},
+		{windowSelector{title: "Firefox$"}, []int64{11, 13}},
+		{windowSelector{workspace: "1"}, []int64{10, 11}},
+		{windowSelector{appID: focusedSelector}, []int64{10, 12}},
+		{windowSelector{workspace: focusedSelector, appID: "firefox"}, []int64{11}},
+		{windowSelector{title: focusedSelector}, []int64{10}},
+	} {
+		pick := tt.sel.windowMatcher(windows)
+		var got []int64
+		for _, w := range windows {
+			if pick(w) {
+				got = append(got, w.ID)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: got %v; want %v", tt.sel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+func cmdShot(args []string) {
+	fs := flag.NewFlagSet("shot", flag.ExitOnError)
+	var sel windowSelector
+	sel.addFlags(fs)
+	useSlurp := fs.Bool("slurp", false, "Select a region of the window with slurp")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl shot [flags...] [file]
+
+where the flags are:
+`)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, `
+The shot command takes a screenshot of a window using grim. The window is the
+first visible window matching -title, -appid, and -workspace (as for the focus
+command) or, if none of those are given, the focused window. The screenshot
+covers the window's contents, excluding borders and title bar.
+
+If -slurp is given, slurp is run to select a region and the screenshot covers
+the part of that region that lies within the window.
+
+The file is passed to grim; if it is omitted, grim picks a name.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	n := sel.visibleWindow(root)
+	r := windowGeometry(n)
+	if *useSlurp {
+		s, err := slurp()
+		if err != nil {
+			log.Fatalln("Error running slurp:", err)
+		}
+		var ok bool
+		r, ok = intersectRects(r, s)
+		if !ok {
+			log.Fatal("Selected region is outside the window")
+		}
+	}
+
+	grimArgs := []string{"-g", formatRect(r)}
+	if fs.NArg() == 1 {
+		grimArgs = append(grimArgs, fs.Arg(0))
+	}
+	cmd := exec.Command("grim", grimArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Fatalln("Error running grim:", err)
+	}
+}
+
+// windowGeometry gives the absolute position and size of the contents of the
+// window n.
+func windowGeometry(n *sway.Node) sway.Rect {
+	return sway.Rect{
+		X:      n.Rect.X + n.WindowRect.X,
+		Y:      n.Rect.Y + n.WindowRect.Y,
+		Width:  n.WindowRect.Width,
+		Height: n.WindowRect.Height,
+	}
+}
+
+// formatRect formats r in the "X,Y WxH" form used by grim and slurp.
+func formatRect(r sway.Rect) string {
+	return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.Width, r.Height)
+}
+
+func parseRect(s string) (sway.Rect, error) {
+	var r sway.Rect
+	if _, err := fmt.Sscanf(s, "%d,%d %dx%d", &r.X, &r.Y, &r.Width, &r.Height); err != nil {
+		return r, fmt.Errorf("bad geometry %q: %s", s, err)
+	}
+	return r, nil
+}
+
+func slurp() (sway.Rect, error) {
+	cmd := exec.Command("slurp")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return sway.Rect{}, err
+	}
+	return parseRect(strings.TrimSpace(string(out)))
+}
+
+// intersectRects returns the intersection of r0 and r1. It returns false if
+// the intersection is empty.
+func intersectRects(r0, r1 sway.Rect) (sway.Rect, bool) {
+	x0, y0 := r0.X, r0.Y
+	if r1.X > x0 {
+		x0 = r1.X
+	}
+	if r1.Y > y0 {
+		y0 = r1.Y
+	}
+	x1, y1 := r0.X+r0.Width, r0.Y+r0.Height
+	if r1.X+r1.Width < x1 {
+		x1 = r1.X + r1.Width
+	}
+	if r1.Y+r1.Height < y1 {
+		y1 = r1.Y + r1.Height
+	}
+	if x1 <= x0 || y1 <= y0 {
+		return sway.Rect{}, false
+	}
+	return sway.Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, true
+}
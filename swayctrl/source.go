@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// The backends for the window commands, chosen with the global -backend
+// flag.
+const (
+	backendSway     = "sway"
+	backendToplevel = "toplevel"
+)
+
+// backend is set by the global -backend flag or, if that isn't given,
+// detected in main.
+var backend string
+
+// requireSway exits if the toplevel backend is in use; what names the
+// sway-only command or feature.
+func requireSway(what string) {
+	if backend == backendToplevel {
+		log.Fatalf("%s requires the sway backend", what)
+	}
+}
+
+// A windowSource lists and focuses windows. The focus, prev, and launch
+// commands and the daemon's focus history work on a windowSource, so they
+// support compositors other than sway through the toplevel backend (see
+// toplevel.go).
+type windowSource interface {
+	// windows lists the windows (in tree order, for sway).
+	windows(ctx context.Context) ([]windowInfo, error)
+	// activate focuses w and, if warp is set, moves the pointer to its
+	// center.
+	activate(ctx context.Context, w windowInfo, warp bool) error
+}
+
+// newWindowSource returns the windowSource for the backend. The toplevel
+// backend works through the daemon, which holds the Wayland connection:
+// toplevel handles only have meaning on the connection that received them.
+func newWindowSource(ctx context.Context) windowSource {
+	if backend == backendToplevel {
+		c, err := dialDaemon()
+		if err != nil {
+			log.Fatalln("Error connecting to local daemon (is it running?):", err)
+		}
+		return daemonSource{c}
+	}
+	return swaySource{newClient(ctx)}
+}
+
+// swaySource is the windowSource for the sway backend.
+type swaySource struct {
+	client swayClient
+}
+
+func (s swaySource) windows(ctx context.Context) ([]windowInfo, error) {
+	root, err := s.client.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return treeWindows(root), nil
+}
+
+func (s swaySource) activate(ctx context.Context, w windowInfo, warp bool) error {
+	n := w.node
+	if n == nil {
+		root, err := s.client.GetTree(ctx)
+		if err != nil {
+			return err
+		}
+		if n = findNode(root, w.ID); n == nil {
+			return fmt.Errorf("no window with con_id %d", w.ID)
+		}
+	}
+	return runCommand(ctx, s.client, focusCommand(n, warp))
+}
+
+// daemonSource is the windowSource for the toplevel backend, which asks
+// the daemon.
+type daemonSource struct {
+	c *daemonConn
+}
+
+func (s daemonSource) windows(ctx context.Context) ([]windowInfo, error) {
+	var windows []windowInfo
+	if err := s.c.call("windows", nil, &windows); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+func (s daemonSource) activate(ctx context.Context, w windowInfo, warp bool) error {
+	if dryRun {
+		fmt.Printf("activate %d\n", w.ID)
+		return nil
+	}
+	return s.c.call("activate", activateParams{ID: w.ID, Warp: warp}, nil)
+}
+
+// focusedWindow returns the focused window in windows, or nil if there
+// isn't one.
+func focusedWindow(windows []windowInfo) *windowInfo {
+	for i := range windows {
+		if windows[i].Focused {
+			return &windows[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestFocusExisting(t *testing.T) {
+	visible, hidden := true, false
+	w0 := testWindow(10, "foot", "a")
+	w0.Visible = &hidden
+	w1 := testWindow(11, "foot", "b")
+	w1.Visible = &visible
+	w1.Focused = true
+	w2 := testWindow(12, "foot", "c")
+	w2.Visible = &visible
+	w3 := testWindow(13, "foot", "d")
+	w3.Visible = &hidden
+	fake := newFakeSway(testTree(testWorkspace(3, "1", w0, w1, w2), testWorkspace(4, "2", w3)))
+	src := swaySource{fake}
+	ctx := context.Background()
+	windows := listWindows(ctx, src)
+	all := func(windowInfo) bool { return true }
+
+	for _, tt := range []struct {
+		name    string
+		mru     []int64
+		pick    func(windowInfo) bool
+		avoidID int64
+		want    string
+	}{
+		{"tree order", nil, all, -1, "[con_id=11] focus"},
+		{"avoid", nil, all, 11, "[con_id=12] focus"},
+		{"visible first", []int64{13, 10, 12}, all, 11, "[con_id=12] focus"},
+		{"then MRU", []int64{13, 10}, func(w windowInfo) bool { return !w.Visible }, -1, "[con_id=13] focus"},
+		{"MRU before unknown", []int64{10}, func(w windowInfo) bool { return !w.Visible }, -1, "[con_id=10] focus"},
+		{"only the avoided window", nil, func(w windowInfo) bool { return w.ID == 11 }, 11, "[con_id=11] focus"},
+	} {
+		idToMRUIdx := make(map[int64]int)
+		for i, id := range tt.mru {
+			idToMRUIdx[id] = i
+		}
+		if !focusExisting(ctx, src, windows, idToMRUIdx, tt.pick, tt.avoidID, false) {
+			t.Errorf("%s: no window focused", tt.name)
+			continue
+		}
+		if got := fake.ran(); !slices.Equal(got, []string{tt.want}) {
+			t.Errorf("%s: got commands %q; want %q", tt.name, got, tt.want)
+		}
+	}
+	if focusExisting(ctx, src, windows, nil, func(windowInfo) bool { return false }, -1, false) {
+		t.Error("focused a window with no matches")
+	}
+}
+
+// fakeSource is a windowSource with a fixed list of windows, plus one more
+// (as if launched) from the appearAt'th listing on. It records the windows
+// it activates.
+type fakeSource struct {
+	mu        sync.Mutex
+	base      []windowInfo
+	launched  windowInfo
+	appearAt  int
+	listings  int
+	activated []int64
+}
+
+func (s *fakeSource) windows(ctx context.Context) ([]windowInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listings++
+	windows := slices.Clone(s.base)
+	if s.listings >= s.appearAt {
+		windows = append(windows, s.launched)
+	}
+	return windows, nil
+}
+
+func (s *fakeSource) activate(ctx context.Context, w windowInfo, warp bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activated = append(s.activated, w.ID)
+	return nil
+}
+
+func TestLaunchAndFocus(t *testing.T) {
+	src := &fakeSource{
+		base:     []windowInfo{{ID: 10, AppID: "foot"}, {ID: 11, AppID: "firefox"}},
+		launched: windowInfo{ID: 12, AppID: "mpv"},
+		appearAt: 3, // the second poll after launching
+	}
+	launchAndFocus(context.Background(), src, false, "true")
+	if want := []int64{12}; !slices.Equal(src.activated, want) {
+		t.Errorf("activated %v; want %v", src.activated, want)
+	}
+	if src.listings != 3 {
+		t.Errorf("listed the windows %d times; want 3", src.listings)
+	}
+}
+
+func TestLaunchAndFocusDryRun(t *testing.T) {
+	dryRun = true
+	defer func() { dryRun = false }()
+	src := &fakeSource{
+		base:     []windowInfo{{ID: 10, AppID: "foot"}},
+		launched: windowInfo{ID: 12, AppID: "mpv"},
+		appearAt: 2,
+	}
+	launchAndFocus(context.Background(), src, false, "true")
+	if len(src.activated) > 0 {
+		t.Errorf("activated %v in a dry run", src.activated)
+	}
+	if src.listings != 1 {
+		t.Errorf("listed the windows %d times; want 1 (no polling)", src.listings)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func cmdSplitAuto(args []string) {
+	fs := flag.NewFlagSet("splitauto", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl splitauto
+
+The splitauto command splits the focused container along its longer side: it
+runs splith if the container is wider than it is tall and splitv otherwise.
+The next window opened there therefore goes beside or below it, as with
+autotiling.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	root, err := client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	focused := root.FocusedNode()
+	if focused == nil {
+		log.Fatal("No focused node")
+	}
+	command := "splitv"
+	if focused.Rect.Width > focused.Rect.Height {
+		command = "splith"
+	}
+	command = fmt.Sprintf("[con_id=%d] %s", focused.ID, command)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+// swallowMark is the prefix of the mark (swallowMark+con_id) put on a window
+// that has been swallowed by the window with that con_id.
+const swallowMark = "_swayctrl_swallowed_by_"
+
+// swallow handles window events for swallowing: when a window is opened by a
+// process running in one of the configured parent windows (usually
+// terminals), the new window takes the parent's place and the parent is
+// hidden in the scratchpad until the new window closes.
+func (h *daemonHandler) swallow(ctx context.Context, e sway.WindowEvent) {
+	parents := h.config().SwallowParents
+	if len(parents) == 0 {
+		return
+	}
+	switch e.Change {
+	case sway.WindowNew:
+		if e.Container.PID == nil || slices.Contains(parents, nodeAppID(&e.Container)) {
+			return
+		}
+		root, err := h.client.GetTree(ctx)
+		if err != nil {
+			log.Println("GET_TREE failed:", err)
+			return
+		}
+		parent := findSwallowParent(root, int(*e.Container.PID), parents)
+		if parent == nil {
+			return
+		}
+		mark := fmt.Sprintf("%s%d", swallowMark, e.Container.ID)
+		commands := []string{
+			fmt.Sprintf("[con_id=%d] mark --add %s", parent.ID, mark),
+			fmt.Sprintf("[con_id=%d] move container to mark %s", e.Container.ID, mark),
+			fmt.Sprintf("[con_id=%d] move scratchpad", parent.ID),
+			fmt.Sprintf("[con_id=%d] focus", e.Container.ID),
+		}
+		h.runCommands(ctx, commands)
+	case sway.WindowClose:
+		root, err := h.client.GetTree(ctx)
+		if err != nil {
+			log.Println("GET_TREE failed:", err)
+			return
+		}
+		mark := fmt.Sprintf("%s%d", swallowMark, e.Container.ID)
+		matches := treeSelect(root, func(n *sway.Node) bool { return slices.Contains(n.Marks, mark) })
+		if len(matches) == 0 {
+			return
+		}
+		parent := matches[0]
+		commands := []string{
+			fmt.Sprintf("[con_id=%d] unmark %s", parent.ID, mark),
+			fmt.Sprintf("[con_id=%d] scratchpad show", parent.ID),
+			fmt.Sprintf("[con_id=%d] floating disable", parent.ID),
+			fmt.Sprintf("[con_id=%d] focus", parent.ID),
+		}
+		h.runCommands(ctx, commands)
+	}
+}
+
+func (h *daemonHandler) runCommands(ctx context.Context, commands []string) {
+	command := strings.Join(commands, "; ")
+	if h.verbose {
+		log.Printf("Running: %s", command)
+	}
+	if err := runCommand(ctx, h.client, command); err != nil {
+		log.Printf("Error running command %q: %s", command, err)
+	}
+}
+
+// findSwallowParent returns the window in root (if any) whose app ID is one
+// of parents and whose process is an ancestor of the process pid.
+func findSwallowParent(root *sway.Node, pid int, parents []string) *sway.Node {
+	byPID := make(map[int]*sway.Node)
+	for _, n := range treeSelect(root, isWindow) {
+		if n.PID != nil && slices.Contains(parents, nodeAppID(n)) {
+			byPID[int(*n.PID)] = n
+		}
+	}
+	if len(byPID) == 0 {
+		return nil
+	}
+	for pid > 1 {
+		ppid, err := parentPID(pid)
+		if err != nil {
+			return nil
+		}
+		if n, ok := byPID[ppid]; ok {
+			return n
+		}
+		pid = ppid
+	}
+	return nil
+}
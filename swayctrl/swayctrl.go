@@ -1,21 +1,21 @@
 package main
 
 import (
+	"container/list"
 	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/cespare/subcmd"
@@ -55,11 +55,91 @@ var cmds = []subcmd.Command{
 		Description: "print the titles of the currently focused node whenever focus changes",
 		Do:          cmdFocusTitle,
 	},
+	{
+		Name:        "splitauto",
+		Description: "split the focused container along its longer side",
+		Do:          cmdSplitAuto,
+	},
+	{
+		Name:        "focus-follows-mouse",
+		Description: "toggle or set sway's focus_follows_mouse option",
+		Do:          cmdFocusFollowsMouse,
+	},
+	{
+		Name:        "float-toggle",
+		Description: "toggle floating, applying a size preset and remembering the tiled position",
+		Do:          cmdFloatToggle,
+	},
+	{
+		Name:        "follow",
+		Description: "make a window follow the focused workspace",
+		Do:          cmdFollow,
+	},
+	{
+		Name:        "mode",
+		Description: "print the binding mode whenever it changes",
+		Do:          cmdMode,
+	},
+	{
+		Name:        "shot",
+		Description: "take a screenshot of a window",
+		Do:          cmdShot,
+	},
+	{
+		Name:        "geometry",
+		Description: "print the geometry of a window for grim, slurp, and the like",
+		Do:          cmdGeometry,
+	},
+	{
+		Name:        "output",
+		Description: "output-related commands (run 'swayctrl output -h' for details)",
+		Do:          cmdOutput,
+	},
+	{
+		Name:        "group",
+		Description: "workspace group commands (run 'swayctrl group -h' for details)",
+		Do:          cmdGroup,
+	},
+	{
+		Name:        "display",
+		Description: "turn outputs off or on",
+		Do:          cmdDisplay,
+	},
+	{
+		Name:        "workspace",
+		Description: "workspace-related commands (run 'swayctrl workspace -h' for details)",
+		Do:          cmdWorkspace,
+	},
+	{
+		Name:        "bar",
+		Description: "change the mode of the bars on an output",
+		Do:          cmdBar,
+	},
+	{
+		Name:        "input",
+		Description: "input device commands (run 'swayctrl input -h' for details)",
+		Do:          cmdInput,
+	},
+	{
+		Name:        "watch",
+		Description: "print sway events as JSON",
+		Do:          cmdWatch,
+	},
 	{
 		Name:        "swaymsg",
 		Description: "run swaymsg with the correct SWAYSOCK",
 		Do:          cmdSwaymsg,
 	},
+	{
+		Name:        "report",
+		Description: "print how long apps have been focused",
+		Do:          cmdReport,
+	},
+	{
+		Name:        "batch",
+		Description: "run many commands read from stdin or a file",
+		Do:          cmdBatch,
+	},
 	{
 		Name:        "daemon",
 		Description: "run subscriber daemon",
@@ -70,38 +150,101 @@ var cmds = []subcmd.Command{
 func main() {
 	log.SetFlags(0)
 
-	// Make swayctrl work even if SWAYSOCK isn't set correctly (e.g., from
-	// inside a tmux session that has been running for a while).
-	// We don't use sway.WithSocketPath because sway.Subscribe doesn't have
-	// a corresponding way to configure it :\
-	if _, err := os.Stat(os.Getenv("SWAYSOCK")); err != nil {
-		u, err := user.Current()
-		if err != nil {
-			log.Fatal(err)
-		}
-		glob := fmt.Sprintf("/run/user/%s/sway-ipc.%[1]s.*.sock", u.Uid)
-		files, err := filepath.Glob(glob)
-		if err != nil {
-			log.Fatalf("Error discovering sway socket file: %s", err)
-		}
-		if len(files) == 0 {
-			log.Fatal("Cannot discover sway socket file")
-		}
-		if len(files) > 1 {
-			log.Fatalf("Multiple socket files matching pattern %s", glob)
+	flag.BoolVar(&dryRun, "n", false, "Print sway commands instead of running them")
+	flag.BoolVar(&dryRun, "dry-run", false, "Same as -n")
+	flag.BoolVar(&traceIPC, "trace", false, "Log each sway IPC round trip with its duration")
+	flag.StringVar(&daemonSock, "socket", "", "Path of the daemon's socket (default: see 'swayctrl daemon -h')")
+	flag.StringVar(&backend, "backend", "", "The `backend`: sway or toplevel (default: sway if it is running, else toplevel)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl [-n] [-trace] [-socket path] [-backend sway|toplevel] COMMAND
+
+where the flags are:
+
+`)
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, `
+and the possible commands are:
+
+`)
+		subcmd.PrintDefaults(cmds)
+		fmt.Fprint(os.Stderr, `
+Run 'swayctrl COMMAND -h' to see more information about a command.
+
+With -backend toplevel, the focus, prev, and launch commands and the daemon
+work on other wlroots-based compositors (such as river or Hyprland) using the
+wlr-foreign-toplevel-management Wayland protocol. This backend has no
+workspaces and cannot move the pointer, and focus, prev, and launch need the
+daemon to be running. The other commands require sway.
+`)
+	}
+	flag.Parse()
+
+	switch backend {
+	case "", backendSway, backendToplevel:
+	default:
+		log.Fatalf("Unknown backend %q (must be sway or toplevel)", backend)
+	}
+	if backend != backendToplevel {
+		// Make swayctrl work even if SWAYSOCK isn't set correctly
+		// (e.g., from inside a tmux session that has been running for a
+		// while). We don't use sway.WithSocketPath because
+		// sway.Subscribe doesn't have a corresponding way to configure
+		// it :\
+		sock, err := findSwaySock()
+		switch {
+		case err == nil:
+			os.Setenv("SWAYSOCK", sock)
+			backend = backendSway
+		case backend == "" && os.Getenv("WAYLAND_DISPLAY") != "":
+			backend = backendToplevel
+		default:
+			log.Fatalln("Cannot connect to sway:", err)
 		}
-		os.Setenv("SWAYSOCK", files[0])
 	}
 
-	subcmd.Run(cmds)
+	rootRunner = subcmd.New("swayctrl", cmds, flag.ExitOnError)
+	rootRunner.Usage = flag.Usage
+	rootRunner.Run(flag.Args())
+}
+
+// findSwaySock returns the path of the sway socket: $SWAYSOCK if it exists
+// or else the only socket file of the user's sway.
+func findSwaySock() (string, error) {
+	if sock := os.Getenv("SWAYSOCK"); sock != "" {
+		if _, err := os.Stat(sock); err == nil {
+			return sock, nil
+		}
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	glob := fmt.Sprintf("/run/user/%s/sway-ipc.%[1]s.*.sock", u.Uid)
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return "", fmt.Errorf("error discovering sway socket file: %s", err)
+	}
+	if len(files) == 0 {
+		return "", errors.New("cannot discover sway socket file")
+	}
+	if len(files) > 1 {
+		return "", fmt.Errorf("multiple socket files matching pattern %s", glob)
+	}
+	return files[0], nil
 }
 
 func newClient(ctx context.Context) sway.Client {
+	requireSway("This command")
+	if inBatch {
+		return batchNewClient(ctx)
+	}
 	client, err := sway.New(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return client
+	return wrapClient(client)
 }
 
 func cmdLaunch(args []string) {
@@ -123,15 +266,15 @@ application's window.
 	}
 
 	ctx := context.Background()
-	client := newClient(ctx)
-	launchAndFocus(ctx, client, fs.Args()[0], fs.Args()[1:]...)
+	launchAndFocus(ctx, newWindowSource(ctx), false, fs.Args()[0], fs.Args()[1:]...)
 }
 
 func cmdFocus(args []string) {
 	fs := flag.NewFlagSet("focus", flag.ExitOnError)
-	title := fs.String("title", "", "Window title (regex match)")
-	appID := fs.String("appid", "", "App ID (exact match)")
+	var sel windowSelector
+	sel.addFlags(fs)
 	launchCmd := fs.String("launch", "", "Launch if window doesn't exist (optional)")
+	warp := fs.Bool("warp", false, "Move the pointer to the center of the focused window")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage:
 
@@ -145,22 +288,32 @@ The focus command selects and focuses the first matching window (in tree order).
 It prioritizes visible windows over not-visible ones and, as a secondary
 preference, more recently focused windows. The daemon must be running.
 
+As with sway criteria, the special value __focused__ given to -title, -appid,
+or -workspace matches the title, app ID, or workspace of the focused window.
+When any __focused__ value is used, the focused window itself is chosen only
+if no other window matches. For example,
+
+  swayctrl focus -appid __focused__ -workspace __focused__
+
+focuses another window of the same app on the same workspace.
+
 If -launch is given, use that command (passed to /bin/sh -c) to launch the
 application if focusing it fails.
+
+If -warp is given, the pointer is moved to the center of the newly focused
+window.
 `)
 	}
 	fs.Parse(args)
 
-	if *title == "" && *appID == "" {
-		log.Fatalln("At least one of -title or -appid is required")
+	if sel.empty() {
+		log.Fatalln("At least one of -title, -appid, or -workspace is required")
 	}
-	var titleRE *regexp.Regexp
-	if *title != "" {
-		var err error
-		titleRE, err = regexp.Compile(*title)
-		if err != nil {
-			log.Fatalln("Bad -title regex:", err)
-		}
+	if sel.workspace != "" {
+		requireSway("-workspace")
+	}
+	if *warp {
+		requireSway("-warp")
 	}
 
 	mruList := getMRUListFromDaemon()
@@ -170,48 +323,55 @@ application if focusing it fails.
 	}
 
 	ctx := context.Background()
-	client := newClient(ctx)
-	pick := func(n *sway.Node) bool {
-		switch n.Type {
-		case sway.NodeCon, sway.NodeFloatingCon:
-		default:
-			return false
-		}
-		if titleRE != nil && !titleRE.MatchString(n.Name) {
-			return false
-		}
-		if *appID != "" && (n.AppID == nil || *n.AppID != *appID) {
-			return false
-		}
-		return true
+	src := newWindowSource(ctx)
+	windows := listWindows(ctx, src)
+	pick := sel.windowMatcher(windows)
+	avoidID := int64(-1)
+	if sel.relative() {
+		avoidID = focusedWindow(windows).ID
 	}
 
-	if focusExisting(ctx, client, idToMRUIdx, pick) {
+	if focusExisting(ctx, src, windows, idToMRUIdx, pick, avoidID, *warp) {
 		return
 	}
 	if *launchCmd == "" {
 		log.Fatalln("No match")
 	}
 	log.Printf("Running %q", *launchCmd)
-	launchAndFocus(ctx, client, "/bin/sh", "-c", *launchCmd)
+	launchAndFocus(ctx, src, *warp, "/bin/sh", "-c", *launchCmd)
 }
 
-func focusExisting(ctx context.Context, client sway.Client, idToMRUIdx map[int64]int, pick func(n *sway.Node) bool) (ok bool) {
-	root, err := client.GetTree(ctx)
+// listWindows lists the windows of src, exiting on error.
+func listWindows(ctx context.Context, src windowSource) []windowInfo {
+	windows, err := src.windows(ctx)
 	if err != nil {
-		log.Fatalln("GET_TREE failed:", err)
+		log.Fatalln("Error listing windows:", err)
+	}
+	return windows
+}
+
+// focusExisting focuses the best window in windows matching pick. The window
+// with ID avoidID is only chosen if there are no other matches.
+func focusExisting(ctx context.Context, src windowSource, windows []windowInfo, idToMRUIdx map[int64]int, pick func(windowInfo) bool, avoidID int64, warp bool) (ok bool) {
+	var matches []windowInfo
+	for _, w := range windows {
+		if pick(w) {
+			matches = append(matches, w)
+		}
 	}
-	matches := treeSelect(root, pick)
 	if len(matches) == 0 {
 		return false
 	}
-	// Prioritize visible windows.
-	slices.SortStableFunc(matches, func(n0, n1 *sway.Node) bool {
-		if *n0.Visible != *n1.Visible {
-			return *n0.Visible
+	// Deprioritize avoidID, then prioritize visible windows.
+	slices.SortStableFunc(matches, func(w0, w1 windowInfo) bool {
+		if (w0.ID == avoidID) != (w1.ID == avoidID) {
+			return w1.ID == avoidID
+		}
+		if w0.Visible != w1.Visible {
+			return w0.Visible
 		}
-		i0, ok0 := idToMRUIdx[n0.ID]
-		i1, ok1 := idToMRUIdx[n1.ID]
+		i0, ok0 := idToMRUIdx[w0.ID]
+		i1, ok1 := idToMRUIdx[w1.ID]
 		if ok0 != ok1 {
 			return ok0
 		}
@@ -220,41 +380,37 @@ func focusExisting(ctx context.Context, client sway.Client, idToMRUIdx map[int64
 		}
 		return i0 < i1
 	})
-	log.Printf("Focusing con_id %d", matches[0].ID)
-	command := fmt.Sprintf("[con_id=%d] focus", matches[0].ID)
-	if err := runCommand(ctx, client, command); err != nil {
-		log.Fatalf("Error running command %q: %s", command, err)
+	log.Printf("Focusing window %d", matches[0].ID)
+	if err := src.activate(ctx, matches[0], warp); err != nil {
+		log.Fatalln("Error focusing window:", err)
 	}
 	return true
 }
 
 // launchAndFocus launches an app using the given command and then focuses the
-// window.
-func launchAndFocus(ctx context.Context, client sway.Client, command string, args ...string) {
-	root, err := client.GetTree(ctx)
-	if err != nil {
-		log.Fatalln("GET_TREE failed:", err)
-	}
+// window (warping the pointer to it if warp is set).
+func launchAndFocus(ctx context.Context, src windowSource, warp bool, command string, args ...string) {
 	oldIDs := make(map[int64]struct{})
-	walkTree(root, func(n *sway.Node) {
-		oldIDs[n.ID] = struct{}{}
-	})
-	getNewID := func() int64 {
-		root, err := client.GetTree(ctx)
-		if err != nil {
-			log.Fatalln("GET_TREE failed:", err)
-		}
-		newID := int64(-1)
-		walkTree(root, func(n *sway.Node) {
-			if _, ok := oldIDs[n.ID]; !ok {
-				if newID == -1 || n.ID < newID {
-					newID = n.ID
+	for _, w := range listWindows(ctx, src) {
+		oldIDs[w.ID] = struct{}{}
+	}
+	getNew := func() *windowInfo {
+		var newWindow *windowInfo
+		for _, w := range listWindows(ctx, src) {
+			if _, ok := oldIDs[w.ID]; !ok {
+				if newWindow == nil || w.ID < newWindow.ID {
+					w := w
+					newWindow = &w
 				}
 			}
-		})
-		return newID
+		}
+		return newWindow
 	}
 	launch(command, args...)
+	if dryRun {
+		// No window will appear.
+		return
+	}
 	ticker := time.NewTicker(50 * time.Millisecond)
 	defer ticker.Stop()
 	start := time.Now()
@@ -262,20 +418,23 @@ func launchAndFocus(ctx context.Context, client sway.Client, command string, arg
 		if time.Since(start) > 1200*time.Millisecond {
 			log.Fatalln("Application couldn't be focused after launch")
 		}
-		newID := getNewID()
-		if newID < 0 {
+		w := getNew()
+		if w == nil {
 			continue
 		}
-		log.Printf("Focusing con_id %d", newID)
-		command := fmt.Sprintf("[con_id=%d] focus", newID)
-		if err := runCommand(ctx, client, command); err != nil {
-			log.Fatalf("Error running command %q: %s", command, err)
+		log.Printf("Focusing window %d", w.ID)
+		if err := src.activate(ctx, *w, warp); err != nil {
+			log.Fatalln("Error focusing window:", err)
 		}
 		return
 	}
 }
 
 func launch(command string, args ...string) {
+	if dryRun {
+		fmt.Printf("exec %q\n", append([]string{command}, args...))
+		return
+	}
 	cmd := exec.Command(command, args...)
 	cmd.SysProcAttr = &unix.SysProcAttr{Setpgid: true}
 	if err := cmd.Start(); err != nil {
@@ -336,13 +495,15 @@ the relevant information for scripting but is much less verbose.
 
 func cmdAppNext(args []string) {
 	fs := flag.NewFlagSet("appnext", flag.ExitOnError)
+	warp := fs.Bool("warp", false, "Move the pointer to the center of the focused window")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage:
 
-  swayctrl appnext
+  swayctrl appnext [-warp]
 
 The appnext command focuses the next instance of the focused application (if
-another one exists).
+another one exists). If -warp is given, the pointer is moved to the center of
+the newly focused window.
 `)
 	}
 	fs.Parse(args)
@@ -371,7 +532,7 @@ another one exists).
 		log.Fatal("Inconsistent tree?")
 	}
 	j := (i + 1) % len(matches)
-	command := fmt.Sprintf("[con_id=%d] focus", matches[j].ID)
+	command := focusCommand(matches[j], *warp)
 	if err := runCommand(ctx, client, command); err != nil {
 		log.Fatalf("Error running command %q: %s", command, err)
 	}
@@ -379,35 +540,42 @@ another one exists).
 
 func cmdPrev(args []string) {
 	fs := flag.NewFlagSet("prev", flag.ExitOnError)
+	warp := fs.Bool("warp", false, "Move the pointer to the center of the focused window")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage:
 
-  swayctrl prev
+  swayctrl prev [-warp]
 
 The prev command focuses the previously focused window. The daemon must be running.
+If -warp is given, the pointer is moved to the center of the newly focused window.
 `)
 	}
 	fs.Parse(args)
 
+	if *warp {
+		requireSway("-warp")
+	}
+
 	mruList := getMRUListFromDaemon()
 
 	ctx := context.Background()
-	client := newClient(ctx)
-	root, err := client.GetTree(ctx)
-	if err != nil {
-		log.Fatalln("GET_TREE failed:", err)
-	}
-	focused := root.FocusedNode()
-	if focused == nil {
-		log.Fatal("No focused node")
-	}
-	for _, w := range mruList {
-		if w.ID == focused.ID {
+	src := newWindowSource(ctx)
+	windows := listWindows(ctx, src)
+	focusedID := int64(-1)
+	if w := focusedWindow(windows); w != nil {
+		focusedID = w.ID
+	}
+	byID := make(map[int64]windowInfo)
+	for _, w := range windows {
+		byID[w.ID] = w
+	}
+	for _, m := range mruList {
+		w, ok := byID[m.ID]
+		if !ok || w.ID == focusedID {
 			continue
 		}
-		command := fmt.Sprintf("[con_id=%d] focus", w.ID)
-		if err := runCommand(ctx, client, command); err != nil {
-			log.Fatalf("Error running command %q: %s", command, err)
+		if err := src.activate(ctx, w, *warp); err != nil {
+			log.Fatalln("Error focusing window:", err)
 		}
 		return
 	}
@@ -415,95 +583,203 @@ The prev command focuses the previously focused window. The daemon must be runni
 }
 
 func getMRUListFromDaemon() []listWindow {
-	sockDir := os.Getenv("XDG_RUNTIME_DIR")
-	if sockDir == "" {
-		log.Fatalln("XDG_RUNTIME_DIR must be defined (to place socket file)")
-	}
-	sockPath := filepath.Join(sockDir, "swayctrl.sock")
-	hc := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				// Don't bother sending the addr through the URL.
-				return net.Dial("unix", sockPath)
-			},
-		},
-	}
-	resp, err := hc.Get("http://localhost/") // fake
-	if err != nil {
-		log.Fatalln("Error querying local daemon (is it running?):", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		log.Fatalf("Got a non-200 status code (%d) from daemon", resp.StatusCode)
-	}
 	var mruList []listWindow
-	if err := json.NewDecoder(resp.Body).Decode(&mruList); err != nil {
-		log.Fatalln("Error reading most recently used window list from daemon:", err)
-	}
+	callDaemon("mru", nil, &mruList)
 	return mruList
 }
 
 func cmdFocusTitle(args []string) {
-	fs := flag.NewFlagSet("title", flag.ExitOnError)
+	fs := flag.NewFlagSet("focustitle", flag.ExitOnError)
+	format := fs.String("format", defaultTitleFormat, "Output format (a Go template)")
+	waybar := fs.Bool("waybar", false, "Print JSON for a waybar custom module")
+	maxLength := fs.Int("max-length", 0, "Truncate titles to this many characters (0 means no limit)")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage:
 
-  swayctrl title
+  swayctrl focustitle [-format template] [-waybar] [-max-length n]
+
+The focustitle command prints the title of the currently focused window, and
+then prints it again each time the focus or the title changes. When no window is
+focused (for instance, after switching to an empty workspace), it prints an
+empty title.
+
+The -format flag is a Go text/template that controls what is printed. The
+available fields are:
+
+  .Title      the window title
+  .AppID      the app ID (or X11 class, for Xwayland windows)
+  .Workspace  the name of the window's workspace
+  .Output     the name of the window's output
+  .Shell      xdg_shell or xwayland
+  .Floating   whether the window is floating
 
-The title command prints the title of the currently focused window.
+For example, -format '{{.AppID}} — {{.Title}} [{{.Workspace}}]'.
+
+If -waybar is given, each line is a JSON object suitable for a waybar custom
+module with "return-type": "json": the text is the (escaped) formatted title,
+the tooltip is the same without truncation, and the class is the app ID.
+
+If -max-length is given, longer titles are truncated (with an ellipsis).
 `)
 	}
 	fs.Parse(args)
 
+	tmpl, err := template.New("format").Parse(*format)
+	if err != nil {
+		log.Fatalln("Bad -format template:", err)
+	}
+
 	ctx := context.Background()
 	client := newClient(ctx)
+	p := &titlePrinter{
+		client:    client,
+		tmpl:      tmpl,
+		waybar:    *waybar,
+		maxLength: *maxLength,
+	}
+
+	// The initial title is printed upon receiving the first tick event,
+	// which sway sends as soon as the subscription is established. This way
+	// we can't miss a focus change between reading the tree and subscribing.
+	handler := newFocusHandler(p, client)
+	events := []sway.EventType{sway.EventTypeWindow, sway.EventTypeWorkspace, sway.EventTypeTick}
+	if err := sway.Subscribe(ctx, handler, events...); err != nil {
+		log.Fatalln("Error with subscription:", err)
+	}
+}
 
-	root, err := client.GetTree(ctx)
+const defaultTitleFormat = `{{if and .Shell (ne .Shell "xdg_shell")}}[{{.Shell}}] {{end}}{{.Title}}`
+
+type titlePrinter struct {
+	client    sway.Client
+	tmpl      *template.Template
+	waybar    bool
+	maxLength int
+}
+
+// titleInfo is the data available to focustitle -format templates.
+type titleInfo struct {
+	Title     string
+	AppID     string
+	Workspace string
+	Output    string
+	Shell     string
+	Floating  bool
+}
+
+func (p *titlePrinter) info(ctx context.Context, n *sway.Node) *titleInfo {
+	info := &titleInfo{
+		Title:    n.Name,
+		AppID:    nodeAppID(n),
+		Floating: n.Type == sway.NodeFloatingCon,
+	}
+	if n.Shell != nil {
+		info.Shell = *n.Shell
+	}
+	// Events don't say where the window is, so look in the tree.
+	root, err := p.client.GetTree(ctx)
 	if err != nil {
 		log.Fatalln("GET_TREE failed:", err)
 	}
-	focused := root.FocusedNode()
-	if focused == nil {
-		log.Fatal("No focused node")
+	if ws := findAncestor(root, n.ID, sway.NodeWorkspace); ws != nil {
+		info.Workspace = ws.Name
 	}
-	printTitle(focused)
-
-	// TODO: there's a race here where we could miss a focus event.
-
-	handler := newFocusHandler()
-	if err := sway.Subscribe(ctx, handler, sway.EventTypeWindow); err != nil {
-		log.Fatalln("Error with subscription:", err)
+	if o := findAncestor(root, n.ID, sway.NodeOutput); o != nil {
+		info.Output = o.Name
 	}
+	return info
 }
 
-func printTitle(n *sway.Node) {
-	if n.Shell != nil && *n.Shell != "xdg_shell" {
-		fmt.Printf("[%s] %s\n", *n.Shell, n.Name)
+// print prints the formatted title of n or, if n is nil, an empty title.
+func (p *titlePrinter) print(ctx context.Context, n *sway.Node) {
+	var title, appID string
+	if n != nil {
+		info := p.info(ctx, n)
+		var b strings.Builder
+		if err := p.tmpl.Execute(&b, info); err != nil {
+			log.Fatalln("Error executing -format template:", err)
+		}
+		title = b.String()
+		appID = info.AppID
+	}
+	text := title
+	if p.maxLength > 0 {
+		if r := []rune(text); len(r) > p.maxLength {
+			text = string(r[:p.maxLength]) + "…"
+		}
+	}
+	if !p.waybar {
+		fmt.Println(text)
 		return
 	}
-	fmt.Println(n.Name)
+	// Waybar interprets the text and tooltip as Pango markup.
+	b, err := json.Marshal(struct {
+		Text    string `json:"text"`
+		Tooltip string `json:"tooltip"`
+		Class   string `json:"class"`
+	}{
+		Text:    html.EscapeString(text),
+		Tooltip: html.EscapeString(title),
+		Class:   appID,
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
 }
 
 type focusHandler struct {
+	p      *titlePrinter
+	client sway.Client
 	sway.EventHandler
 }
 
-func newFocusHandler() *focusHandler {
+func newFocusHandler(p *titlePrinter, client sway.Client) *focusHandler {
 	h := &focusHandler{
 		EventHandler: sway.NoOpEventHandler(),
+		p:            p,
+		client:       client,
 	}
 	return h
 }
 
+func (h *focusHandler) Tick(ctx context.Context, e sway.TickEvent) {
+	if !e.First {
+		return
+	}
+	root, err := h.client.GetTree(ctx)
+	if err != nil {
+		log.Fatalln("GET_TREE failed:", err)
+	}
+	focused := root.FocusedNode()
+	if focused == nil {
+		log.Fatal("No focused node")
+	}
+	if focused.Type == sway.NodeWorkspace {
+		focused = nil
+	}
+	h.p.print(ctx, focused)
+}
+
+func (h *focusHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
+	// Switching to an empty workspace doesn't generate a window event.
+	if e.Change != sway.WorkspaceFocus || e.Current == nil {
+		return
+	}
+	if len(e.Current.Nodes) == 0 && len(e.Current.FloatingNodes) == 0 {
+		h.p.print(ctx, nil)
+	}
+}
+
 func (h *focusHandler) Window(ctx context.Context, e sway.WindowEvent) {
 	switch e.Change {
 	case sway.WindowFocus, sway.WindowTitle:
 		if e.Container.Focused {
-			printTitle(&e.Container)
+			h.p.print(ctx, &e.Container)
 		}
 	case sway.WindowClose:
 		if e.Container.Focused {
-			fmt.Println()
+			h.p.print(ctx, nil)
 		}
 	}
 }
@@ -547,127 +823,307 @@ environment variable.
 func cmdDaemon(args []string) {
 	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
 	verbose := fs.Bool("v", false, "Verbose mode")
+	mruSize := fs.Int("mru-size", 500, "Maximum number of windows in the focus history")
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage:
 
-  swayctrl daemon [-v]
+  swayctrl daemon [-v] [-mru-size n]
 
 The daemon command starts a long-running process that subscribes to sway IPC
 events and tracks window focus history. This is necessary for the 'prev' command.
 
+If swallow_parents is set in the config file to a list of app IDs (usually
+terminals), the daemon implements "swallowing": when a program started from one
+of those windows opens a window, the new window takes the place of the parent,
+which is hidden in the scratchpad until the new window closes.
+
+The daemon moves windows marked by 'swayctrl follow' to each workspace that
+gets focused.
+
+The daemon keeps track of the active workspace group for the 'group' commands.
+
+The daemon also remembers the labels of numbered workspaces (such as "3:mail")
+and restores the label if sway destroys and later recreates the workspace.
+
+If output profiles are configured (see 'swayctrl output profile -h'), the daemon
+applies the matching profile whenever the set of connected outputs changes.
+
+The daemon sends desktop notifications (using notify-send) according to the
+notify rules in the config file. For example:
+
+  "notify": [
+    {"event": "urgent"},
+    {"event": "new", "app_id": "steam", "min_startup_secs": 10}
+  ]
+
+An "urgent" rule fires when a window that isn't visible becomes urgent. A "new"
+rule fires when a window is opened; with min_startup_secs, only if that happens
+at least that long after its process started. Rules may be restricted to a
+particular app_id. The first matching rule wins.
+
+Title triggers fire when a window's title changes to match a regular expression
+that its previous title didn't match. A trigger can make the window urgent or
+run a command (with SWAYCTRL_CON_ID, SWAYCTRL_APP_ID, and SWAYCTRL_TITLE set
+in its environment). For example:
+
+  "title_triggers": [
+    {"app_id": "foot", "match": "\\[bell\\]", "exec": "notify-send 'Build done'"},
+    {"match": "Meet", "urgent": true}
+  ]
+
+Other commands talk to the daemon using JSON-RPC 2.0 (one request or response
+per line) over a unix socket. Besides querying the focus
+history, clients can reload the config file (rules.reload), freeze the history
+while cycling through windows (cycle.begin and cycle.commit), and read or reset
+per-app focus time statistics (stats, stats.spans, and stats.reset), get the current
+binding mode (mode), and list and switch workspace groups (group.list and
+group.switch).
+
+The socket is named by the global -socket flag or $SWAYCTRL_SOCK. By default,
+it is $XDG_RUNTIME_DIR/swayctrl.$UID.$PID.sock, after the sway socket
+(sway-ipc.$UID.$PID.sock), so that nested or separate sway sessions can each
+run their own daemon.
+
 The -v flag enables verbose mode where the daemon logs its actions.
+
+The -mru-size flag bounds the focus history; when it is full, the least
+recently focused window is forgotten.
+
+With the toplevel backend (see 'swayctrl -h'), the daemon only tracks the focus
+history and serves the windows for focus, prev, and launch; the features above
+that act on sway (swallowing, following, workspace groups and labels, output
+profiles, notifications, and title triggers) require sway.
 `)
 	}
 	fs.Parse(args)
 
-	ctx := context.Background()
-	sockDir := os.Getenv("XDG_RUNTIME_DIR")
-	if sockDir == "" {
-		log.Fatalln("XDG_RUNTIME_DIR must be defined (to place socket file)")
+	if *mruSize < 1 {
+		log.Fatalln("-mru-size must be positive")
 	}
-	lock := lockFile(filepath.Join(sockDir, "swayctrl.lock"))
+
+	ctx := context.Background()
+	sockPath := daemonSockPath()
+	lock := lockFile(strings.TrimSuffix(sockPath, ".sock") + ".lock")
 	defer lock.unlock()
-	handler := newDaemonHandler(*verbose)
-	handler.listen(filepath.Join(sockDir, "swayctrl.sock"))
-	if err := sway.Subscribe(ctx, handler, sway.EventTypeWindow); err != nil {
+	if backend == backendToplevel {
+		m, err := dialToplevels()
+		if err != nil {
+			log.Fatalln("Error connecting to the Wayland compositor:", err)
+		}
+		handler := newDaemonHandler(nil, loadConfig(), *verbose, *mruSize)
+		handler.src = m
+		handler.listen(sockPath)
+		log.Fatalln("Error reading toplevel events:", m.run(handler.trackWindow))
+	}
+	handler := newDaemonHandler(newClient(ctx), loadConfig(), *verbose, *mruSize)
+	handler.listen(sockPath)
+	go handler.watchOutputs(ctx)
+	events := []sway.EventType{sway.EventTypeWindow, sway.EventTypeWorkspace, sway.EventTypeMode}
+	if err := sway.Subscribe(ctx, handler, events...); err != nil {
 		log.Fatalln("Error with subscription:", err)
 	}
 }
 
+// swayClient is the part of sway.Client that the daemon uses. Tests
+// substitute an in-memory fake.
+type swayClient interface {
+	GetTree(ctx context.Context) (*sway.Node, error)
+	GetWorkspaces(ctx context.Context) ([]sway.Workspace, error)
+	RunCommand(ctx context.Context, command string) ([]sway.RunCommandReply, error)
+}
+
+// dialSway opens a new (traced) sway connection.
+func dialSway(ctx context.Context) (swayClient, error) {
+	client, err := sway.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wrapClient(client), nil
+}
+
 type daemonHandler struct {
+	client swayClient
+	// dial opens the separate sway connections used by RPC methods.
+	dial func(context.Context) (swayClient, error)
+	// src is the toplevel manager with the toplevel backend; it is nil
+	// with sway.
+	src     windowSource
 	verbose bool
-	mu      sync.Mutex
-	list    windowMRUList
+
+	mu   sync.Mutex
+	cfg  *config
+	list *windowMRUList
+	// While cycling (between the cycle.begin and cycle.commit RPCs), focus
+	// changes don't reorder list; cycleFocus is the last focused window.
+	cycling    bool
+	cycleFocus *listWindow
+	stats      *focusTracker
+	mode       string // current binding mode
+	// wsLabels holds the labels of numbered workspaces, by number.
+	wsLabels map[int]string
+	// group is the active workspace group and groupFocused holds the
+	// most recently focused workspace of each group.
+	group        string
+	groupFocused map[string]string
+	// titles holds the last known title of each window.
+	titles map[int64]string
+
 	sway.EventHandler
 }
 
-func newDaemonHandler(verbose bool) *daemonHandler {
+func newDaemonHandler(client swayClient, cfg *config, verbose bool, mruSize int) *daemonHandler {
 	h := &daemonHandler{
 		EventHandler: sway.NoOpEventHandler(),
+		client:       client,
+		dial:         dialSway,
+		cfg:          cfg,
 		verbose:      verbose,
+		list:         newWindowMRUList(mruSize),
+		stats:        newFocusTracker(time.Now()),
+		mode:         "default",
+		wsLabels:     make(map[int]string),
+		group:        defaultGroup,
+		groupFocused: make(map[string]string),
+		titles:       make(map[int64]string),
 	}
-	h.list.m = make(map[int64]*mruElt)
 	return h
 }
 
-func (h *daemonHandler) listen(sockPath string) {
-	if err := os.RemoveAll(sockPath); err != nil {
-		log.Fatalln("Error creating socket file:", err)
-	}
-	l, err := net.Listen("unix", sockPath)
-	if err != nil {
-		log.Fatalln("Error listening with socket file:", err)
-	}
-	handle := func(w http.ResponseWriter, r *http.Request) {
-		h.mu.Lock()
-		nodes := h.list.all()
-		h.mu.Unlock()
-
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(nodes)
-	}
-	go func() {
-		log.Fatal("Serve error:", http.Serve(l, http.HandlerFunc(handle)))
-	}()
+func (h *daemonHandler) config() *config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cfg
 }
 
 func (h *daemonHandler) Window(ctx context.Context, e sway.WindowEvent) {
+	h.notify(ctx, e)
+	h.swallow(ctx, e)
+	h.titleTriggers(ctx, e)
+	h.trackWindow(e.Change, windowInfo{
+		ID:      e.Container.ID,
+		AppID:   nodeAppID(&e.Container),
+		Title:   e.Container.Name,
+		Focused: e.Container.Focused,
+	})
+}
+
+// trackWindow updates the focus history and statistics for a window event
+// from either backend.
+func (h *daemonHandler) trackWindow(change sway.WindowEventChange, w windowInfo) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	switch e.Change {
+	switch change {
 	case sway.WindowFocus:
-		appID := "?"
-		if e.Container.AppID != nil && *e.Container.AppID != "" {
-			appID = *e.Container.AppID
+		appID := w.AppID
+		if appID == "" {
+			appID = "?"
+		}
+		h.stats.focus(w.AppID, w.Title, time.Now())
+		if h.cycling {
+			h.cycleFocus = &listWindow{ID: w.ID, AppID: appID}
+			return
 		}
-		h.list.bringFront(e.Container.ID, appID)
+		h.list.bringFront(w.ID, appID)
 	case sway.WindowClose:
-		h.list.delete(e.Container.ID)
+		if w.Focused {
+			h.stats.focus("", "", time.Now())
+		}
+		if h.cycleFocus != nil && h.cycleFocus.ID == w.ID {
+			h.cycleFocus = nil
+		}
+		h.list.delete(w.ID)
+	case sway.WindowTitle:
+		// Start a new span so that reports can group by title.
+		if w.Focused {
+			h.stats.focus(w.AppID, w.Title, time.Now())
+		}
+		return
 	default:
 		return
 	}
 	if h.verbose {
-		log.Printf("Event[%s]: %v", e.Change, h.list.all())
+		log.Printf("Event[%s]: %v", change, h.list.all())
 	}
 }
 
-type windowMRUList struct {
-	head *mruElt
-	m    map[int64]*mruElt
+func (h *daemonHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
+	if e.Current == nil {
+		return
+	}
+	if e.Change == sway.WorkspaceFocus {
+		h.groupFocus(e.Current.Name)
+		h.moveFollowers(ctx, e.Current.Name)
+	}
+	h.restoreLabel(ctx, e)
 }
 
-type mruElt struct {
-	prev  *mruElt
-	next  *mruElt
-	id    int64
-	appID string
+func (h *daemonHandler) Mode(ctx context.Context, e sway.ModeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mode = e.Change
 }
 
-func (l *windowMRUList) bringFront(id int64, appID string) {
-	e, ok := l.m[id]
+func (h *daemonHandler) restoreLabel(ctx context.Context, e sway.WorkspaceEvent) {
+	num, label, ok := parseWorkspaceName(e.Current.Name)
 	if !ok {
-		e = &mruElt{id: id}
-		l.m[id] = e
-	}
-	e.appID = appID
-	if l.head == e {
 		return
 	}
-
-	if e.prev != nil {
-		e.prev.next = e.next
-	}
-	if e.next != nil {
-		e.next.prev = e.prev
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch e.Change {
+	case sway.WorkspaceRename:
+		if label == "" {
+			delete(h.wsLabels, num)
+		} else {
+			h.wsLabels[num] = label
+		}
+		if h.verbose {
+			log.Printf("Workspace %d label: %q", num, label)
+		}
+	case sway.WorkspaceInit:
+		want, ok := h.wsLabels[num]
+		if !ok || want == label {
+			return
+		}
+		command := fmt.Sprintf("rename workspace %q to %q", e.Current.Name, workspaceName(num, want))
+		if h.verbose {
+			log.Printf("Restoring workspace label: %s", command)
+		}
+		if err := runCommand(ctx, h.client, command); err != nil {
+			log.Printf("Error running command %q: %s", command, err)
+		}
 	}
+}
+
+// windowMRUList tracks windows in order from most to least recently focused.
+// It holds at most max windows; past that, the least recently focused window
+// is evicted.
+type windowMRUList struct {
+	max int
+	l   list.List // of listWindow
+	m   map[int64]*list.Element
+}
 
-	e.prev = nil
-	e.next = l.head
-	if e.next != nil {
-		e.next.prev = e
+func newWindowMRUList(max int) *windowMRUList {
+	return &windowMRUList{
+		max: max,
+		m:   make(map[int64]*list.Element),
 	}
+}
 
-	l.head = e
+func (l *windowMRUList) bringFront(id int64, appID string) {
+	w := listWindow{ID: id, AppID: appID}
+	if e, ok := l.m[id]; ok {
+		e.Value = w
+		l.l.MoveToFront(e)
+		return
+	}
+	l.m[id] = l.l.PushFront(w)
+	for l.l.Len() > l.max {
+		e := l.l.Back()
+		delete(l.m, e.Value.(listWindow).ID)
+		l.l.Remove(e)
+	}
 }
 
 func (l *windowMRUList) delete(id int64) {
@@ -676,30 +1132,15 @@ func (l *windowMRUList) delete(id int64) {
 		return
 	}
 	delete(l.m, id)
-	if e.prev != nil {
-		e.prev.next = e.next
-	}
-	if e.next != nil {
-		e.next.prev = e.prev
-	}
-	if l.head == e {
-		l.head = e.next
-	}
+	l.l.Remove(e)
 }
 
 func (l *windowMRUList) all() []listWindow {
-	var nodes []listWindow
-	var i int
-	for e := l.head; e != nil; e = e.next {
-		i++
-		// FIXME: delete
-		if i > 100 {
-			l.debug()
-			panic("boom")
-		}
-		nodes = append(nodes, listWindow{e.id, e.appID})
+	windows := make([]listWindow, 0, l.l.Len())
+	for e := l.l.Front(); e != nil; e = e.Next() {
+		windows = append(windows, e.Value.(listWindow))
 	}
-	return nodes
+	return windows
 }
 
 type listWindow struct {
@@ -711,19 +1152,15 @@ func (s listWindow) String() string {
 	return fmt.Sprintf("%d:%s", s.ID, s.AppID)
 }
 
-func (l *windowMRUList) debug() {
-	for id, e := range l.m {
-		fmt.Printf("%d -> %p\n", id, e)
+// nodeAppID returns the app ID of n or, for Xwayland windows, the X11 class.
+func nodeAppID(n *sway.Node) string {
+	if n.AppID != nil && *n.AppID != "" {
+		return *n.AppID
 	}
-	var i int
-	for e := l.head; e != nil; e = e.next {
-		i++
-		if i == 30 {
-			fmt.Printf("quitting after 30")
-			break
-		}
-		fmt.Printf("[%d] prev=%p next=%p\n", e.id, e.prev, e.next)
+	if n.WindowProperties != nil {
+		return n.WindowProperties.Class
 	}
+	return ""
 }
 
 func treeSelect(node *sway.Node, fn func(*sway.Node) bool) []*sway.Node {
@@ -746,7 +1183,7 @@ func walkTree(node *sway.Node, fn func(*sway.Node)) {
 	}
 }
 
-func runCommand(ctx context.Context, client sway.Client, command string) error {
+func runCommand(ctx context.Context, client swayClient, command string) error {
 	results, err := client.RunCommand(ctx, command)
 	if err != nil {
 		return err
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cespare/utils/internal/wl"
+	"github.com/joshuarubin/go-sway"
+)
+
+// This file implements the toplevel backend (-backend toplevel) using the
+// wlr-foreign-toplevel-management protocol, which wlroots-based compositors
+// other than sway (river, Hyprland, labwc, ...) support. The protocol gives a
+// flat list of toplevels (title, app ID, and state) and a request to
+// activate one; there are no workspaces or geometry.
+
+const (
+	wlSeatInterface          = "wl_seat"
+	toplevelManagerInterface = "zwlr_foreign_toplevel_manager_v1"
+)
+
+// Values in a zwlr_foreign_toplevel_handle_v1.state array.
+const (
+	toplevelMinimized = 1
+	toplevelActivated = 2
+)
+
+// A toplevelState is the double-buffered state of a toplevel: changes are
+// collected until the handle's done event.
+type toplevelState struct {
+	title     string
+	appID     string
+	activated bool
+	minimized bool
+}
+
+// A toplevel is a zwlr_foreign_toplevel_handle_v1.
+type toplevel struct {
+	// id identifies the toplevel to clients of the daemon. Handle object
+	// IDs may be reused after a toplevel closes, so these are assigned
+	// separately.
+	id      int64
+	handle  uint32
+	cur     toplevelState
+	pending toplevelState
+	ready   bool // whether the first done event has arrived
+}
+
+// A toplevelChange is a focus, title, or close of a toplevel.
+type toplevelChange struct {
+	change sway.WindowEventChange
+	w      windowInfo
+	handle uint32
+}
+
+// A toplevelManager tracks the compositor's toplevels. It is the daemon's
+// windowSource for the toplevel backend.
+type toplevelManager struct {
+	c       *wl.Conn
+	manager uint32
+	seat    uint32
+
+	mu        sync.Mutex
+	toplevels map[uint32]*toplevel // by handle
+	lastID    int64
+}
+
+func newToplevelManager() *toplevelManager {
+	return &toplevelManager{toplevels: make(map[uint32]*toplevel)}
+}
+
+// dialToplevels connects to the compositor and binds the toplevel manager
+// and a seat. The toplevels arrive once run is called.
+func dialToplevels() (*toplevelManager, error) {
+	c, err := wl.Dial()
+	if err != nil {
+		return nil, err
+	}
+	m := newToplevelManager()
+	m.c = c
+	registry, err := c.GetRegistry()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	done, err := c.Sync()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	for {
+		e, err := c.ReadEvent()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		if e.Object == done {
+			break
+		}
+		if e.Object != registry || e.Opcode != 0 {
+			continue
+		}
+		// wl_registry.global(name, interface, version)
+		r := wl.Reader(e.Args)
+		name, iface, version := r.ReadUint(), r.ReadString(), r.ReadUint()
+		switch {
+		case iface == toplevelManagerInterface && m.manager == 0:
+			if version > 3 {
+				version = 3
+			}
+			m.manager, err = c.Bind(registry, name, iface, version)
+		case iface == wlSeatInterface && m.seat == 0:
+			m.seat, err = c.Bind(registry, name, iface, 1)
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if m.manager == 0 {
+		c.Close()
+		return nil, errors.New("the compositor doesn't support wlr-foreign-toplevel-management")
+	}
+	return m, nil
+}
+
+// run reads events from the compositor, calling fn with each change to the
+// toplevels, until there is an error.
+func (m *toplevelManager) run(fn func(change sway.WindowEventChange, w windowInfo)) error {
+	for {
+		e, err := m.c.ReadEvent()
+		if err != nil {
+			return err
+		}
+		changes, err := m.handleEvent(e)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if c.change == sway.WindowClose {
+				// zwlr_foreign_toplevel_handle_v1.destroy
+				if err := m.c.Request(c.handle, 7, nil); err != nil {
+					return err
+				}
+			}
+			fn(c.change, c.w)
+		}
+	}
+}
+
+// handleEvent updates the toplevels for an event from the compositor and
+// returns the resulting changes.
+func (m *toplevelManager) handleEvent(e wl.Event) ([]toplevelChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := wl.Reader(e.Args)
+	if e.Object == m.manager {
+		switch e.Opcode {
+		case 0: // toplevel(new_id)
+			handle := r.ReadUint()
+			m.lastID++
+			m.toplevels[handle] = &toplevel{id: m.lastID, handle: handle}
+		case 1: // finished
+			return nil, errors.New("the compositor stopped sending toplevel events")
+		}
+		return nil, nil
+	}
+	t, ok := m.toplevels[e.Object]
+	if !ok {
+		return nil, nil
+	}
+	switch e.Opcode {
+	case 0: // title
+		t.pending.title = r.ReadString()
+	case 1: // app_id
+		t.pending.appID = r.ReadString()
+	case 4: // state
+		t.pending.activated = false
+		t.pending.minimized = false
+		for _, s := range r.ReadUintArray() {
+			switch s {
+			case toplevelActivated:
+				t.pending.activated = true
+			case toplevelMinimized:
+				t.pending.minimized = true
+			}
+		}
+	case 5: // done
+		old, first := t.cur, !t.ready
+		t.cur = t.pending
+		t.ready = true
+		var changes []toplevelChange
+		if first {
+			changes = append(changes, toplevelChange{change: sway.WindowNew, w: t.info(), handle: t.handle})
+		} else if t.cur.title != old.title {
+			changes = append(changes, toplevelChange{change: sway.WindowTitle, w: t.info(), handle: t.handle})
+		}
+		if t.cur.activated && (first || !old.activated) {
+			changes = append(changes, toplevelChange{change: sway.WindowFocus, w: t.info(), handle: t.handle})
+		}
+		return changes, nil
+	case 6: // closed
+		delete(m.toplevels, e.Object)
+		if !t.ready {
+			return nil, nil
+		}
+		return []toplevelChange{{change: sway.WindowClose, w: t.info(), handle: t.handle}}, nil
+	}
+	return nil, nil
+}
+
+func (t *toplevel) info() windowInfo {
+	return windowInfo{
+		ID:      t.id,
+		AppID:   t.cur.appID,
+		Title:   t.cur.title,
+		Focused: t.cur.activated,
+		Visible: !t.cur.minimized,
+	}
+}
+
+// windows lists the toplevels in the order they appeared.
+func (m *toplevelManager) windows(ctx context.Context) ([]windowInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	windows := []windowInfo{}
+	for _, t := range m.toplevels {
+		if t.ready {
+			windows = append(windows, t.info())
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].ID < windows[j].ID })
+	return windows, nil
+}
+
+// activate unminimizes (if necessary) and activates w.
+func (m *toplevelManager) activate(ctx context.Context, w windowInfo, warp bool) error {
+	if warp {
+		return errors.New("the toplevel backend cannot move the pointer")
+	}
+	if m.seat == 0 {
+		return errors.New("the compositor has no seat")
+	}
+	m.mu.Lock()
+	var t *toplevel
+	for _, t1 := range m.toplevels {
+		if t1.id == w.ID && t1.ready {
+			t = t1
+			break
+		}
+	}
+	var handle uint32
+	var minimized bool
+	if t != nil {
+		handle, minimized = t.handle, t.cur.minimized
+	}
+	m.mu.Unlock()
+	if t == nil {
+		return fmt.Errorf("no window with ID %d", w.ID)
+	}
+	if minimized {
+		// unset_minimized
+		if err := m.c.Request(handle, 3, nil); err != nil {
+			return err
+		}
+	}
+	// activate(seat)
+	var args wl.Message
+	args.PutUint(m.seat)
+	return m.c.Request(handle, 4, args)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cespare/utils/internal/wl"
+	"github.com/joshuarubin/go-sway"
+	"golang.org/x/exp/slices"
+)
+
+const testManagerID = 3
+
+func toplevelEvent(object uint32, opcode uint16, build func(m *wl.Message)) wl.Event {
+	var m wl.Message
+	if build != nil {
+		build(&m)
+	}
+	return wl.Event{Object: object, Opcode: opcode, Args: m}
+}
+
+func newToplevel(handle uint32) wl.Event {
+	return toplevelEvent(testManagerID, 0, func(m *wl.Message) { m.PutUint(handle) })
+}
+
+func toplevelTitle(handle uint32, title string) wl.Event {
+	return toplevelEvent(handle, 0, func(m *wl.Message) { m.PutString(title) })
+}
+
+func toplevelAppID(handle uint32, appID string) wl.Event {
+	return toplevelEvent(handle, 1, func(m *wl.Message) { m.PutString(appID) })
+}
+
+func toplevelStates(handle uint32, states ...uint32) wl.Event {
+	return toplevelEvent(handle, 4, func(m *wl.Message) {
+		m.PutUint(uint32(4 * len(states)))
+		for _, s := range states {
+			m.PutUint(s)
+		}
+	})
+}
+
+func toplevelDone(handle uint32) wl.Event   { return toplevelEvent(handle, 5, nil) }
+func toplevelClosed(handle uint32) wl.Event { return toplevelEvent(handle, 6, nil) }
+
+func TestToplevelManager(t *testing.T) {
+	m := newToplevelManager()
+	m.manager = testManagerID
+	type change struct {
+		change sway.WindowEventChange
+		id     int64
+		title  string
+	}
+	send := func(events ...wl.Event) []change {
+		t.Helper()
+		var changes []change
+		for _, e := range events {
+			cs, err := m.handleEvent(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range cs {
+				changes = append(changes, change{c.change, c.w.ID, c.w.Title})
+			}
+		}
+		return changes
+	}
+	check := func(name string, got, want []change) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: got changes %v; want %v", name, got, want)
+		}
+	}
+
+	// Handles appear with their initial state, then done.
+	got := send(
+		newToplevel(0xff000000),
+		toplevelTitle(0xff000000, "~"),
+		toplevelAppID(0xff000000, "foot"),
+		toplevelStates(0xff000000),
+		newToplevel(0xff000001),
+		toplevelTitle(0xff000001, "Firefox"),
+		toplevelAppID(0xff000001, "firefox"),
+		toplevelStates(0xff000001, toplevelActivated),
+	)
+	check("before done", got, nil)
+	got = send(toplevelDone(0xff000000), toplevelDone(0xff000001))
+	check("initial", got, []change{
+		{sway.WindowNew, 1, "~"},
+		{sway.WindowNew, 2, "Firefox"},
+		{sway.WindowFocus, 2, "Firefox"},
+	})
+
+	// Focus moves.
+	got = send(
+		toplevelStates(0xff000001),
+		toplevelDone(0xff000001),
+		toplevelStates(0xff000000, toplevelActivated),
+		toplevelDone(0xff000000),
+	)
+	check("focus", got, []change{{sway.WindowFocus, 1, "~"}})
+
+	// Title changes; states resent without a change aren't a focus.
+	got = send(
+		toplevelTitle(0xff000000, "vim"),
+		toplevelStates(0xff000000, toplevelActivated),
+		toplevelDone(0xff000000),
+	)
+	check("title", got, []change{{sway.WindowTitle, 1, "vim"}})
+
+	// Minimized windows aren't visible.
+	send(toplevelStates(0xff000001, toplevelMinimized), toplevelDone(0xff000001))
+	windows, err := m.windows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWindows := []windowInfo{
+		{ID: 1, AppID: "foot", Title: "vim", Focused: true, Visible: true},
+		{ID: 2, AppID: "firefox", Title: "Firefox"},
+	}
+	if !slices.Equal(windows, wantWindows) {
+		t.Errorf("got windows %+v; want %+v", windows, wantWindows)
+	}
+
+	// A closed handle's object ID may be reused for a new toplevel, which
+	// gets a new window ID.
+	got = send(
+		toplevelClosed(0xff000001),
+		newToplevel(0xff000001),
+		toplevelTitle(0xff000001, "mpv"),
+		toplevelStates(0xff000001),
+		toplevelDone(0xff000001),
+	)
+	check("reuse", got, []change{
+		{sway.WindowClose, 2, "Firefox"},
+		{sway.WindowNew, 3, "mpv"},
+	})
+
+	// The manager finishing is an error.
+	if _, err := m.handleEvent(toplevelEvent(testManagerID, 1, nil)); err == nil {
+		t.Error("finished: got nil error")
+	}
+}
+
+// TestDaemonToplevelHistory checks that toplevel changes drive the daemon's
+// focus history like sway window events do.
+func TestDaemonToplevelHistory(t *testing.T) {
+	m := newToplevelManager()
+	m.manager = testManagerID
+	h := newTestDaemon(newFakeSway(testTree()), 10)
+	h.src = m
+	for _, e := range []wl.Event{
+		newToplevel(0xff000000),
+		toplevelAppID(0xff000000, "foot"),
+		toplevelStates(0xff000000, toplevelActivated),
+		toplevelDone(0xff000000),
+		newToplevel(0xff000001),
+		toplevelAppID(0xff000001, "firefox"),
+		toplevelStates(0xff000001, toplevelActivated),
+		toplevelDone(0xff000001),
+		newToplevel(0xff000002),
+		toplevelStates(0xff000002, toplevelActivated),
+		toplevelDone(0xff000002),
+		toplevelClosed(0xff000001),
+	} {
+		changes, err := m.handleEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			h.trackWindow(c.change, c.w)
+		}
+	}
+	var mru []listWindow
+	if _, err := callRPC(h, "mru", nil, &mru); err != nil {
+		t.Fatal(err)
+	}
+	want := []listWindow{{3, "?"}, {1, "foot"}}
+	if !slices.Equal(mru, want) {
+		t.Errorf("got MRU %v; want %v", mru, want)
+	}
+
+	// The windows method lists the toplevels, not the sway tree.
+	var windows []windowInfo
+	if _, err := callRPC(h, "windows", nil, &windows); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, w := range windows {
+		ids = append(ids, w.ID)
+	}
+	if want := []int64{1, 3}; !slices.Equal(ids, want) {
+		t.Errorf("got window IDs %v; want %v", ids, want)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// These are set by the global -n and -trace flags.
+var (
+	// dryRun makes swayctrl print the sway commands it would run rather
+	// than running them. Queries are still made.
+	dryRun bool
+	// traceIPC makes swayctrl log each IPC round trip and its duration.
+	traceIPC bool
+)
+
+// wrapClient applies the -n and -trace flags to client.
+func wrapClient(client sway.Client) sway.Client {
+	if !dryRun && !traceIPC {
+		return client
+	}
+	return tracingClient{client}
+}
+
+// traceCall calls fn, logging the call and its duration if -trace is set.
+func traceCall[T any](name string, fn func() (T, error)) (T, error) {
+	if !traceIPC {
+		return fn()
+	}
+	start := time.Now()
+	v, err := fn()
+	elapsed := time.Since(start).Round(time.Microsecond)
+	if err != nil {
+		log.Printf("IPC %s: error after %s: %s", name, elapsed, err)
+	} else {
+		log.Printf("IPC %s: %s", name, elapsed)
+	}
+	return v, err
+}
+
+// tracingClient is a sway.Client that implements -n and -trace.
+type tracingClient struct {
+	sway.Client
+}
+
+func (c tracingClient) RunCommand(ctx context.Context, command string) ([]sway.RunCommandReply, error) {
+	if dryRun {
+		fmt.Println(command)
+		return []sway.RunCommandReply{{Success: true}}, nil
+	}
+	return traceCall(fmt.Sprintf("RUN_COMMAND %q", command), func() ([]sway.RunCommandReply, error) {
+		return c.Client.RunCommand(ctx, command)
+	})
+}
+
+func (c tracingClient) GetWorkspaces(ctx context.Context) ([]sway.Workspace, error) {
+	return traceCall("GET_WORKSPACES", func() ([]sway.Workspace, error) { return c.Client.GetWorkspaces(ctx) })
+}
+
+func (c tracingClient) GetOutputs(ctx context.Context) ([]sway.Output, error) {
+	return traceCall("GET_OUTPUTS", func() ([]sway.Output, error) { return c.Client.GetOutputs(ctx) })
+}
+
+func (c tracingClient) GetTree(ctx context.Context) (*sway.Node, error) {
+	return traceCall("GET_TREE", func() (*sway.Node, error) { return c.Client.GetTree(ctx) })
+}
+
+func (c tracingClient) GetMarks(ctx context.Context) ([]string, error) {
+	return traceCall("GET_MARKS", func() ([]string, error) { return c.Client.GetMarks(ctx) })
+}
+
+func (c tracingClient) GetBarIDs(ctx context.Context) ([]string, error) {
+	return traceCall("GET_BAR_CONFIG", func() ([]string, error) { return c.Client.GetBarIDs(ctx) })
+}
+
+func (c tracingClient) GetBarConfig(ctx context.Context, id string) (*sway.BarConfig, error) {
+	return traceCall(fmt.Sprintf("GET_BAR_CONFIG %q", id), func() (*sway.BarConfig, error) {
+		return c.Client.GetBarConfig(ctx, id)
+	})
+}
+
+func (c tracingClient) GetVersion(ctx context.Context) (*sway.Version, error) {
+	return traceCall("GET_VERSION", func() (*sway.Version, error) { return c.Client.GetVersion(ctx) })
+}
+
+func (c tracingClient) GetBindingModes(ctx context.Context) ([]string, error) {
+	return traceCall("GET_BINDING_MODES", func() ([]string, error) { return c.Client.GetBindingModes(ctx) })
+}
+
+func (c tracingClient) GetConfig(ctx context.Context) (*sway.Config, error) {
+	return traceCall("GET_CONFIG", func() (*sway.Config, error) { return c.Client.GetConfig(ctx) })
+}
+
+func (c tracingClient) SendTick(ctx context.Context, payload string) (*sway.TickReply, error) {
+	return traceCall("SEND_TICK", func() (*sway.TickReply, error) { return c.Client.SendTick(ctx, payload) })
+}
+
+func (c tracingClient) GetInputs(ctx context.Context) ([]sway.Input, error) {
+	return traceCall("GET_INPUTS", func() ([]sway.Input, error) { return c.Client.GetInputs(ctx) })
+}
+
+func (c tracingClient) GetSeats(ctx context.Context) ([]sway.Seat, error) {
+	return traceCall("GET_SEATS", func() ([]sway.Seat, error) { return c.Client.GetSeats(ctx) })
+}
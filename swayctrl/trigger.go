@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// A titleTrigger is a daemon rule that fires when a window's title changes
+// to match a regular expression that the previous title didn't match.
+type titleTrigger struct {
+	// AppID restricts the trigger to windows with this app ID (or X11
+	// class). If empty, the trigger applies to all windows.
+	AppID string `json:"app_id"`
+
+	Match *jsonRegexp `json:"match"`
+
+	// Urgent makes the window urgent.
+	Urgent bool `json:"urgent"`
+	// Exec is a command (passed to /bin/sh -c) to run. The window's con_id,
+	// app ID, and title are in the environment variables SWAYCTRL_CON_ID,
+	// SWAYCTRL_APP_ID, and SWAYCTRL_TITLE.
+	Exec string `json:"exec"`
+}
+
+// jsonRegexp is a regular expression that is compiled when it is decoded
+// from JSON.
+type jsonRegexp struct {
+	*regexp.Regexp
+}
+
+func (r *jsonRegexp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	r.Regexp = re
+	return nil
+}
+
+// titleTriggers tracks window titles and fires the configured title
+// triggers.
+func (h *daemonHandler) titleTriggers(ctx context.Context, e sway.WindowEvent) {
+	id := e.Container.ID
+	h.mu.Lock()
+	old, known := h.titles[id]
+	switch e.Change {
+	case sway.WindowNew, sway.WindowTitle:
+		h.titles[id] = e.Container.Name
+	case sway.WindowClose:
+		delete(h.titles, id)
+	}
+	h.mu.Unlock()
+	if e.Change != sway.WindowTitle {
+		return
+	}
+
+	title := e.Container.Name
+	appID := nodeAppID(&e.Container)
+	for _, t := range h.config().TitleTriggers {
+		if t.Match == nil || (t.AppID != "" && t.AppID != appID) {
+			continue
+		}
+		if !t.Match.MatchString(title) || (known && t.Match.MatchString(old)) {
+			continue
+		}
+		if h.verbose {
+			log.Printf("Title trigger %q fired for con_id %d: %q", t.Match, id, title)
+		}
+		if t.Urgent {
+			h.runCommands(ctx, []string{fmt.Sprintf("[con_id=%d] urgent enable", id)})
+		}
+		if t.Exec != "" {
+			runHook(t.Exec, id, appID, title)
+		}
+	}
+}
+
+func runHook(command string, id int64, appID, title string) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("SWAYCTRL_CON_ID=%d", id),
+		"SWAYCTRL_APP_ID="+appID,
+		"SWAYCTRL_TITLE="+title,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Printf("Error running hook %q: %s", command, err)
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func cmdWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var filters []eventFilter
+	fs.Func("filter", "Only print events matching `selector` (may be repeated)", func(s string) error {
+		f, err := parseEventFilter(s)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+		return nil
+	})
+	fs.Usage = func() {
+		var names []string
+		for _, name := range ipcEventNames {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, `Usage:
+
+  swayctrl watch [-filter selector...] [event types...]
+
+The watch command subscribes to sway events and prints each one as a line of
+JSON of the form
+
+  {"type": "window", "event": {...}}
+
+where the event is exactly as sway sent it. The event types are:
+
+  %s
+
+If no types are given, all events are printed.
+
+A -filter selector is either path=value, which matches events where the field
+at the dot-separated path is equal to value, or path~regexp, which matches
+if it matches the regular expression. If -filter is given multiple times, an
+event must match all the selectors. For example:
+
+  swayctrl watch -filter change=focus -filter 'container.app_id~^foot' window
+`, strings.Join(names, "\n  "))
+	}
+	fs.Parse(args)
+
+	events := fs.Args()
+	if len(events) == 0 {
+		for _, name := range ipcEventNames {
+			events = append(events, name)
+		}
+		sort.Strings(events)
+	}
+	for _, event := range events {
+		var ok bool
+		for _, name := range ipcEventNames {
+			if event == name {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			log.Fatalf("Unknown event type %q", event)
+		}
+	}
+
+	ctx := context.Background()
+	enc := json.NewEncoder(os.Stdout)
+	err := ipcSubscribeRaw(ctx, events, func(typ ipcMessageType, payload []byte) {
+		name, ok := ipcEventNames[typ]
+		if !ok {
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		var event any
+		if err := dec.Decode(&event); err != nil {
+			log.Printf("Error decoding %s event: %s", name, err)
+			return
+		}
+		for _, f := range filters {
+			if !f.match(event) {
+				return
+			}
+		}
+		msg := struct {
+			Type  string          `json:"type"`
+			Event json.RawMessage `json:"event"`
+		}{name, payload}
+		if err := enc.Encode(msg); err != nil {
+			log.Fatal(err)
+		}
+	})
+	log.Fatalln("Error with subscription:", err)
+}
+
+type eventFilter struct {
+	path  []string
+	value string         // for =
+	re    *regexp.Regexp // for ~
+}
+
+func parseEventFilter(s string) (eventFilter, error) {
+	i := strings.IndexAny(s, "=~")
+	if i <= 0 {
+		return eventFilter{}, fmt.Errorf("bad selector %q (must be path=value or path~regexp)", s)
+	}
+	f := eventFilter{path: strings.Split(s[:i], ".")}
+	if s[i] == '=' {
+		f.value = s[i+1:]
+		return f, nil
+	}
+	re, err := regexp.Compile(s[i+1:])
+	if err != nil {
+		return eventFilter{}, fmt.Errorf("bad regexp in selector %q: %s", s, err)
+	}
+	f.re = re
+	return f, nil
+}
+
+func (f eventFilter) match(event any) bool {
+	v := event
+	for _, key := range f.path {
+		switch x := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = x[key]; !ok {
+				return false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(x) {
+				return false
+			}
+			v = x[i]
+		default:
+			return false
+		}
+	}
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case nil:
+		s = "null"
+	case map[string]any, []any:
+		b, err := json.Marshal(x)
+		if err != nil {
+			panic(err)
+		}
+		s = string(b)
+	default:
+		s = fmt.Sprint(x)
+	}
+	if f.re != nil {
+		return f.re.MatchString(s)
+	}
+	return s == f.value
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/subcmd"
+)
+
+var workspaceCmds = []subcmd.Command{
+	{
+		Name:        "rename",
+		Description: "rename the focused workspace, keeping its number",
+		Do:          cmdWorkspaceRename,
+	},
+}
+
+func cmdWorkspace(args []string) {
+	r := subcmd.New("swayctrl workspace", workspaceCmds, flag.ExitOnError)
+	r.Run(args)
+}
+
+func cmdWorkspaceRename(args []string) {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage:
+
+  swayctrl workspace rename [label]
+
+The rename command renames the focused workspace. If the workspace name has a
+numeric prefix (as in "3" or "3:mail"), the number is kept and only the label
+after it is changed, so that 'workspace number N' keybindings keep working.
+An empty label resets the workspace name to just the number.
+
+If no label is given, prompt for one using the menu command from the config
+file (by default, wofi --dmenu).
+
+If the daemon is running, it remembers labels of numbered workspaces and
+restores them when sway recreates a workspace with the same number.
+`)
+	}
+	fs.Parse(args)
+
+	var label string
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		label = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newClient(ctx)
+	workspaces, err := client.GetWorkspaces(ctx)
+	if err != nil {
+		log.Fatalln("GET_WORKSPACES failed:", err)
+	}
+	var cur string
+	for _, ws := range workspaces {
+		if ws.Focused {
+			cur = ws.Name
+			break
+		}
+	}
+	if cur == "" {
+		log.Fatal("No focused workspace")
+	}
+	num, curLabel, numbered := parseWorkspaceName(cur)
+
+	if fs.NArg() == 0 {
+		if !numbered {
+			curLabel = cur
+		}
+		var ok bool
+		label, ok = prompt(loadConfig().Menu, []string{curLabel})
+		if !ok {
+			return
+		}
+	}
+
+	name := label
+	if numbered {
+		name = workspaceName(num, label)
+	}
+	if name == "" {
+		log.Fatal("Workspace name cannot be empty")
+	}
+	if name == cur {
+		return
+	}
+	command := fmt.Sprintf("rename workspace %q to %q", cur, name)
+	if err := runCommand(ctx, client, command); err != nil {
+		log.Fatalf("Error running command %q: %s", command, err)
+	}
+}
+
+// parseWorkspaceName splits a workspace name such as "3:mail" into its
+// number and label. The ok result reports whether name has a numeric prefix.
+func parseWorkspaceName(name string) (num int, label string, ok bool) {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, "", false
+	}
+	num, err := strconv.Atoi(name[:i])
+	if err != nil {
+		return 0, "", false
+	}
+	label = strings.TrimSpace(strings.TrimPrefix(name[i:], ":"))
+	return num, label, true
+}
+
+// workspaceName is the inverse of parseWorkspaceName.
+func workspaceName(num int, label string) string {
+	if label == "" {
+		return strconv.Itoa(num)
+	}
+	return fmt.Sprintf("%d:%s", num, label)
+}
+
+// prompt runs the dmenu-style menu command with the given choices and returns
+// the selected (or typed) line. It returns false if the user cancelled.
+func prompt(menu string, choices []string) (string, bool) {
+	cmd := exec.Command("/bin/sh", "-c", menu)
+	cmd.Stdin = strings.NewReader(strings.Join(choices, "\n"))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return "", false
+	}
+	if err != nil {
+		log.Fatalf("Error running menu command %q: %s", menu, err)
+	}
+	return strings.TrimSpace(string(out)), true
+}